@@ -0,0 +1,101 @@
+// Package checkpoint records how far a bulk load has got, so an interrupted
+// load can resume where it left off instead of restarting from zero.
+//
+// Checkpoints live in a txraw_checkpoints side table in the target database
+// and are written inside the load transaction, e.g. from
+// pgxraw.ChunkOptions.OnChunk, so a checkpoint is committed exactly when the
+// rows it counts are:
+//
+//	done, err := checkpoint.Load(ctx, db, key)
+//	src, err = checkpoint.Skip(src, done)
+//	_, err = pgxraw.CopyFromChunks(ctx, db, table, columns, src, pgxraw.ChunkOptions{
+//		CommitEach: true,
+//		OnChunk: func(ctx context.Context, tx *txraw.Tx, rows int64) error {
+//			return checkpoint.Save(ctx, tx, key, done+rows)
+//		},
+//	})
+package checkpoint
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Schema creates the txraw_checkpoints table if it does not exist yet.
+const Schema = `
+CREATE TABLE IF NOT EXISTS txraw_checkpoints (
+    key        TEXT PRIMARY KEY,
+    rows_done  BIGINT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`
+
+// Querier is implemented by *sql.DB, *sql.Tx and *txraw.Tx.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// EnsureTable creates txraw_checkpoints if needed.
+func EnsureTable(ctx context.Context, q Querier) error {
+	if _, err := q.ExecContext(ctx, Schema); err != nil {
+		return fmt.Errorf("checkpoint: creating txraw_checkpoints failed: %w", err)
+	}
+	return nil
+}
+
+// Load returns the number of rows already loaded for key, or 0 if there is
+// no checkpoint.
+func Load(ctx context.Context, q Querier, key string) (int64, error) {
+	var rowsDone int64
+	err := q.QueryRowContext(ctx, "SELECT rows_done FROM txraw_checkpoints WHERE key = $1", key).Scan(&rowsDone)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("checkpoint: loading %q failed: %w", key, err)
+	}
+	return rowsDone, nil
+}
+
+// Save records rowsDone for key. Call it within the transaction that loaded
+// those rows.
+func Save(ctx context.Context, q Querier, key string, rowsDone int64) error {
+	_, err := q.ExecContext(ctx, `
+		INSERT INTO txraw_checkpoints (key, rows_done) VALUES ($1, $2)
+		ON CONFLICT (key) DO UPDATE SET rows_done = EXCLUDED.rows_done, updated_at = now()`,
+		key, rowsDone)
+	if err != nil {
+		return fmt.Errorf("checkpoint: saving %q failed: %w", key, err)
+	}
+	return nil
+}
+
+// Clear removes the checkpoint of key, e.g. once the load has completed or
+// to force a restart from zero.
+func Clear(ctx context.Context, q Querier, key string) error {
+	if _, err := q.ExecContext(ctx, "DELETE FROM txraw_checkpoints WHERE key = $1", key); err != nil {
+		return fmt.Errorf("checkpoint: clearing %q failed: %w", key, err)
+	}
+	return nil
+}
+
+// Skip returns src advanced past its first n rows. The source must produce
+// rows in the same order on every run for resuming to be correct.
+func Skip(src pgx.CopyFromSource, n int64) (pgx.CopyFromSource, error) {
+	for i := int64(0); i < n; i++ {
+		if !src.Next() {
+			if err := src.Err(); err != nil {
+				return nil, fmt.Errorf("checkpoint: skipping row %d failed: %w", i+1, err)
+			}
+			return nil, fmt.Errorf("checkpoint: source has only %d rows, checkpoint is at %d", i, n)
+		}
+		if _, err := src.Values(); err != nil {
+			return nil, fmt.Errorf("checkpoint: skipping row %d failed: %w", i+1, err)
+		}
+	}
+	return src, nil
+}
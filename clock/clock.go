@@ -0,0 +1,154 @@
+// Package clock is the seam between the loaders and wall-clock time. Code that
+// waits or timestamps takes a Clock instead of calling the time package, so
+// tests and soak/chaos tooling can substitute a Manual clock and run hours of
+// pacing and backoff instantly and deterministically.
+package clock
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and creates timers.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is the part of *time.Timer that Clock users need.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// Real is the Clock backed by the time package.
+var Real Clock = realClock{}
+
+// Or returns c, or Real if c is nil, so that a zero-valued Clock field in an
+// options struct means wall-clock time.
+func Or(c Clock) Clock {
+	if c == nil {
+		return Real
+	}
+	return c
+}
+
+// Since returns the time elapsed on c since t.
+func Since(c Clock, t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// Sleep pauses for d on c, returning early with ctx.Err() if ctx is done.
+func Sleep(ctx context.Context, c Clock, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := c.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C():
+		return nil
+	}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTimer(d time.Duration) Timer { return realTimer{time.NewTimer(d)} }
+
+type realTimer struct{ t *time.Timer }
+
+func (t realTimer) C() <-chan time.Time { return t.t.C }
+func (t realTimer) Stop() bool          { return t.t.Stop() }
+
+// Manual is a Clock that only moves when Advance or Set is called. Timers
+// fire, in deadline order, when the clock reaches their deadline.
+type Manual struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*manualTimer
+}
+
+// NewManual returns a Manual clock set to start.
+func NewManual(start time.Time) *Manual {
+	return &Manual{now: start}
+}
+
+// Now returns the clock's current time.
+func (m *Manual) Now() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.now
+}
+
+// NewTimer returns a timer that fires once the clock has advanced by d.
+func (m *Manual) NewTimer(d time.Duration) Timer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t := &manualTimer{m: m, deadline: m.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.c <- m.now
+		return t
+	}
+	m.timers = append(m.timers, t)
+	return t
+}
+
+// Advance moves the clock forward by d, firing every timer that falls due.
+func (m *Manual) Advance(d time.Duration) {
+	m.Set(m.Now().Add(d))
+}
+
+// Set moves the clock to t, firing every timer that falls due. Moving the
+// clock backwards fires nothing.
+func (m *Manual) Set(t time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if t.After(m.now) {
+		m.now = t
+	}
+
+	sort.Slice(m.timers, func(i, j int) bool { return m.timers[i].deadline.Before(m.timers[j].deadline) })
+	pending := m.timers[:0]
+	for _, timer := range m.timers {
+		if timer.deadline.After(m.now) {
+			pending = append(pending, timer)
+			continue
+		}
+		timer.c <- timer.deadline
+	}
+	m.timers = pending
+}
+
+// Pending returns the number of timers waiting to fire, so a driver loop can
+// tell when the code under test has started waiting.
+func (m *Manual) Pending() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.timers)
+}
+
+type manualTimer struct {
+	m        *Manual
+	deadline time.Time
+	c        chan time.Time
+}
+
+func (t *manualTimer) C() <-chan time.Time { return t.c }
+
+func (t *manualTimer) Stop() bool {
+	t.m.mu.Lock()
+	defer t.m.mu.Unlock()
+	for i, other := range t.m.timers {
+		if other == t {
+			t.m.timers = append(t.m.timers[:i], t.m.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
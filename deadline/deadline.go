@@ -0,0 +1,161 @@
+// Package deadline resolves per-operation timeouts from layered defaults, so
+// operators can bound connect, copy, commit and verification times without
+// touching every call site.
+//
+// Timeouts are resolved from the most general to the most specific layer,
+// each non-zero value overriding the previous one:
+//
+//	global (SetGlobal) → profile (WithProfile) → job (WithJob) → call
+//
+// and applied with Context:
+//
+//	ctx, cancel := deadline.Context(ctx, deadline.Copy)
+//	defer cancel()
+package deadline
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Op is an operation with its own timeout.
+type Op int
+
+const (
+	Connect Op = iota
+	Copy
+	Commit
+	Verify
+)
+
+func (op Op) String() string {
+	switch op {
+	case Connect:
+		return "connect"
+	case Copy:
+		return "copy"
+	case Commit:
+		return "commit"
+	case Verify:
+		return "verify"
+	default:
+		return "unknown"
+	}
+}
+
+// Timeouts holds a timeout per operation. Zero fields leave the value of
+// the layer below in place.
+type Timeouts struct {
+	Connect time.Duration
+	Copy    time.Duration
+	Commit  time.Duration
+	Verify  time.Duration
+}
+
+// Defaults are the global timeouts until SetGlobal is called.
+var Defaults = Timeouts{
+	Connect: 10 * time.Second,
+	Copy:    30 * time.Minute,
+	Commit:  time.Minute,
+	Verify:  30 * time.Second,
+}
+
+// Merge returns t with the non-zero fields of override applied.
+func (t Timeouts) Merge(override Timeouts) Timeouts {
+	if override.Connect != 0 {
+		t.Connect = override.Connect
+	}
+	if override.Copy != 0 {
+		t.Copy = override.Copy
+	}
+	if override.Commit != 0 {
+		t.Commit = override.Commit
+	}
+	if override.Verify != 0 {
+		t.Verify = override.Verify
+	}
+	return t
+}
+
+// For returns the timeout of op.
+func (t Timeouts) For(op Op) time.Duration {
+	switch op {
+	case Connect:
+		return t.Connect
+	case Copy:
+		return t.Copy
+	case Commit:
+		return t.Commit
+	case Verify:
+		return t.Verify
+	default:
+		return 0
+	}
+}
+
+var registry = struct {
+	sync.RWMutex
+	global   Timeouts
+	profiles map[string]Timeouts
+}{global: Defaults, profiles: make(map[string]Timeouts)}
+
+// SetGlobal replaces the global layer; zero fields keep Defaults.
+func SetGlobal(t Timeouts) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.global = Defaults.Merge(t)
+}
+
+// RegisterProfile defines a named profile layer, e.g. "nightly" or
+// "interactive".
+func RegisterProfile(name string, t Timeouts) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.profiles[name] = t
+}
+
+type profileKey struct{}
+type jobKey struct{}
+
+// WithProfile selects the profile layer for work under ctx. Unknown
+// profiles add nothing.
+func WithProfile(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, profileKey{}, name)
+}
+
+// WithJob sets the job layer for work under ctx, on top of any job layer
+// already present.
+func WithJob(ctx context.Context, t Timeouts) context.Context {
+	job, _ := ctx.Value(jobKey{}).(Timeouts)
+	return context.WithValue(ctx, jobKey{}, job.Merge(t))
+}
+
+// Resolve returns the timeouts in effect under ctx, without a call layer.
+func Resolve(ctx context.Context) Timeouts {
+	registry.RLock()
+	t := registry.global
+	if name, ok := ctx.Value(profileKey{}).(string); ok {
+		t = t.Merge(registry.profiles[name])
+	}
+	registry.RUnlock()
+
+	if job, ok := ctx.Value(jobKey{}).(Timeouts); ok {
+		t = t.Merge(job)
+	}
+	return t
+}
+
+// Context returns ctx bounded by the timeout of op resolved under ctx. A
+// positive override is the call layer and takes precedence. An existing
+// earlier deadline on ctx is kept.
+func Context(ctx context.Context, op Op, override ...time.Duration) (context.Context, context.CancelFunc) {
+	timeout := Resolve(ctx).For(op)
+	if len(override) > 0 && override[0] > 0 {
+		timeout = override[0]
+	}
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
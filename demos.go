@@ -0,0 +1,566 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/eqld/example-tx-raw/pgxraw"
+	"github.com/eqld/example-tx-raw/pipe"
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// demoTable is created by each demo inside its own transaction, which is
+// always rolled back, so demos leave nothing behind and never touch items.
+const demoTable = "demo_items"
+
+// demo is a runnable example of one API, run with "demo <name>". run, or
+// runDB for demos that manage transactions themselves, returns an error if
+// the API does not behave as documented.
+type demo struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, tx *txraw.Tx) error
+	runDB   func(ctx context.Context, db *sql.DB) error
+}
+
+var demos = []demo{
+	{"copy-structs", "CopyFromStructs: load []T, identity column left to the server", demoCopyStructs, nil},
+	{"copy-csv", "CopyFromCSV: stream CSV with a header, fields parsed by column type", demoCopyCSV, nil},
+	{"copy-mapped", "CopyFromMapped: auto-map mismatched field names and coerce strings", demoCopyMapped, nil},
+	{"upsert", "UpsertCopy: staged upsert with ReplaceExisting and CollectErrors", demoUpsert, nil},
+	{"query-structs", "QueryStructs: read rows written in the same transaction", demoQueryStructs, nil},
+	{"assertions", "Assertions: a failing pre-commit check rolls the load back", demoAssertions, nil},
+	{"savepoints", "Tx.Savepoint: roll back a failed CopyFrom chunk and keep going", demoSavepoints, nil},
+	{"nested", "Tx.BeginNested: library code commits and rolls back inside an outer transaction", demoNested, nil},
+	{"retry", "WithRetry: a serialization failure after CopyFrom retries the whole transaction", nil, demoRetry},
+	{"relay", "pipe.Copy: stream rows from one database into another, committed only if both sides succeed", nil, demoRelay},
+	{"cursor", "QueryCursor: read a large result in batches and write while reading", demoCursor, nil},
+	{"large-object", "ImportLargeObject: store and read back a large object inside the transaction", demoLargeObject, nil},
+}
+
+// runDemos runs the demos named in names, "all" of them, or lists them if
+// names is empty. Each runs in a transaction of its own that is rolled back.
+// It returns the number of demos that failed.
+func runDemos(ctx context.Context, db *sql.DB, names []string) int {
+	if len(names) == 0 {
+		log.Println("Available demos (run with: demo <name>... or demo all):")
+		for _, d := range demos {
+			log.Printf("  %-14s %s", d.name, d.summary)
+		}
+		return 0
+	}
+
+	var selected []demo
+	for _, name := range names {
+		if name == "all" {
+			selected = demos
+			break
+		}
+		i := slices.IndexFunc(demos, func(d demo) bool { return d.name == name })
+		if i < 0 {
+			log.Printf("✗ Unknown demo %q", name)
+			return 1
+		}
+		selected = append(selected, demos[i])
+	}
+
+	failed := 0
+	for _, d := range selected {
+		log.Printf("--- Demo %s: %s ---", d.name, d.summary)
+		if err := runDemo(ctx, db, d); err != nil {
+			log.Printf("✗ Demo %s failed: %v", d.name, err)
+			failed++
+		} else {
+			log.Printf("✓ Demo %s passed", d.name)
+		}
+		log.Println()
+	}
+	return failed
+}
+
+func runDemo(ctx context.Context, db *sql.DB, d demo) error {
+	if d.runDB != nil {
+		return d.runDB(ctx, db)
+	}
+	tx, err := txraw.Begin(ctx, db, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createDemoTable(ctx, tx, ""); err != nil {
+		return err
+	}
+	return d.run(ctx, tx)
+}
+
+// createDemoTable creates demoTable as a temporary table, with the ON COMMIT
+// clause onCommit if not empty.
+func createDemoTable(ctx context.Context, tx txraw.RawTx, onCommit string) error {
+	_, err := tx.ExecContext(ctx, fmt.Sprintf(`CREATE TEMP TABLE %s (
+		id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		price NUMERIC(10, 2),
+		data TEXT
+	) %s`, demoTable, onCommit))
+	if err != nil {
+		return fmt.Errorf("creating %s failed: %w", demoTable, err)
+	}
+	return nil
+}
+
+// expect fails a demo with the formatted message unless ok.
+func expect(ok bool, format string, args ...any) error {
+	if ok {
+		return nil
+	}
+	return fmt.Errorf("expectation failed: "+format, args...)
+}
+
+func demoCount(ctx context.Context, tx *txraw.Tx, where string) (int, error) {
+	var n int
+	err := tx.QueryRowContext(ctx, fmt.Sprintf("SELECT count(*) FROM %s WHERE %s", demoTable, where)).Scan(&n)
+	return n, err
+}
+
+// demoCopy copies rows into columns of demoTable on tx's raw connection.
+func demoCopy(ctx context.Context, tx *txraw.Tx, columns []string, rows [][]any) error {
+	return tx.RawContext(ctx, txraw.UnwrapAs(func(conn *pgx.Conn) error {
+		_, err := conn.CopyFrom(ctx, pgx.Identifier{demoTable}, columns, pgx.CopyFromRows(rows))
+		return err
+	}))
+}
+
+func demoCopyStructs(ctx context.Context, tx *txraw.Tx) error {
+	type row struct {
+		ID    int    `db:"id"` // identity: filled in by the server
+		Name  string `db:"name"`
+		Price float64
+		Data  *string `db:"data"`
+	}
+	note := "with data"
+	rows := []row{{ID: 100, Name: "alpha", Price: 1.5, Data: &note}, {Name: "beta", Price: 2}, {Name: "gamma", Price: 3.25}}
+
+	n, err := pgxraw.CopyFromStructs(ctx, tx, demoTable, rows)
+	if err != nil {
+		return err
+	}
+	log.Printf("✓ Copied %d structs", n)
+
+	var maxID, nulls int
+	err = tx.QueryRowContext(ctx, fmt.Sprintf("SELECT max(id), count(*) FILTER (WHERE data IS NULL) FROM %s", demoTable)).Scan(&maxID, &nulls)
+	if err != nil {
+		return err
+	}
+	log.Printf("✓ Server assigned ids up to %d; %d rows have NULL data", maxID, nulls)
+	return errors.Join(
+		expect(n == int64(len(rows)), "copied %d rows, want %d", n, len(rows)),
+		expect(maxID == len(rows), "max id %d, want %d (ID field must be ignored)", maxID, len(rows)),
+		expect(nulls == 2, "%d NULL data, want 2", nulls))
+}
+
+func demoCopyCSV(ctx context.Context, tx *txraw.Tx) error {
+	input := "name,price,comment\nalpha,1.50,first\nbeta,,second\ngamma,3.25,third\ndelta,2.00,\"\"\n"
+	n, err := pgxraw.CopyFromCSV(ctx, tx, demoTable, strings.NewReader(input), pgxraw.CSVOptions{
+		Mapping: map[string]string{"comment": "data"},
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("✓ Copied %d CSV records", n)
+
+	var total string
+	if err := tx.QueryRowContext(ctx, fmt.Sprintf("SELECT sum(price)::text FROM %s", demoTable)).Scan(&total); err != nil {
+		return err
+	}
+	withData, err := demoCount(ctx, tx, "data IS NOT NULL")
+	if err != nil {
+		return err
+	}
+	log.Printf("✓ Sum of prices %s, %d rows with data", total, withData)
+	return errors.Join(
+		expect(n == 4, "copied %d rows, want 4", n),
+		expect(total == "6.75", "sum of prices %s, want 6.75 (empty field must load NULL)", total),
+		expect(withData == 4, "%d rows with data, want 4 (quoted empty string must not load NULL)", withData))
+}
+
+func demoCopyMapped(ctx context.Context, tx *txraw.Tx) error {
+	described, err := pgxraw.DescribeTable(ctx, tx, demoTable)
+	if err != nil {
+		return err
+	}
+	for _, c := range described {
+		log.Printf("  column %-6s %-14s not null=%-5t identity=%t", c.Name, c.Type, c.NotNull, c.Identity)
+	}
+
+	fields := []string{"ID", "Name", "Price", "Comment"}
+	src := pgx.CopyFromRows([][]any{
+		{"7", "alpha", "1.50", "no such column"},
+		{"8", "beta", 2, nil},
+	})
+	result, err := pgxraw.CopyFromMapped(ctx, tx, demoTable, fields, src)
+	if err != nil {
+		return err
+	}
+	log.Printf("✓ Copied %d rows, mapping %v, unmapped %v, skipped %v", result.Copied, result.Mapping, result.Unmapped, result.Skipped)
+
+	priced, err := demoCount(ctx, tx, "price IS NOT NULL")
+	if err != nil {
+		return err
+	}
+	return errors.Join(
+		expect(result.Copied == 2, "copied %d rows, want 2", result.Copied),
+		expect(slices.Equal(result.Unmapped, []string{"Comment"}), "unmapped %v, want [Comment]", result.Unmapped),
+		expect(slices.Equal(result.Skipped, []string{"ID"}), "skipped %v, want [ID]", result.Skipped),
+		expect(priced == 2, "%d rows with a price, want 2 (strings must be coerced)", priced))
+}
+
+func demoUpsert(ctx context.Context, tx *txraw.Tx) error {
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (name, price) VALUES ('alpha', 1), ('beta', 2)", demoTable)); err != nil {
+		return err
+	}
+
+	columns := []string{"name", "price"}
+	replaced, err := pgxraw.UpsertCopy(ctx, tx, demoTable, columns,
+		pgx.CopyFromRows([][]any{{"beta", 20}, {"gamma", 3}}),
+		pgxraw.UpsertOptions{ConflictColumns: []string{"name"}, Strategy: pgxraw.ReplaceExisting})
+	if err != nil {
+		return err
+	}
+	log.Printf("✓ ReplaceExisting: staged %d, merged %d", replaced.Staged, replaced.Merged)
+
+	collected, err := pgxraw.UpsertCopy(ctx, tx, demoTable, columns,
+		pgx.CopyFromRows([][]any{{"delta", 4}, {nil, 5}, {"epsilon", 6}}),
+		pgxraw.UpsertOptions{ConflictColumns: []string{"name"}, Strategy: pgxraw.CollectErrors})
+	if err != nil {
+		return err
+	}
+	log.Printf("✓ CollectErrors: merged %d, rejected %v", collected.Merged, collected.Errors)
+
+	total, err := demoCount(ctx, tx, "true")
+	if err != nil {
+		return err
+	}
+	replacedPrice, err := demoCount(ctx, tx, "name = 'beta' AND price = 20")
+	if err != nil {
+		return err
+	}
+	return errors.Join(
+		expect(replaced.Merged == 2, "ReplaceExisting merged %d, want 2", replaced.Merged),
+		expect(replacedPrice == 1, "beta was not replaced"),
+		expect(collected.Merged == 2, "CollectErrors merged %d, want 2", collected.Merged),
+		expect(len(collected.Errors) == 1 && collected.Errors[0].Row == 2, "rejected %v, want row 2", collected.Errors),
+		expect(total == 5, "%d rows, want 5", total))
+}
+
+func demoQueryStructs(ctx context.Context, tx *txraw.Tx) error {
+	rows := [][]any{{"alpha", "first"}, {"beta", nil}, {"gamma", "third"}}
+	if err := demoCopy(ctx, tx, []string{"name", "data"}, rows); err != nil {
+		return err
+	}
+
+	var names []string
+	var nulls int
+	query := fmt.Sprintf("SELECT id, name, data FROM %s ORDER BY id", demoTable)
+	for it, err := range pgxraw.QueryStructs[item](ctx, tx, query) {
+		if err != nil {
+			return err
+		}
+		names = append(names, it.Name)
+		if it.Data == nil {
+			nulls++
+		}
+	}
+	log.Printf("✓ Read back %v before commit", names)
+	return errors.Join(
+		expect(slices.Equal(names, []string{"alpha", "beta", "gamma"}), "read %v, want [alpha beta gamma]", names),
+		expect(nulls == 1, "%d NULL data, want 1", nulls))
+}
+
+func demoAssertions(ctx context.Context, tx *txraw.Tx) error {
+	rows := [][]any{{"alpha", 1}, {"beta", -2}}
+	if err := demoCopy(ctx, tx, []string{"name", "price"}, rows); err != nil {
+		return err
+	}
+
+	assertions := pgxraw.NewAssertions().Add(pgxraw.Assertion{
+		Name:  "no negative prices",
+		Query: fmt.Sprintf("SELECT count(*) FROM %s WHERE price < 0", demoTable),
+	})
+	err := assertions.Commit(ctx, tx)
+	var assertErr *pgxraw.AssertionError
+	if !errors.As(err, &assertErr) {
+		return fmt.Errorf("expectation failed: commit returned %v, want an AssertionError", err)
+	}
+	log.Printf("✓ Commit refused: %v", err)
+
+	_, err = tx.ExecContext(ctx, "SELECT 1")
+	return errors.Join(
+		expect(assertErr.Assertion.Name == "no negative prices" && assertErr.Got == 1, "got %+v", assertErr),
+		expect(errors.Is(err, sql.ErrTxDone), "transaction still open after failed assertion: %v", err))
+}
+
+func demoSavepoints(ctx context.Context, tx *txraw.Tx) error {
+	chunks := [][][]any{
+		{{"alpha", 1}, {"beta", 2}},
+		{{"gamma", 3}, {nil, 4}}, // NULL name: the whole chunk fails
+		{{"delta", 5}, {"epsilon", 6}},
+	}
+	var failed []int
+	for i, chunk := range chunks {
+		if err := tx.SavepointContext(ctx, "chunk"); err != nil {
+			return err
+		}
+		if err := demoCopy(ctx, tx, []string{"name", "price"}, chunk); err != nil {
+			log.Printf("⚠️  Chunk %d failed, rolling back to its savepoint: %v", i+1, err)
+			failed = append(failed, i+1)
+			if err := tx.RollbackToContext(ctx, "chunk"); err != nil {
+				return err
+			}
+		}
+		if err := tx.ReleaseContext(ctx, "chunk"); err != nil {
+			return err
+		}
+	}
+
+	total, err := demoCount(ctx, tx, "true")
+	if err != nil {
+		return err
+	}
+	gamma, err := demoCount(ctx, tx, "name = 'gamma'")
+	if err != nil {
+		return err
+	}
+	log.Printf("✓ Transaction still usable: %d rows from the chunks that succeeded", total)
+	return errors.Join(
+		expect(slices.Equal(failed, []int{2}), "failed chunks %v, want [2]", failed),
+		expect(total == 4, "%d rows, want 4", total),
+		expect(gamma == 0, "rows of the failed chunk survived its rollback"))
+}
+
+// demoSave stands in for library code that runs in "its own" transaction.
+func demoSave(ctx context.Context, tx txraw.RawTx, name string, fail bool) error {
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (name) VALUES ($1)", demoTable), name); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if fail {
+		_ = tx.Rollback()
+		return fmt.Errorf("saving %s failed", name)
+	}
+	return tx.Commit()
+}
+
+func demoNested(ctx context.Context, tx *txraw.Tx) error {
+	if err := demoCopy(ctx, tx, []string{"name"}, [][]any{{"bulk 1"}, {"bulk 2"}}); err != nil {
+		return err
+	}
+
+	committed, err := tx.BeginNested(ctx)
+	if err != nil {
+		return err
+	}
+	if err := demoSave(ctx, committed, "kept", false); err != nil {
+		return err
+	}
+
+	outer, err := tx.BeginNested(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := outer.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (name) VALUES ('outer')", demoTable)); err != nil {
+		return err
+	}
+	inner, err := outer.BeginNested(ctx)
+	if err != nil {
+		return err
+	}
+	saveErr := demoSave(ctx, inner, "discarded", true)
+	log.Printf("⚠️  Inner library call failed and rolled back only its own work: %v", saveErr)
+	if err := outer.Commit(); err != nil {
+		return err
+	}
+
+	var names []string
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT name FROM %s ORDER BY id", demoTable))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	log.Printf("✓ Outer transaction holds %v", names)
+
+	_, doneErr := inner.ExecContext(ctx, "SELECT 1")
+	return errors.Join(
+		expect(slices.Equal(names, []string{"bulk 1", "bulk 2", "kept", "outer"}), "rows %v, want [bulk 1 bulk 2 kept outer]", names),
+		expect(errors.Is(doneErr, sql.ErrTxDone), "finished nested transaction still usable: %v", doneErr))
+}
+
+func demoRetry(ctx context.Context, db *sql.DB) error {
+	opts := txraw.RetryOptions{
+		TxOptions:   &sql.TxOptions{Isolation: sql.LevelSerializable},
+		MaxAttempts: 3,
+		OnRetry: func(attempt int, err error, backoff time.Duration) {
+			log.Printf("⚠️  Attempt %d failed with SQLSTATE %s, retrying in %v: %v", attempt, txraw.SQLState(err), backoff.Round(time.Millisecond), err)
+		},
+	}
+
+	// RetryCockroach is meant for CockroachDB; here its savepoint protocol
+	// runs against PostgreSQL, which handles the simulated conflict the same.
+	var errs []error
+	for _, mode := range []struct {
+		name string
+		mode txraw.RetryMode
+	}{{"new transaction per attempt", txraw.RetryTransaction}, {"cockroach_restart savepoint", txraw.RetryCockroach}} {
+		opts.Mode = mode.mode
+		attempts, copiedInLast := 0, 0
+		err := txraw.WithRetry(ctx, db, opts, func(tx *txraw.Tx) error {
+			attempts++
+			if err := createDemoTable(ctx, tx, "ON COMMIT DROP"); err != nil {
+				return err
+			}
+			if err := demoCopy(ctx, tx, []string{"name"}, [][]any{{"alpha"}, {"beta"}}); err != nil {
+				return err
+			}
+			if attempts == 1 {
+				// What a concurrent writer would cause under SERIALIZABLE.
+				_, err := tx.ExecContext(ctx, `DO $$ BEGIN RAISE EXCEPTION 'simulated conflict' USING ERRCODE = 'serialization_failure'; END $$`)
+				return err
+			}
+			var err error
+			copiedInLast, err = demoCount(ctx, tx, "true")
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", mode.name, err)
+		}
+		log.Printf("✓ %s: committed on attempt %d with %d rows", mode.name, attempts, copiedInLast)
+		errs = append(errs,
+			expect(attempts == 2, "%s: %d attempts, want 2", mode.name, attempts),
+			expect(copiedInLast == 2, "%s: %d rows in the last attempt, want 2 (the failed attempt's rows must be rolled back)", mode.name, copiedInLast))
+	}
+
+	opts.Mode = txraw.RetryTransaction
+	permanent := txraw.WithRetry(ctx, db, opts, func(tx *txraw.Tx) error {
+		_, err := tx.ExecContext(ctx, "SELECT 1/0")
+		return err
+	})
+	log.Printf("✓ Non-retryable error returned at once: %v", permanent)
+	errs = append(errs,
+		expect(permanent != nil && !errors.Is(permanent, txraw.ErrRetriesExhausted) && txraw.SQLState(permanent) == "22012",
+			"division by zero gave %v, want it returned unretried", permanent))
+	return errors.Join(errs...)
+}
+
+// relayTable is the destination of the relay demo. pipe.Copy commits, so it
+// is a regular table, dropped again when the demo ends.
+const relayTable = "demo_relay"
+
+func demoRelay(ctx context.Context, db *sql.DB) error {
+	// Source and destination are the same database here; pass another
+	// *sql.DB as dst to replicate between servers.
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("CREATE TABLE %s (name TEXT NOT NULL, price NUMERIC(10, 2))", relayTable)); err != nil {
+		return fmt.Errorf("creating %s failed: %w", relayTable, err)
+	}
+	defer db.ExecContext(context.WithoutCancel(ctx), "DROP TABLE "+relayTable)
+
+	const query = "SELECT name, price FROM (VALUES ('alpha', 1.50), ('beta', 2.25), ('gamma', NULL)) AS v(name, price)"
+	n, err := pipe.Copy(ctx, db, db, query, relayTable, []string{"name", "price"})
+	if err != nil {
+		return err
+	}
+	log.Printf("✓ Relayed %d rows in binary COPY format", n)
+
+	// A failing source must leave the destination untouched.
+	_, failErr := pipe.Copy(ctx, db, db, "SELECT 'delta', 1/0", relayTable, []string{"name", "price"})
+	log.Printf("⚠️  Relay from a failing query rolled back: %v", failErr)
+
+	var total int
+	var sum string
+	err = db.QueryRowContext(ctx, fmt.Sprintf("SELECT count(*), sum(price)::text FROM %s", relayTable)).Scan(&total, &sum)
+	if err != nil {
+		return err
+	}
+	return errors.Join(
+		expect(n == 3, "relayed %d rows, want 3", n),
+		expect(failErr != nil, "relay from a failing query succeeded"),
+		expect(total == 3 && sum == "3.75", "destination has %d rows summing to %s, want 3 summing to 3.75", total, sum))
+}
+
+func demoCursor(ctx context.Context, tx *txraw.Tx) error {
+	type row struct {
+		N int `db:"n"`
+	}
+
+	// Copy a generated series into demoTable while reading it through a
+	// cursor: each batch is written before the next one is fetched.
+	read, batch := 0, [][]any{}
+	query := "SELECT n FROM generate_series(1, $1::int) AS n"
+	for r, err := range pgxraw.QueryCursor[row](ctx, tx, query, 100, 250) {
+		if err != nil {
+			return err
+		}
+		read++
+		batch = append(batch, []any{fmt.Sprintf("row %d", r.N), r.N})
+		if len(batch) == 100 {
+			if err := demoCopy(ctx, tx, []string{"name", "price"}, batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := demoCopy(ctx, tx, []string{"name", "price"}, batch); err != nil {
+		return err
+	}
+
+	total, err := demoCount(ctx, tx, "true")
+	if err != nil {
+		return err
+	}
+	log.Printf("✓ Read %d rows through a cursor and wrote %d while reading", read, total)
+	return errors.Join(
+		expect(read == 250, "read %d rows, want 250", read),
+		expect(total == 250, "wrote %d rows, want 250", total))
+}
+
+func demoLargeObject(ctx context.Context, tx *txraw.Tx) error {
+	content := strings.Repeat("large object payload ", 20000)
+	oid, n, err := pgxraw.ImportLargeObject(ctx, tx, strings.NewReader(content))
+	if err != nil {
+		return err
+	}
+	log.Printf("✓ Stored %d bytes as large object %d", n, oid)
+
+	var back strings.Builder
+	read, err := pgxraw.ExportLargeObject(ctx, tx, oid, &back)
+	if err != nil {
+		return err
+	}
+	log.Printf("✓ Read %d bytes back before commit", read)
+
+	// The large object goes away with the rolled-back transaction; unlink
+	// it explicitly only to show how.
+	if err := pgxraw.UnlinkLargeObject(ctx, tx, oid); err != nil {
+		return err
+	}
+	return errors.Join(
+		expect(n == int64(len(content)), "stored %d bytes, want %d", n, len(content)),
+		expect(back.String() == content, "read back %d bytes that differ from the %d stored", read, len(content)))
+}
@@ -0,0 +1,240 @@
+package diff
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/eqld/example-tx-raw/pgxraw"
+	"github.com/eqld/example-tx-raw/tempfiles"
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// ErrPatchConflict is returned by Apply when an update or delete of the
+// patch found no row with its key, i.e. the target has changed since the
+// diff. The transaction is rolled back.
+var ErrPatchConflict = errors.New("diff: patch does not match the target")
+
+// ApplyOptions configures Apply.
+type ApplyOptions struct {
+	// Key are the key columns the patch was made with. If empty, the
+	// primary key of the table is used.
+	Key []string
+	// TempDir is where the patch is spooled while it is split by operation.
+	// Empty means os.TempDir().
+	TempDir string
+}
+
+// ApplyResult counts the rows Apply changed.
+type ApplyResult struct {
+	Inserted int64
+	Updated  int64
+	Deleted  int64
+}
+
+// Apply applies a patch written by Tables to table in db, in one
+// transaction: deletes and updates are staged in temporary tables and
+// applied with one DELETE ... USING and one UPDATE ... FROM, then inserts are
+// loaded with COPY. Either the whole patch is applied or nothing is.
+//
+// The patch is read once and spooled to temporary files, split by
+// operation, so it is never held in memory.
+func Apply(ctx context.Context, db *sql.DB, table string, patch io.Reader, opts ApplyOptions) (ApplyResult, error) {
+	var result ApplyResult
+
+	key := opts.Key
+	if len(key) == 0 {
+		var err error
+		if key, err = primaryKey(ctx, Side{DB: db, Table: table}); err != nil {
+			return result, err
+		}
+	}
+
+	files, err := tempfiles.New(opts.TempDir)
+	if err != nil {
+		return result, err
+	}
+	defer files.Cleanup()
+
+	spool, err := spoolPatch(files, patch)
+	if err != nil {
+		return result, err
+	}
+	defer spool.close()
+
+	err = txraw.WithTx(ctx, db, func(tx *txraw.Tx) error {
+		if spool.counts[Delete] > 0 {
+			stage, err := stageRows(ctx, tx, table, key, spool.files[Delete])
+			if err != nil {
+				return err
+			}
+			res, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s t USING %s s WHERE %s",
+				pgx.Identifier(strings.Split(table, ".")).Sanitize(), stage, joinKey(key)))
+			if err != nil {
+				return fmt.Errorf("diff: applying deletes failed: %w", err)
+			}
+			if result.Deleted, err = res.RowsAffected(); err != nil {
+				return err
+			}
+			if result.Deleted != spool.counts[Delete] {
+				return fmt.Errorf("%w: %d of %d deletes found their row", ErrPatchConflict, result.Deleted, spool.counts[Delete])
+			}
+		}
+
+		if spool.counts[Update] > 0 {
+			stage, err := stageRows(ctx, tx, table, spool.columns, spool.files[Update])
+			if err != nil {
+				return err
+			}
+			var set []string
+			for _, c := range spool.columns {
+				if !slices.Contains(key, c) {
+					quoted := pgx.Identifier{c}.Sanitize()
+					set = append(set, fmt.Sprintf("%s = s.%s", quoted, quoted))
+				}
+			}
+			if len(set) == 0 {
+				return errors.New("diff: patch updates have no non-key columns")
+			}
+			res, err := tx.ExecContext(ctx, fmt.Sprintf("UPDATE %s t SET %s FROM %s s WHERE %s",
+				pgx.Identifier(strings.Split(table, ".")).Sanitize(), strings.Join(set, ", "), stage, joinKey(key)))
+			if err != nil {
+				return fmt.Errorf("diff: applying updates failed: %w", err)
+			}
+			if result.Updated, err = res.RowsAffected(); err != nil {
+				return err
+			}
+			if result.Updated != spool.counts[Update] {
+				return fmt.Errorf("%w: %d of %d updates found their row", ErrPatchConflict, result.Updated, spool.counts[Update])
+			}
+		}
+
+		if spool.counts[Insert] > 0 {
+			result.Inserted, err = pgxraw.CopyFromNDJSON(ctx, tx, table, spool.files[Insert], pgxraw.NDJSONOptions{Columns: spool.columns})
+			if err != nil {
+				return fmt.Errorf("diff: applying inserts failed: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return ApplyResult{}, err
+	}
+	return result, nil
+}
+
+// spooledPatch is a patch split into one NDJSON file of rows per operation.
+type spooledPatch struct {
+	files  map[Op]*os.File
+	counts map[Op]int64
+	// columns are the columns of the insert and update rows.
+	columns []string
+}
+
+// spoolPatch reads patch and writes the rows of each operation to their own
+// file in files, rewound for reading.
+func spoolPatch(files *tempfiles.Manager, patch io.Reader) (*spooledPatch, error) {
+	spool := &spooledPatch{files: make(map[Op]*os.File), counts: make(map[Op]int64)}
+	writers := make(map[Op]*bufio.Writer)
+	for _, op := range []Op{Insert, Update, Delete} {
+		f, err := files.Create(string(op) + "-*.ndjson")
+		if err != nil {
+			spool.close()
+			return nil, err
+		}
+		spool.files[op] = f
+		writers[op] = bufio.NewWriter(f)
+	}
+
+	decoder := json.NewDecoder(patch)
+	for line := 1; ; line++ {
+		var entry PatchEntry
+		err := decoder.Decode(&entry)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			spool.close()
+			return nil, fmt.Errorf("diff: patch entry %d: %w", line, err)
+		}
+		w, ok := writers[entry.Op]
+		if !ok {
+			spool.close()
+			return nil, fmt.Errorf("diff: patch entry %d: unknown op %q", line, entry.Op)
+		}
+		if entry.Op != Delete && spool.columns == nil {
+			var row map[string]json.RawMessage
+			if err := json.Unmarshal(entry.Row, &row); err != nil {
+				spool.close()
+				return nil, fmt.Errorf("diff: patch entry %d: %w", line, err)
+			}
+			for c := range row {
+				spool.columns = append(spool.columns, c)
+			}
+			slices.Sort(spool.columns)
+		}
+		w.Write(entry.Row)
+		w.WriteByte('\n')
+		spool.counts[entry.Op]++
+	}
+
+	for op, w := range writers {
+		if err := w.Flush(); err != nil {
+			spool.close()
+			return nil, fmt.Errorf("diff: spooling patch failed: %w", err)
+		}
+		if _, err := spool.files[op].Seek(0, io.SeekStart); err != nil {
+			spool.close()
+			return nil, fmt.Errorf("diff: spooling patch failed: %w", err)
+		}
+	}
+	return spool, nil
+}
+
+func (s *spooledPatch) close() {
+	for _, f := range s.files {
+		f.Close()
+	}
+}
+
+// stagingSeq makes staging table names unique within a session.
+var stagingSeq atomic.Int64
+
+// stageRows creates a temporary table with columns of table and loads the
+// NDJSON rows of r into it. It returns the staging table's name.
+func stageRows(ctx context.Context, tx *txraw.Tx, table string, columns []string, r io.Reader) (string, error) {
+	stage := fmt.Sprintf("txraw_patch_%d", stagingSeq.Add(1))
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = pgx.Identifier{c}.Sanitize()
+	}
+	_, err := tx.ExecContext(ctx, fmt.Sprintf("CREATE TEMP TABLE %s ON COMMIT DROP AS SELECT %s FROM %s WITH NO DATA",
+		stage, strings.Join(quoted, ", "), pgx.Identifier(strings.Split(table, ".")).Sanitize()))
+	if err != nil {
+		return "", fmt.Errorf("diff: creating staging table failed: %w", err)
+	}
+	if _, err := pgxraw.CopyFromNDJSON(ctx, tx, stage, r, pgxraw.NDJSONOptions{Columns: columns}); err != nil {
+		return "", fmt.Errorf("diff: staging patch rows failed: %w", err)
+	}
+	return stage, nil
+}
+
+// joinKey returns the condition matching t and s on key.
+func joinKey(key []string) string {
+	conditions := make([]string, len(key))
+	for i, k := range key {
+		quoted := pgx.Identifier{k}.Sanitize()
+		conditions[i] = fmt.Sprintf("t.%s = s.%s", quoted, quoted)
+	}
+	return strings.Join(conditions, " AND ")
+}
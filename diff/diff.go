@@ -0,0 +1,363 @@
+// Package diff compares two PostgreSQL tables, in the same or different
+// databases, by primary key and row hash (see package rowhash), and can write the differences as a
+// patch that makes the target equal to the source.
+//
+// Both tables are streamed out with COPY TO, sorted by key, and merged, so
+// neither is held in memory and no rows are compared on the server across
+// databases.
+package diff
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/eqld/example-tx-raw/pgxraw"
+	"github.com/eqld/example-tx-raw/rowhash"
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// Side is one of the two tables being compared.
+type Side struct {
+	DB    *sql.DB
+	Table string
+}
+
+// Options configures Tables.
+type Options struct {
+	// Key are the columns identifying a row. If empty, the primary key of
+	// the source table is used.
+	Key []string
+	// Columns are the columns compared. If empty, all columns of the source
+	// table are compared.
+	Columns []string
+	// Patch, if set, receives one PatchEntry per difference as NDJSON.
+	Patch io.Writer
+	// Hash is the algorithm rows are compared by. Empty means
+	// rowhash.Default. Algorithms the server has built in are computed on
+	// the server; others on the client, as each row's text form streams in.
+	Hash rowhash.Algorithm
+}
+
+// Op is the kind of change a PatchEntry describes.
+type Op string
+
+const (
+	// Insert adds a row that is only in the source.
+	Insert Op = "insert"
+	// Update overwrites a row whose compared columns differ.
+	Update Op = "update"
+	// Delete removes a row that is only in the target.
+	Delete Op = "delete"
+)
+
+// PatchEntry is one line of a patch.
+type PatchEntry struct {
+	Op Op `json:"op"`
+	// Row is the source row as a JSON object of its key and compared
+	// columns for Insert and Update, and of the key columns only for Delete.
+	Row json.RawMessage `json:"row"`
+}
+
+// Report counts the differences found by Tables.
+type Report struct {
+	// Key and Columns are the columns the comparison used.
+	Key     []string
+	Columns []string
+	// Hash is the algorithm the rows were compared by.
+	Hash rowhash.Algorithm
+
+	Added     int64 // only in the source
+	Removed   int64 // only in the target
+	Changed   int64 // in both, with different values
+	Unchanged int64
+}
+
+// Equal reports whether the tables had no differences.
+func (r Report) Equal() bool {
+	return r.Added == 0 && r.Removed == 0 && r.Changed == 0
+}
+
+// Tables compares target against source. Each side is exported in its own
+// read-only REPEATABLE READ transaction, so both are consistent snapshots,
+// and the exports run concurrently.
+//
+// Rows are matched by the text form of their key and compared by a hash
+// (opts.Hash) of the text form of their compared columns; the column types on
+// both sides must therefore print alike.
+func Tables(ctx context.Context, source, target Side, opts Options) (Report, error) {
+	var report Report
+	var err error
+	report.Key, report.Columns, err = resolveColumns(ctx, source, opts)
+	if err != nil {
+		return report, err
+	}
+
+	report.Hash = opts.Hash
+	if report.Hash == "" {
+		report.Hash = rowhash.Default
+	}
+	if _, err := rowhash.New(report.Hash); err != nil {
+		return report, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	withRows := opts.Patch != nil
+	src := export(ctx, source, report.Key, report.Columns, report.Hash, withRows)
+	dst := export(ctx, target, report.Key, report.Columns, report.Hash, withRows)
+	defer src.close()
+	defer dst.close()
+
+	var buffered *bufio.Writer
+	var patch *json.Encoder
+	if withRows {
+		buffered = bufio.NewWriter(opts.Patch)
+		patch = json.NewEncoder(buffered)
+	}
+	emit := func(op Op, row string) error {
+		if patch == nil {
+			return nil
+		}
+		if op == Delete {
+			var err error
+			if row, err = keyObject(row, report.Key); err != nil {
+				return err
+			}
+		}
+		if err := patch.Encode(PatchEntry{Op: op, Row: json.RawMessage(row)}); err != nil {
+			return fmt.Errorf("diff: writing patch failed: %w", err)
+		}
+		return nil
+	}
+
+	a, aErr := src.next()
+	b, bErr := dst.next()
+	for aErr == nil && bErr == nil && (a != nil || b != nil) {
+		switch {
+		case b == nil || (a != nil && a.key < b.key):
+			report.Added++
+			err = emit(Insert, a.row)
+			a, aErr = src.next()
+		case a == nil || b.key < a.key:
+			report.Removed++
+			err = emit(Delete, b.row)
+			b, bErr = dst.next()
+		default:
+			if a.hash == b.hash {
+				report.Unchanged++
+			} else {
+				report.Changed++
+				err = emit(Update, a.row)
+			}
+			a, aErr = src.next()
+			b, bErr = dst.next()
+		}
+		if err != nil {
+			return report, err
+		}
+	}
+	if aErr != nil {
+		return report, fmt.Errorf("diff: source %s: %w", source.Table, aErr)
+	}
+	if bErr != nil {
+		return report, fmt.Errorf("diff: target %s: %w", target.Table, bErr)
+	}
+	if err := src.wait(); err != nil {
+		return report, fmt.Errorf("diff: source %s: %w", source.Table, err)
+	}
+	if err := dst.wait(); err != nil {
+		return report, fmt.Errorf("diff: target %s: %w", target.Table, err)
+	}
+	if buffered != nil {
+		if err := buffered.Flush(); err != nil {
+			return report, fmt.Errorf("diff: writing patch failed: %w", err)
+		}
+	}
+	return report, nil
+}
+
+// resolveColumns fills in the key and compared columns from the source
+// table's catalog where opts leaves them empty.
+func resolveColumns(ctx context.Context, source Side, opts Options) (key, columns []string, err error) {
+	key, columns = opts.Key, opts.Columns
+	if len(key) == 0 {
+		if key, err = primaryKey(ctx, source); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(columns) == 0 {
+		columns, err = queryNames(ctx, source.DB, `
+			SELECT attname FROM pg_attribute
+			WHERE attrelid = $1::regclass AND attnum > 0 AND NOT attisdropped
+			ORDER BY attnum`, source.Table)
+		if err != nil {
+			return nil, nil, fmt.Errorf("diff: reading columns of %s failed: %w", source.Table, err)
+		}
+	}
+	return key, columns, nil
+}
+
+// primaryKey returns the primary key columns of side's table.
+func primaryKey(ctx context.Context, side Side) ([]string, error) {
+	key, err := queryNames(ctx, side.DB, `
+		SELECT a.attname
+		FROM pg_index i
+		JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+		WHERE i.indrelid = $1::regclass AND i.indisprimary
+		ORDER BY array_position(i.indkey, a.attnum)`, side.Table)
+	if err != nil {
+		return nil, fmt.Errorf("diff: reading primary key of %s failed: %w", side.Table, err)
+	}
+	if len(key) == 0 {
+		return nil, fmt.Errorf("diff: %s has no primary key; set the key explicitly", side.Table)
+	}
+	return key, nil
+}
+
+func queryNames(ctx context.Context, db *sql.DB, query string, args ...any) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// keyObject reduces the JSON object row to its key columns.
+func keyObject(row string, key []string) (string, error) {
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(row), &all); err != nil {
+		return "", fmt.Errorf("diff: decoding row failed: %w", err)
+	}
+	keys := make(map[string]json.RawMessage, len(key))
+	for _, k := range key {
+		keys[k] = all[k]
+	}
+	b, err := json.Marshal(keys)
+	return string(b), err
+}
+
+// exportedRow is one row of an export: its sort key, hash and, if
+// requested, its key and compared columns as a JSON object.
+type exportedRow struct {
+	key, hash, row string
+}
+
+// exportStream reads one side's export as it arrives.
+type exportStream struct {
+	reader *csv.Reader
+	pr     *io.PipeReader
+	done   chan error
+	header bool // whether the CSV header line has been skipped
+	// hasher, if set, hashes the exported text form of each row on the
+	// client, for algorithms the server does not have.
+	hasher *rowhash.RowHasher
+}
+
+// export starts streaming side's rows, sorted by key, as CSV from COPY TO.
+// alg must be registered.
+func export(ctx context.Context, side Side, key, columns []string, alg rowhash.Algorithm, withRows bool) *exportStream {
+	pr, pw := io.Pipe()
+	s := &exportStream{reader: csv.NewReader(pr), pr: pr, done: make(chan error, 1)}
+	s.reader.ReuseRecord = true
+
+	rowText := fmt.Sprintf("row(%s)::text", quoteAll(columns))
+	hashExpr, onServer := rowhash.ServerExpr(alg, rowText)
+	if !onServer {
+		hashExpr = rowText
+		s.hasher, _ = rowhash.NewRowHasher(alg)
+	}
+
+	rowExpr := "NULL"
+	if withRows {
+		// The patch row carries the key even if it is not compared.
+		var pairs []string
+		for _, c := range slices.Concat(key, columns) {
+			pair := fmt.Sprintf("'%s', %s", strings.ReplaceAll(c, "'", "''"), quote(c))
+			if !slices.Contains(pairs, pair) {
+				pairs = append(pairs, pair)
+			}
+		}
+		rowExpr = fmt.Sprintf("json_build_object(%s)::text", strings.Join(pairs, ", "))
+	}
+	// Ordering by the key's text form in the C collation makes the server's
+	// order match Go's byte-wise string comparison.
+	query := fmt.Sprintf(`SELECT k, h, r FROM (
+		SELECT row(%s)::text AS k, %s AS h, %s AS r FROM %s
+	) s ORDER BY k COLLATE "C"`,
+		quoteAll(key), hashExpr, rowExpr, pgx.Identifier(strings.Split(side.Table, ".")).Sanitize())
+
+	go func() {
+		tx, err := txraw.Begin(ctx, side.DB, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+		if err == nil {
+			_, err = pgxraw.CopyTo(ctx, tx, query, pw, pgxraw.CSV)
+			_ = tx.Rollback()
+		}
+		pw.CloseWithError(err)
+		s.done <- err
+	}()
+	return s
+}
+
+// next returns the next row, or nil at the end of the export.
+func (s *exportStream) next() (*exportedRow, error) {
+	record, err := s.reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !s.header {
+		s.header = true
+		return s.next()
+	}
+	hash := record[1]
+	if s.hasher != nil {
+		s.hasher.Reset()
+		s.hasher.WriteString(hash)
+		hash = s.hasher.Sum()
+	}
+	return &exportedRow{key: record[0], hash: hash, row: record[2]}, nil
+}
+
+// wait waits for the export to finish and returns its error.
+func (s *exportStream) wait() error {
+	return <-s.done
+}
+
+// close stops reading; a still running export fails on the closed pipe.
+func (s *exportStream) close() {
+	s.pr.Close()
+}
+
+func quote(column string) string {
+	return pgx.Identifier{column}.Sanitize()
+}
+
+func quoteAll(columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = quote(c)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/eqld/example-tx-raw/diff"
+	"github.com/eqld/example-tx-raw/rowhash"
+)
+
+// runDiff compares two tables of the demo database, given as
+// "source,target", and with patchPath set writes the patch that would make
+// target equal to source there. Rows are compared with the hash alg.
+func runDiff(ctx context.Context, db *sql.DB, tables, patchPath string, alg rowhash.Algorithm) {
+	log.Println("--- Diff: comparing tables by primary key and row hash ---")
+
+	source, target, ok := strings.Cut(tables, ",")
+	if !ok {
+		log.Fatalf("-diff wants two tables as source,target, got %q", tables)
+	}
+
+	var patch io.Writer
+	if patchPath != "" {
+		f, err := os.Create(patchPath)
+		if err != nil {
+			log.Fatalf("Failed to create patch file: %v", err)
+		}
+		defer f.Close()
+		patch = f
+	}
+
+	report, err := diff.Tables(ctx, diff.Side{DB: db, Table: source}, diff.Side{DB: db, Table: target}, diff.Options{Patch: patch, Hash: alg})
+	if err != nil {
+		log.Printf("✗ Diff failed: %v", err)
+		return
+	}
+
+	log.Printf("Key %v, comparing %v by %s", report.Key, report.Columns, report.Hash)
+	log.Printf("✓ %s → %s: %d added, %d removed, %d changed, %d unchanged",
+		source, target, report.Added, report.Removed, report.Changed, report.Unchanged)
+	if report.Equal() {
+		log.Println("✓ Tables are equal")
+	}
+	if patchPath != "" {
+		log.Printf("✓ Patch written to %s", patchPath)
+	}
+	log.Println()
+}
+
+// runApplyPatch applies a patch written by runDiff, given as "file,table",
+// to a table of the demo database.
+func runApplyPatch(ctx context.Context, db *sql.DB, spec string) {
+	log.Println("--- Apply patch: syncing a table from diff output in one transaction ---")
+
+	path, table, ok := strings.Cut(spec, ",")
+	if !ok {
+		log.Fatalf("-apply-patch wants file,table, got %q", spec)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("Failed to open patch file: %v", err)
+	}
+	defer f.Close()
+
+	result, err := diff.Apply(ctx, db, table, f, diff.ApplyOptions{})
+	if errors.Is(err, diff.ErrPatchConflict) {
+		log.Printf("⚠️  %s has changed since the diff, nothing applied: %v", table, err)
+		return
+	}
+	if err != nil {
+		log.Printf("✗ Applying patch failed, nothing applied: %v", err)
+		return
+	}
+	log.Printf("✓ %s → %s: %d inserted, %d updated, %d deleted",
+		path, table, result.Inserted, result.Updated, result.Deleted)
+	log.Println()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/eqld/example-tx-raw/pgxraw"
+)
+
+// demonstrateDryRun validates a batch with a few bad rows against the items
+// table without writing anything, and shows that the table is unchanged.
+func demonstrateDryRun(ctx context.Context, db *sql.DB) scenarioResult {
+	log.Println("--- Extra scenario: Dry run validating rows without writing ---")
+
+	before, err := countRows(ctx, db)
+	if err != nil {
+		log.Fatalf("Failed to count rows: %v", err)
+	}
+
+	rows := generateSampleData(10, "DryRun")
+	rows[3][0] = nil                   // name is NOT NULL
+	rows[6] = []any{"DryRun Name 7"}   // missing data
+	rows[8][1] = struct{ X int }{X: 9} // not encodable as text
+	rows[1][0] = "Bogus Name 2"        // fails the validator below
+
+	validators := pgxraw.NewValidators(pgxraw.SkipInvalid).Add(func(row []any) error {
+		if name, ok := row[0].(string); ok && !strings.HasPrefix(name, "DryRun ") {
+			return fmt.Errorf("name %q lacks the batch prefix", name)
+		}
+		return nil
+	})
+
+	result, err := pgxraw.DryRun(ctx, db, tableName, []string{"name", "data"}, pgx.CopyFromRows(rows),
+		pgxraw.DryRunOptions{CopyToTemp: true, Validators: validators})
+	if err != nil {
+		log.Printf("✗ Dry run failed: %v", err)
+		log.Println()
+		return scenarioResult{err: err}
+	}
+
+	log.Printf("✓ %d rows read: %d valid, %d invalid", result.Rows, result.Valid, result.Invalid())
+	for _, e := range result.Errors {
+		log.Printf("  ✗ %v", e)
+	}
+	if result.CopyErr != nil {
+		log.Printf("  ✗ Server rejected the copy into the temporary table: %v", result.CopyErr)
+	} else {
+		log.Printf("✓ %d valid rows accepted by the server in a temporary table", result.Copied)
+	}
+
+	after, err := countRows(ctx, db)
+	if err != nil {
+		log.Fatalf("Failed to count rows: %v", err)
+	}
+	log.Printf("✓ Result: %d rows in %s before and %d after (Expected: unchanged)", before, tableName, after)
+	if after != before {
+		log.Printf("✗ ERROR: The dry run wrote to %s!", tableName)
+	}
+	log.Println()
+	return scenarioResult{loaded: int(result.Valid), persisted: after - before}
+}
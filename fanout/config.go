@@ -0,0 +1,188 @@
+package fanout
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// Config lists fan-out targets, typically decoded from a JSON file:
+//
+//	{
+//	  "table": "items",
+//	  "targets": [
+//	    {"name": "eu", "dsn": "postgres://eu-db/app", "user": "loader", "password": "...",
+//	     "tls": {"mode": "verify-full", "ca_file": "/etc/ssl/eu-ca.pem"}},
+//	    {"name": "us", "dsn": "postgres://us-db/app", "table": "items_us"}
+//	  ]
+//	}
+type Config struct {
+	// Table is the default target table.
+	Table string `json:"table"`
+	// Targets are the databases to load into.
+	Targets []TargetConfig `json:"targets"`
+}
+
+// TargetConfig describes one target. Credentials, TLS and Table override
+// whatever the DSN or the Config says for this target only.
+type TargetConfig struct {
+	Name     string    `json:"name"`
+	DSN      string    `json:"dsn"`
+	User     string    `json:"user,omitempty"`
+	Password string    `json:"password,omitempty"`
+	TLS      TLSConfig `json:"tls,omitempty"`
+	Table    string    `json:"table,omitempty"`
+}
+
+// TLSConfig overrides the TLS settings of a target. Mode takes the libpq
+// sslmode values; files are PEM encoded.
+type TLSConfig struct {
+	Mode     string `json:"mode,omitempty"`
+	CAFile   string `json:"ca_file,omitempty"`
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+}
+
+// LoadConfig decodes a JSON Config from r and validates it.
+func LoadConfig(r io.Reader) (Config, error) {
+	var cfg Config
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("fanout: decoding config failed: %w", err)
+	}
+	return cfg, cfg.Validate()
+}
+
+// Validate checks every target up front, including parsing its DSN and
+// loading its TLS certificates, so that a typo in one regional target fails
+// at startup rather than at first use.
+func (c Config) Validate() error {
+	if len(c.Targets) == 0 {
+		return errors.New("fanout: config has no targets")
+	}
+
+	var errs []error
+	seen := make(map[string]bool)
+	for i, t := range c.Targets {
+		if t.Name == "" {
+			errs = append(errs, fmt.Errorf("target #%d: name is required", i+1))
+			continue
+		}
+		if seen[t.Name] {
+			errs = append(errs, fmt.Errorf("target %s: duplicate name", t.Name))
+		}
+		seen[t.Name] = true
+
+		if t.Table == "" && c.Table == "" {
+			errs = append(errs, fmt.Errorf("target %s: no table and no default table", t.Name))
+		}
+		if _, err := t.connConfig(); err != nil {
+			errs = append(errs, fmt.Errorf("target %s: %w", t.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("fanout: invalid config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// Open validates c and opens a pool for every target. Pools connect lazily;
+// close them with Close when done.
+func (c Config) Open() ([]Target, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	targets := make([]Target, 0, len(c.Targets))
+	for _, t := range c.Targets {
+		connConfig, err := t.connConfig()
+		if err != nil {
+			return nil, fmt.Errorf("fanout: target %s: %w", t.Name, err)
+		}
+
+		table := t.Table
+		if table == "" {
+			table = c.Table
+		}
+		targets = append(targets, Target{
+			Name:  t.Name,
+			DB:    stdlib.OpenDB(*connConfig),
+			Table: table,
+		})
+	}
+	return targets, nil
+}
+
+// Close closes the pools of targets.
+func Close(targets []Target) error {
+	var errs []error
+	for _, t := range targets {
+		if err := t.DB.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("target %s: %w", t.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// connConfig parses the DSN and applies the target's overrides.
+func (t TargetConfig) connConfig() (*pgx.ConnConfig, error) {
+	if t.DSN == "" {
+		return nil, errors.New("dsn is required")
+	}
+	cfg, err := pgx.ParseConfig(t.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("parsing dsn failed: %w", err)
+	}
+
+	if t.User != "" {
+		cfg.User = t.User
+	}
+	if t.Password != "" {
+		cfg.Password = t.Password
+	}
+
+	if t.TLS != (TLSConfig{}) {
+		// Let pgx build the tls.Config from libpq-style settings, which also
+		// reads and checks the certificate files right here.
+		settings := []string{"host=" + quote(cfg.Host)}
+		for key, value := range map[string]string{
+			"sslmode":     t.TLS.Mode,
+			"sslrootcert": t.TLS.CAFile,
+			"sslcert":     t.TLS.CertFile,
+			"sslkey":      t.TLS.KeyFile,
+		} {
+			if value != "" {
+				settings = append(settings, key+"="+quote(value))
+			}
+		}
+		tlsCfg, err := pgx.ParseConfig(strings.Join(settings, " "))
+		if err != nil {
+			return nil, fmt.Errorf("invalid tls settings: %w", err)
+		}
+		cfg.TLSConfig = tlsCfg.TLSConfig
+		cfg.Fallbacks = nil
+		for _, fb := range tlsCfg.Fallbacks {
+			// sslmode=prefer and allow fall back between TLS and plain.
+			cfg.Fallbacks = append(cfg.Fallbacks, &pgconn.FallbackConfig{
+				Host:      cfg.Host,
+				Port:      cfg.Port,
+				TLSConfig: fb.TLSConfig,
+			})
+		}
+	}
+	return cfg, nil
+}
+
+// quote quotes a value for a keyword/value connection string.
+func quote(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
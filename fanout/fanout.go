@@ -0,0 +1,165 @@
+// Package fanout loads the same dataset into several target databases (e.g.
+// regional clusters), each in its own transaction, and reports per-target
+// results under an overall success policy.
+package fanout
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// Policy decides when a fan-out load as a whole succeeds.
+type Policy int
+
+const (
+	// AllMustSucceed commits only if the load succeeded on every target;
+	// otherwise every transaction is rolled back. Commits are issued only
+	// after all loads have finished, but they are not atomic across targets:
+	// a commit failing after others went through is reported as
+	// ErrPartialCommit.
+	AllMustSucceed Policy = iota
+	// BestEffort commits every target whose load succeeded, independently of
+	// the others. The run fails only if no target committed.
+	BestEffort
+)
+
+func (p Policy) String() string {
+	switch p {
+	case AllMustSucceed:
+		return "all-must-succeed"
+	case BestEffort:
+		return "best-effort"
+	default:
+		return fmt.Sprintf("Policy(%d)", int(p))
+	}
+}
+
+// ErrPartialCommit is returned under AllMustSucceed when some targets
+// committed and at least one commit then failed.
+var ErrPartialCommit = errors.New("fanout: commit failed on some targets after others committed")
+
+// Target is one destination database.
+type Target struct {
+	// Name identifies the target in results and errors.
+	Name string
+	// DB is the pool to load through.
+	DB *sql.DB
+	// Table is the table to load into on this target, if configured.
+	Table string
+}
+
+// LoadFunc loads the dataset into one target inside tx.
+type LoadFunc func(ctx context.Context, target Target, tx *txraw.Tx) error
+
+// Result is the outcome for one target.
+type Result struct {
+	Target    string
+	Committed bool
+	Err       error
+	Duration  time.Duration
+}
+
+// Load runs load against every target concurrently, each in its own
+// transaction, and commits according to policy. Results are returned in the
+// order of targets, together with an error summarizing the failures that made
+// the run as a whole fail.
+func Load(ctx context.Context, targets []Target, policy Policy, load LoadFunc) ([]Result, error) {
+	results := make([]Result, len(targets))
+	txs := make([]*txraw.Tx, len(targets))
+
+	// Phase 1: load every target concurrently. Under BestEffort each target
+	// also commits right away.
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			start := time.Now()
+			results[i].Target = target.Name
+			defer func() { results[i].Duration = time.Since(start) }()
+
+			tx, err := txraw.Begin(ctx, target.DB, nil)
+			if err != nil {
+				results[i].Err = err
+				return
+			}
+			if err := load(ctx, target, tx); err != nil {
+				results[i].Err = err
+				_ = tx.Rollback()
+				return
+			}
+
+			if policy == BestEffort {
+				results[i].Err = tx.Commit()
+				results[i].Committed = results[i].Err == nil
+				return
+			}
+			txs[i] = tx
+		}()
+	}
+	wg.Wait()
+
+	if policy == BestEffort {
+		for _, r := range results {
+			if r.Committed {
+				return results, nil
+			}
+		}
+		return results, failures(results, "no target committed")
+	}
+
+	// Phase 2 (AllMustSucceed): commit everything only if every load
+	// succeeded, otherwise roll back the ones still open.
+	allLoaded := true
+	for _, r := range results {
+		if r.Err != nil {
+			allLoaded = false
+			break
+		}
+	}
+	if !allLoaded {
+		for _, tx := range txs {
+			if tx != nil {
+				_ = tx.Rollback()
+			}
+		}
+		return results, failures(results, "load failed, all targets rolled back")
+	}
+
+	committed, failed := 0, 0
+	for i, tx := range txs {
+		if err := tx.Commit(); err != nil {
+			results[i].Err = err
+			failed++
+			continue
+		}
+		results[i].Committed = true
+		committed++
+	}
+	switch {
+	case failed == 0:
+		return results, nil
+	case committed > 0:
+		return results, fmt.Errorf("%w: %w", ErrPartialCommit, failures(results, "commit failed"))
+	default:
+		return results, failures(results, "commit failed on all targets")
+	}
+}
+
+// failures joins the per-target errors of results under a summary.
+func failures(results []Result, summary string) error {
+	var errs []error
+	for _, r := range results {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("target %s: %w", r.Target, r.Err))
+		}
+	}
+	return fmt.Errorf("fanout: %s: %w", summary, errors.Join(errs...))
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// pgxModulePath is the module whose version is reported in the fingerprint.
+const pgxModulePath = "github.com/jackc/pgx/v5"
+
+// fingerprint describes the driver and server combination in use.
+type fingerprint struct {
+	// PgxVersion is the pgx module version from the build info, e.g. "v5.7.5",
+	// or "" when it cannot be determined (e.g. in a test binary).
+	PgxVersion string
+	// ServerVersionNum is the server_version_num setting, e.g. 150004.
+	ServerVersionNum int
+	// Port is the port from the connection settings.
+	Port string
+}
+
+// knownIssue is a problematic driver/server combination worth warning about.
+type knownIssue struct {
+	match   func(fp fingerprint) bool
+	warning string
+}
+
+// knownIssues is checked at connect time. Keep entries short and actionable.
+var knownIssues = []knownIssue{
+	{
+		match: func(fp fingerprint) bool {
+			return fp.PgxVersion != "" && compareVersions(fp.PgxVersion, "v5.5.4") < 0
+		},
+		warning: "pgx versions before v5.5.4 are affected by CVE-2024-27289 and CVE-2024-27304; upgrade pgx",
+	},
+	{
+		match: func(fp fingerprint) bool {
+			return fp.ServerVersionNum > 0 && fp.ServerVersionNum < 120000
+		},
+		warning: "pgx v5 is only tested against PostgreSQL 12 and newer",
+	},
+	{
+		match: func(fp fingerprint) bool {
+			return fp.ServerVersionNum > 0 && fp.ServerVersionNum < 140000
+		},
+		warning: "PostgreSQL before 14 has no pg_stat_progress_copy; server-side COPY progress is unavailable",
+	},
+	{
+		// 6432 is PgBouncer's default port. In transaction pooling mode the
+		// statement cache pgx uses by default breaks with errors such as
+		// "prepared statement ... already exists".
+		match: func(fp fingerprint) bool {
+			return fp.Port == "6432"
+		},
+		warning: "connection looks like it goes through PgBouncer; with transaction pooling set default_query_exec_mode=simple_protocol or exec",
+	},
+}
+
+// takeFingerprint collects the pgx version from the build info and the server
+// version from the database.
+func takeFingerprint(ctx context.Context, db *sql.DB, port string) (fingerprint, error) {
+	fp := fingerprint{Port: port}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path == pgxModulePath {
+				fp.PgxVersion = dep.Version
+				break
+			}
+		}
+	}
+
+	var versionNum string
+	if err := db.QueryRowContext(ctx, "SHOW server_version_num").Scan(&versionNum); err != nil {
+		return fp, fmt.Errorf("SHOW server_version_num failed: %w", err)
+	}
+	n, err := strconv.Atoi(versionNum)
+	if err != nil {
+		return fp, fmt.Errorf("unexpected server_version_num %q: %w", versionNum, err)
+	}
+	fp.ServerVersionNum = n
+
+	return fp, nil
+}
+
+// warnKnownIssues logs a warning for every known issue matching fp.
+func warnKnownIssues(fp fingerprint) {
+	for _, issue := range knownIssues {
+		if issue.match(fp) {
+			log.Printf("⚠️  %s", issue.warning)
+		}
+	}
+}
+
+// compareVersions compares two "vMAJOR.MINOR.PATCH" versions numerically,
+// ignoring any pre-release or build suffix. It returns -1, 0 or +1.
+func compareVersions(a, b string) int {
+	pa, pb := versionParts(a), versionParts(b)
+	for i := range pa {
+		if pa[i] != pb[i] {
+			if pa[i] < pb[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// versionParts parses "v1.2.3-pre" into [1 2 3]. Missing or malformed parts
+// are treated as zero.
+func versionParts(v string) [3]int {
+	var parts [3]int
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	for i, s := range strings.SplitN(v, ".", 3) {
+		parts[i], _ = strconv.Atoi(s)
+	}
+	return parts
+}
@@ -14,3 +14,55 @@ CREATE TABLE items (
 
 GRANT ALL PRIVILEGES ON TABLE items TO exampleuser;
 GRANT USAGE, SELECT ON SEQUENCE items_id_seq TO exampleuser;
+
+-- Structured summary of every load, written by the runlog package (-record-runs)
+CREATE TABLE txraw_runs (
+    id             BIGSERIAL PRIMARY KEY,
+    job            TEXT NOT NULL DEFAULT '',
+    trace          TEXT NOT NULL DEFAULT '',
+    tenant         TEXT NOT NULL DEFAULT '',
+    scenario       TEXT NOT NULL,
+    strategy       TEXT NOT NULL,
+    rows_loaded    BIGINT NOT NULL DEFAULT 0,
+    rows_persisted BIGINT NOT NULL DEFAULT 0,
+    bytes_sent     BIGINT NOT NULL DEFAULT 0,
+    started_at     TIMESTAMPTZ NOT NULL,
+    duration       INTERVAL NOT NULL,
+    error          TEXT
+);
+
+GRANT ALL PRIVILEGES ON TABLE txraw_runs TO exampleuser;
+GRANT USAGE, SELECT ON SEQUENCE txraw_runs_id_seq TO exampleuser;
+
+-- Target of the resumable scenario; kept across runs so -resume can continue
+CREATE TABLE items_resumable (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    data TEXT
+);
+
+GRANT ALL PRIVILEGES ON TABLE items_resumable TO exampleuser;
+GRANT USAGE, SELECT ON SEQUENCE items_resumable_id_seq TO exampleuser;
+
+-- Target of the type-mapping scenario (-typed)
+CREATE TABLE items_typed (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    tags TEXT[],
+    attrs JSONB,
+    seen_at TIMESTAMPTZ,
+    note TEXT,
+    ref TEXT
+);
+
+GRANT ALL PRIVILEGES ON TABLE items_typed TO exampleuser;
+GRANT USAGE, SELECT ON SEQUENCE items_typed_id_seq TO exampleuser;
+
+-- Progress of resumable loads, written by the checkpoint package
+CREATE TABLE txraw_checkpoints (
+    key        TEXT PRIMARY KEY,
+    rows_done  BIGINT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+GRANT ALL PRIVILEGES ON TABLE txraw_checkpoints TO exampleuser;
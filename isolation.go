@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// isolationRows is the number of rows copied under each isolation level.
+const isolationRows = 5
+
+// demonstrateIsolationLevels runs the transactional CopyFrom under READ
+// COMMITTED, REPEATABLE READ and SERIALIZABLE. While each transaction is
+// open, a row is committed from outside; whether the transaction sees it
+// shows the level's snapshot. A read-only transaction then refuses the same
+// CopyFrom up front.
+func demonstrateIsolationLevels(ctx context.Context, db, verifyDB *sql.DB) scenarioResult {
+	log.Println("--- Extra scenario: CopyFrom under each isolation level, and in a read-only transaction ---")
+
+	if err := clearTable(ctx, db); err != nil {
+		log.Fatalf("Failed to clear table: %v", err)
+	}
+
+	levels := []struct {
+		level        sql.IsolationLevel
+		seesOutsider bool
+	}{
+		{sql.LevelReadCommitted, true},
+		{sql.LevelRepeatableRead, false},
+		{sql.LevelSerializable, false},
+	}
+	loaded := 0
+	var failed error
+	for _, l := range levels {
+		sampleData := generateSampleData(isolationRows, fmt.Sprintf("Isolation %v", l.level))
+		loaded += len(sampleData)
+		err := copyUnderIsolation(ctx, db, l.level, l.seesOutsider, sampleData)
+		if err != nil {
+			log.Printf("✗ %v: %v", l.level, err)
+			failed = errors.Join(failed, err)
+		}
+	}
+
+	tx, err := txraw.Begin(ctx, db, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		log.Fatalf("Failed to begin read-only transaction: %v", err)
+	}
+	sampleData := generateSampleData(isolationRows, "ReadOnly")
+	err = txraw.RawWrite(ctx, tx, "CopyFrom into "+tableName, func(driverConn any) error {
+		_, err := performCopyFrom(ctx, driverConn, sampleData, "read-only")
+		return err
+	})
+	_ = tx.Rollback()
+	if errors.Is(err, txraw.ErrReadOnly) {
+		log.Printf("✓ Read-only transaction refused CopyFrom before sending any rows: %v", err)
+	} else {
+		log.Printf("✗ ERROR: Read-only transaction did not refuse CopyFrom: %v", err)
+		failed = errors.Join(failed, fmt.Errorf("read-only CopyFrom not refused: %v", err))
+	}
+
+	rowCount, err := countRows(ctx, verifyDB)
+	if err != nil {
+		log.Fatalf("Failed to count rows (isolation): %v", err)
+	}
+	expected := loaded + len(levels)
+	log.Printf("✓ Result: %d rows persisted (Expected: %d, copied plus one outside row per level)", rowCount, expected)
+	if rowCount != expected {
+		log.Printf("✗ ERROR: Row count mismatch after the isolation runs!")
+	}
+	log.Println()
+	return scenarioResult{loaded: loaded, persisted: rowCount, err: failed}
+}
+
+// copyUnderIsolation copies sampleData in a transaction at level, commits a
+// row from outside in the middle, and checks whether the transaction saw it.
+func copyUnderIsolation(ctx context.Context, db *sql.DB, level sql.IsolationLevel, seesOutsider bool, sampleData [][]any) error {
+	tx, err := txraw.Begin(ctx, db, &sql.TxOptions{Isolation: level})
+	if err != nil {
+		return err
+	}
+	// Not retried on conflicts: a retry would commit a second outside row.
+	err = func() error {
+		// The first statement takes the snapshot under REPEATABLE READ and
+		// SERIALIZABLE.
+		var setting string
+		if err := tx.QueryRowContext(ctx, "SELECT current_setting('transaction_isolation')").Scan(&setting); err != nil {
+			return err
+		}
+		before, err := countRows(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (name, data) VALUES ($1, 'committed outside')", tableName),
+			fmt.Sprintf("Outsider %v", level)); err != nil {
+			return fmt.Errorf("committing the outside row failed: %w", err)
+		}
+
+		err = txraw.RawWrite(ctx, tx, "CopyFrom into "+tableName, func(driverConn any) error {
+			_, err := performCopyFrom(ctx, driverConn, sampleData, setting)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		after, err := countRows(ctx, tx)
+		if err != nil {
+			return err
+		}
+		sawOutsider := after-before-len(sampleData) == 1
+		log.Printf("  %s: %d rows visible before the copy, %d after; outside row visible: %t", setting, before, after, sawOutsider)
+		if sawOutsider != seesOutsider {
+			return fmt.Errorf("outside row visible: %t, want %t", sawOutsider, seesOutsider)
+		}
+		return nil
+	}()
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -1,15 +1,34 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
-	"reflect"
+	"os"
+	"path/filepath"
 	"time"
 
+	mssql "github.com/denisenkom/go-mssqldb"
+	_ "github.com/go-sql-driver/mysql"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/stdlib"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	_ "modernc.org/sqlite"
+
+	"github.com/eqld/example-tx-raw/deadline"
+	"github.com/eqld/example-tx-raw/mssqlraw"
+	"github.com/eqld/example-tx-raw/mysqlraw"
+	"github.com/eqld/example-tx-raw/pgxraw"
+	"github.com/eqld/example-tx-raw/pqraw"
+	"github.com/eqld/example-tx-raw/rowhash"
+	"github.com/eqld/example-tx-raw/runctx"
+	"github.com/eqld/example-tx-raw/runlog"
+	"github.com/eqld/example-tx-raw/sqliteraw"
+	"github.com/eqld/example-tx-raw/txraw"
 )
 
 const (
@@ -19,67 +38,115 @@ const (
 	dbHost     = "localhost"
 	dbPort     = "54320"
 	tableName  = "items"
+
+	// verifyPoolSize caps the dedicated verification pool so it can never
+	// compete with the load path for more than a couple of connections.
+	verifyPoolSize = 2
+
+	// The temp-table merge scenario seeds mergeExisting rows and merges a
+	// batch of mergeBatch rows, the first mergeOverlap of which already exist.
+	mergeStagingTable = "items_staging"
+	mergeExisting     = 5
+	mergeBatch        = 6
+	mergeOverlap      = 3
 )
 
-// Tx is a type based on sql.Tx that provides a Raw() method using reflection.
-// This demonstrates the workaround currently needed to access the underlying
-// driver connection from within a transaction context.
-//
-// IMPORTANT: This reflection-based approach is fragile and depends on the
-// internal structure of sql.Tx, which could change between Go versions.
-// An official Tx.Raw() method in the standard library would eliminate
-// the need for this unsafe workaround.
-type Tx sql.Tx
-
-// Raw executes the provided function with access to the underlying driver connection.
-// This method uses reflection to access unexported fields of sql.Tx, which is
-// necessary because sql.Tx doesn't provide a Raw() method like sql.Conn does.
-//
-// The reflection process:
-// 1. Access sql.Tx.dc (driverConn) field
-// 2. Extract dc.ci (driver.Conn interface)
-// 3. Execute the callback with the driver connection
-//
-// This approach is fragile because:
-// - It depends on internal Go standard library structure
-// - Field names and types could change between Go versions
-// - It bypasses Go's type safety and encapsulation
-func (tx *Tx) Raw(f func(driverConn any) error) (err error) {
-	// Use reflection to access `tx.dc` (`driverConn`).
-	txValue := reflect.ValueOf((*sql.Tx)(tx)).Elem()
+// errDemoRollback is returned from the rollback scenario's transaction
+// function to make txraw.WithTx roll back.
+var errDemoRollback = errors.New("intentional rollback")
 
-	dcField := txValue.FieldByName("dc")
-	if !dcField.IsValid() {
-		return fmt.Errorf("cannot access dc field from transaction")
-	}
+var verifyPool = flag.Bool("verify-pool", false,
+	"run verification and count queries on a separate, small read-only connection pool")
 
-	// Make the field accessible and get the `driverConn` pointer.
-	dcField = reflect.NewAt(dcField.Type(), dcField.Addr().UnsafePointer()).Elem()
-	dc := dcField.Interface()
-	dcValue := reflect.ValueOf(dc).Elem()
+var libPQ = flag.Bool("libpq", false,
+	"also run the transactional bulk insert through the lib/pq driver")
 
-	// Access `dc.ci` (`driver.Conn` interface).
-	ciField := dcValue.FieldByName("ci")
-	if !ciField.IsValid() {
-		return fmt.Errorf("cannot access ci field from `driverConn`")
-	}
+var mysqlDSN = flag.String("mysql-dsn", "",
+	"also run the transactional bulk insert against this MySQL server with LOAD DATA LOCAL INFILE")
+
+var mssqlDSN = flag.String("mssql-dsn", "",
+	"also run the transactional bulk insert against this SQL Server with TDS bulk copy")
+
+var sqliteDriver = flag.String("sqlite-driver", "",
+	`also run a SQLite scenario with this driver: "sqlite" (modernc, pure Go) or "sqlite3" (mattn, cgo)`)
+
+var noWait = flag.Bool("no-wait", false,
+	"fail immediately instead of waiting when another instance is loading into the table")
+
+var comparePool = flag.Bool("pgxpool", false,
+	"also run the scenarios on a native pgxpool and compare transactional CopyFrom timings")
+
+var sanityCheck = flag.Bool("sanity-check", false,
+	"also copy the same rows through sql.Conn.Raw and Tx.Raw and compare both paths")
+
+var diffTables = flag.String("diff", "",
+	`compare two tables as "source,target" by primary key and row hash after the scenarios`)
+
+var hashAlgorithm = flag.String("hash", string(rowhash.Default),
+	"hash algorithm for -sanity-check row checksums and -diff row comparison: md5, sha256, fnv1a-64 or xxhash64")
+
+var patchFile = flag.String("patch", "",
+	"with -diff, write the patch that makes the target equal to the source to this file")
+
+var applyPatch = flag.String("apply-patch", "",
+	`apply a patch written by -diff as "file,table" in one transaction, after any -diff`)
+
+var partitioned = flag.Bool("partitions", false,
+	"also load a partitioned table in parallel, one transaction per partition, with two-phase commit")
 
-	// Make the field accessible and get the underlying driver connection.
-	ciField = reflect.NewAt(ciField.Type(), ciField.Addr().UnsafePointer()).Elem()
-	ci := ciField.Interface()
+var dryRun = flag.Bool("dry-run", false,
+	"also validate a batch with bad rows against items without writing anything")
 
-	return f(ci)
+var typedRows = flag.Bool("typed", false,
+	"also copy rows with arrays, JSONB, time zones and NULLs through a pgxraw.TypeMap")
+
+var isolationLevels = flag.Bool("isolation", false,
+	"also run the transactional CopyFrom under each isolation level and in a read-only transaction")
+
+var resume = flag.Bool("resume", false,
+	"continue the resumable load from its last checkpoint instead of restarting it from zero")
+
+var recordRuns = flag.Bool("record-runs", false,
+	"record a summary of every PostgreSQL scenario in the txraw_runs table")
+
+// scenarioResult is what a scenario reports for its run summary.
+type scenarioResult struct {
+	loaded    int
+	persisted int
+	bytes     int64
+	err       error
+}
+
+// item mirrors a row of the items table for typed reads with
+// pgxraw.QueryStructs.
+type item struct {
+	ID   int     `db:"id"`
+	Name string  `db:"name"`
+	Data *string `db:"data"`
 }
 
 func main() {
+	flag.Parse()
+
 	log.Println("=== Go sql.Tx Raw Connection Access Example ===")
 	log.Println("This example demonstrates the need for an official Tx.Raw() method")
 	log.Println("in Go's database/sql package by showing pgx.CopyFrom usage scenarios.")
 	log.Println()
 
+	// Fail fast if this Go release changed the internals txraw relies on
+	if err := txraw.Verify(); err != nil {
+		log.Fatalf("txraw self-test failed, Tx.Raw() cannot work on this Go version: %v", err)
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 
+	// Label every transaction of this run; txraw.Begin puts the labels into
+	// application_name so the demo's sessions are visible in pg_stat_activity
+	labels := runctx.Labels{Job: "tx_raw_example", Trace: fmt.Sprintf("%x", time.Now().UnixNano())}
+	ctx = runctx.WithLabels(ctx, labels)
+	log.Printf("Run labels: %s", labels)
+
 	// Establish database connection
 	db, err := dbConnect(ctx)
 	if err != nil {
@@ -87,10 +154,118 @@ func main() {
 	}
 	defer db.Close()
 
-	// Run all three demonstration scenarios
-	demonstrateNoTransactionCopyFrom(ctx, db)
-	demonstrateTransactionCommitCopyFrom(ctx, db)
-	demonstrateTransactionRollbackCopyFrom(ctx, db)
+	// "demo <name>..." runs the API demos instead of the scenarios
+	if flag.Arg(0) == "demo" {
+		if failed := runDemos(ctx, db, flag.Args()[1:]); failed > 0 {
+			log.Fatalf("%d demo(s) failed", failed)
+		}
+		return
+	}
+
+	// Verification queries share the load pool unless a dedicated one is requested
+	verifyDB := db
+	if *verifyPool {
+		verifyDB, err = dbConnectVerify(ctx)
+		if err != nil {
+			log.Fatalf("Failed to open verification pool: %v", err)
+		}
+		defer verifyDB.Close()
+	}
+
+	// Keep a second instance from loading into the same table concurrently
+	unlock, err := pgxraw.LockTable(ctx, db, tableName, !*noWait)
+	if errors.Is(err, pgxraw.ErrLocked) {
+		log.Fatalf("Another instance is loading into %s; rerun without -no-wait to wait for it", tableName)
+	}
+	if err != nil {
+		log.Fatalf("Failed to take the load lock: %v", err)
+	}
+	defer func() {
+		if err := unlock(); err != nil {
+			log.Printf("⚠️  %v", err)
+		}
+	}()
+	log.Printf("✓ Holding the load lock on %s", tableName)
+
+	if txraw.HasOfficialRaw() {
+		log.Println("✓ This Go release provides sql.Tx.Raw(); txraw uses it instead of reflection")
+	} else {
+		log.Println("⚠️  No official sql.Tx.Raw() in this Go release; txraw falls back to reflection")
+	}
+	log.Println()
+
+	if *recordRuns {
+		if err := runlog.EnsureTable(ctx, db); err != nil {
+			log.Fatalf("Failed to prepare run history: %v", err)
+		}
+	}
+
+	// Run the four demonstration scenarios
+	runScenario(ctx, db, "no-transaction", "conn-raw-copy-from", func() scenarioResult {
+		return demonstrateNoTransactionCopyFrom(ctx, db, verifyDB)
+	})
+	runScenario(ctx, db, "transaction-commit", "tx-raw-copy-from", func() scenarioResult {
+		return demonstrateTransactionCommitCopyFrom(ctx, db, verifyDB)
+	})
+	runScenario(ctx, db, "transaction-rollback", "tx-raw-copy-from", func() scenarioResult {
+		return demonstrateTransactionRollbackCopyFrom(ctx, db, verifyDB)
+	})
+	runScenario(ctx, db, "temp-table-merge", "tx-raw-copy-from-on-commit-drop", func() scenarioResult {
+		return demonstrateTempTableMerge(ctx, db, verifyDB)
+	})
+
+	runScenario(ctx, db, "resumable-load", "chunked-copy-from-checkpoint", func() scenarioResult {
+		return demonstrateResumableLoad(ctx, db, *resume)
+	})
+
+	if *sanityCheck {
+		runScenario(ctx, db, "sanity-check", "conn-raw-vs-tx-raw", func() scenarioResult {
+			return demonstrateSanityCheck(ctx, db, rowhash.Algorithm(*hashAlgorithm))
+		})
+	}
+	if *dryRun {
+		runScenario(ctx, db, "dry-run", "validate-copy-to-temp", func() scenarioResult {
+			return demonstrateDryRun(ctx, db)
+		})
+	}
+	if *typedRows {
+		runScenario(ctx, db, "typed-rows", "type-map-copy-from", func() scenarioResult {
+			return demonstrateTypeMapping(ctx, db)
+		})
+	}
+	if *isolationLevels {
+		runScenario(ctx, db, "isolation-levels", "tx-raw-copy-from-isolation", func() scenarioResult {
+			return demonstrateIsolationLevels(ctx, db, verifyDB)
+		})
+	}
+	if *partitioned {
+		runScenario(ctx, db, "partitioned-load", "parallel-copy-from-2pc", func() scenarioResult {
+			return demonstratePartitionedLoad(ctx, db)
+		})
+	}
+	if *diffTables != "" {
+		runDiff(ctx, db, *diffTables, *patchFile, rowhash.Algorithm(*hashAlgorithm))
+	}
+	if *applyPatch != "" {
+		runApplyPatch(ctx, db, *applyPatch)
+	}
+	if *comparePool {
+		demonstratePgxPool(ctx, db)
+	}
+	if *libPQ {
+		runScenario(ctx, db, "libpq-commit", "pq-copy-in", func() scenarioResult {
+			return demonstrateLibPQCopyIn(ctx, verifyDB)
+		})
+	}
+	if *mysqlDSN != "" {
+		demonstrateMySQLLoadData(ctx, *mysqlDSN)
+	}
+	if *mssqlDSN != "" {
+		demonstrateMSSQLCopyIn(ctx, *mssqlDSN)
+	}
+	if *sqliteDriver != "" {
+		demonstrateSQLiteRaw(ctx, *sqliteDriver)
+	}
 
 	log.Println("\n=== Example Finished ===")
 	log.Println("Key observations:")
@@ -101,12 +276,39 @@ func main() {
 	log.Println("This example provides justification for adding Tx.Raw() to database/sql")
 }
 
+// runScenario times scenario and, with -record-runs, writes its result to
+// txraw_runs on db, outside of the scenario's own transactions.
+func runScenario(ctx context.Context, db *sql.DB, name, strategy string, scenario func() scenarioResult) {
+	startedAt := time.Now()
+	result := scenario()
+	if !*recordRuns {
+		return
+	}
+
+	id, err := runlog.Record(ctx, db, runlog.Entry{
+		Scenario:      name,
+		Strategy:      strategy,
+		RowsLoaded:    int64(result.loaded),
+		RowsPersisted: int64(result.persisted),
+		BytesSent:     result.bytes,
+		StartedAt:     startedAt,
+		Duration:      time.Since(startedAt),
+		Err:           result.err,
+	})
+	if err != nil {
+		log.Printf("⚠️  Failed to record run summary: %v", err)
+		return
+	}
+	log.Printf("✓ Run summary recorded in txraw_runs (id=%d)", id)
+	log.Println()
+}
+
 // demonstrateNoTransactionCopyFrom shows how pgx.CopyFrom works perfectly
 // in a non-transactional context using the official sql.Conn.Raw() method.
 //
 // This scenario works cleanly because sql.Conn provides a Raw() method
 // that allows safe access to the underlying driver connection.
-func demonstrateNoTransactionCopyFrom(ctx context.Context, db *sql.DB) {
+func demonstrateNoTransactionCopyFrom(ctx context.Context, db, verifyDB *sql.DB) scenarioResult {
 	log.Println("--- Scenario 1: CopyFrom WITHOUT transaction ---")
 	log.Println("Uses sql.Conn.Raw() - the official, safe way to access driver connection")
 
@@ -126,15 +328,17 @@ func demonstrateNoTransactionCopyFrom(ctx context.Context, db *sql.DB) {
 	defer sqlDBConn.Close()
 
 	// Use the official Raw() method - this is the clean, supported approach
+	var sent int64
 	err = sqlDBConn.Raw(func(driverConn any) error {
-		return performCopyFrom(ctx, driverConn, sampleData, "non-transactional")
+		sent, err = performCopyFrom(ctx, driverConn, sampleData, "non-transactional")
+		return err
 	})
 	if err != nil {
 		log.Fatalf("sqlDBConn.Raw failed: %v", err)
 	}
 
 	// Verify the results
-	rowCount, err := countRows(ctx, db)
+	rowCount, err := countRows(ctx, verifyDB)
 	if err != nil {
 		log.Fatalf("Failed to count rows (no-tx): %v", err)
 	}
@@ -144,6 +348,7 @@ func demonstrateNoTransactionCopyFrom(ctx context.Context, db *sql.DB) {
 		log.Printf("✗ ERROR: Row count mismatch for non-transactional CopyFrom!")
 	}
 	log.Println()
+	return scenarioResult{loaded: len(sampleData), persisted: rowCount, bytes: sent}
 }
 
 // demonstrateTransactionCommitCopyFrom shows how pgx.CopyFrom can be used
@@ -152,7 +357,7 @@ func demonstrateNoTransactionCopyFrom(ctx context.Context, db *sql.DB) {
 //
 // This scenario demonstrates the problem: we need unsafe reflection to
 // access the driver connection from within a transaction.
-func demonstrateTransactionCommitCopyFrom(ctx context.Context, db *sql.DB) {
+func demonstrateTransactionCommitCopyFrom(ctx context.Context, db, verifyDB *sql.DB) scenarioResult {
 	log.Println("--- Scenario 2: CopyFrom WITH transaction (COMMIT) ---")
 	log.Println("Uses reflection-based Tx.Raw() - demonstrates the current workaround")
 
@@ -164,37 +369,44 @@ func demonstrateTransactionCommitCopyFrom(ctx context.Context, db *sql.DB) {
 	sampleData := generateSampleData(15, "TxCommit")
 	log.Printf("Generated %d rows for transactional insertion (commit)", len(sampleData))
 
-	// Begin transaction
-	sqlTx, err := db.BeginTx(ctx, nil)
-	if err != nil {
-		log.Fatalf("Failed to begin transaction (commit scenario): %v", err)
-	}
-
-	// Wrap sql.Tx to add our reflection-based Raw() method
-	tx := (*Tx)(sqlTx)
-
-	// Use our reflection-based Raw() method - this is the problematic workaround
+	// Run the copy in a transaction that commits on success and rolls back otherwise
 	log.Println("⚠️  Using reflection to access transaction's driver connection...")
-	err = tx.Raw(func(driverConn any) error {
-		return performCopyFrom(ctx, driverConn, sampleData, "transactional (commit)")
-	})
+	var sent int64
+	err := txraw.WithTx(ctx, db, func(tx *txraw.Tx) error {
+		err := tx.RawContext(ctx, func(driverConn any) (err error) {
+			sent, err = performCopyFrom(ctx, driverConn, sampleData, "transactional (commit)")
+			return err
+		})
+		if err != nil {
+			return err
+		}
 
-	if err != nil {
-		log.Printf("✗ CopyFrom failed, rolling back: %v", err)
-		if rollbackErr := sqlTx.Rollback(); rollbackErr != nil {
-			log.Printf("✗ Rollback also failed: %v", rollbackErr)
+		// Read a few of the copied rows back through the same transaction
+		query := fmt.Sprintf("SELECT id, name, data FROM %s ORDER BY id LIMIT 3", tableName)
+		for it, err := range pgxraw.QueryStructs[item](ctx, tx, query) {
+			if err != nil {
+				return err
+			}
+			log.Printf("  read back inside transaction: id=%d name=%q", it.ID, it.Name)
 		}
-		return
-	}
 
-	// Commit the transaction
-	if err = sqlTx.Commit(); err != nil {
-		log.Fatalf("Failed to commit transaction: %v", err)
+		// Export the uncommitted rows as CSV from the same snapshot
+		var export bytes.Buffer
+		exported, err := pgxraw.CopyTo(ctx, tx, "TABLE "+tableName, &export, pgxraw.CSV)
+		if err != nil {
+			return err
+		}
+		log.Printf("  exported %d rows as CSV inside transaction (%d bytes)", exported, export.Len())
+		return nil
+	})
+	if err != nil {
+		log.Printf("✗ Transaction failed and was rolled back: %v", err)
+		return scenarioResult{loaded: len(sampleData), bytes: sent, err: err}
 	}
 	log.Println("✓ Transaction committed successfully")
 
 	// Verify the results
-	rowCount, err := countRows(ctx, db)
+	rowCount, err := countRows(ctx, verifyDB)
 	if err != nil {
 		log.Fatalf("Failed to count rows (tx-commit): %v", err)
 	}
@@ -204,6 +416,7 @@ func demonstrateTransactionCommitCopyFrom(ctx context.Context, db *sql.DB) {
 		log.Printf("✗ ERROR: Row count mismatch for transactional CopyFrom!")
 	}
 	log.Println()
+	return scenarioResult{loaded: len(sampleData), persisted: rowCount, bytes: sent}
 }
 
 // demonstrateTransactionRollbackCopyFrom shows how pgx.CopyFrom works within
@@ -211,7 +424,7 @@ func demonstrateTransactionCommitCopyFrom(ctx context.Context, db *sql.DB) {
 //
 // This scenario proves that the transactional semantics work correctly
 // even with the reflection-based approach, but highlights the fragility.
-func demonstrateTransactionRollbackCopyFrom(ctx context.Context, db *sql.DB) {
+func demonstrateTransactionRollbackCopyFrom(ctx context.Context, db, verifyDB *sql.DB) scenarioResult {
 	log.Println("--- Scenario 3: CopyFrom WITH transaction (ROLLBACK) ---")
 	log.Println("Uses reflection-based Tx.Raw() - demonstrates transaction rollback")
 
@@ -223,37 +436,37 @@ func demonstrateTransactionRollbackCopyFrom(ctx context.Context, db *sql.DB) {
 	sampleData := generateSampleData(20, "TxRollback")
 	log.Printf("Generated %d rows for transactional insertion (rollback)", len(sampleData))
 
-	// Begin transaction
-	sqlTx, err := db.BeginTx(ctx, nil)
-	if err != nil {
-		log.Fatalf("Failed to begin transaction (rollback scenario): %v", err)
-	}
-
-	// Wrap sql.Tx to add our reflection-based Raw() method
-	tx := (*Tx)(sqlTx)
-
-	// Use our reflection-based Raw() method
+	// Run the copy in a transaction, then fail on purpose so WithTx rolls it back
 	log.Println("⚠️  Using reflection to access transaction's driver connection...")
-	err = tx.Raw(func(driverConn any) error {
-		return performCopyFrom(ctx, driverConn, sampleData, "transactional (rollback)")
-	})
+	var sent int64
+	err := txraw.WithTx(ctx, db, func(tx *txraw.Tx) error {
+		err := tx.RawContext(ctx, func(driverConn any) (err error) {
+			sent, err = performCopyFrom(ctx, driverConn, sampleData, "transactional (rollback)")
+			return err
+		})
+		if err != nil {
+			return err
+		}
 
-	if err != nil {
-		log.Printf("✗ CopyFrom failed: %v", err)
-		if rollbackErr := sqlTx.Rollback(); rollbackErr != nil {
-			log.Printf("✗ Rollback also failed: %v", rollbackErr)
+		// The wrapper has the full sql.Tx method set, so it can be passed
+		// straight to code written against *sql.Tx-shaped interfaces
+		inTxCount, err := countRows(ctx, tx)
+		if err != nil {
+			return err
 		}
-		return
-	}
+		log.Printf("✓ %d rows visible inside the transaction before rollback", inTxCount)
 
-	// Intentionally rollback the transaction to demonstrate transactional semantics
-	if err = sqlTx.Rollback(); err != nil {
-		log.Fatalf("Failed to rollback transaction: %v", err)
+		// Intentionally fail to demonstrate transactional semantics
+		return errDemoRollback
+	})
+	if !errors.Is(err, errDemoRollback) {
+		log.Printf("✗ Transaction failed: %v", err)
+		return scenarioResult{loaded: len(sampleData), bytes: sent, err: err}
 	}
 	log.Println("✓ Transaction rolled back successfully")
 
 	// Verify that no data was persisted
-	rowCount, err := countRows(ctx, db)
+	rowCount, err := countRows(ctx, verifyDB)
 	if err != nil {
 		log.Fatalf("Failed to count rows (tx-rollback): %v", err)
 	}
@@ -265,58 +478,487 @@ func demonstrateTransactionRollbackCopyFrom(ctx context.Context, db *sql.DB) {
 		log.Println("✓ Rollback worked correctly - no data persisted")
 	}
 	log.Println()
+	return scenarioResult{loaded: len(sampleData), persisted: rowCount, bytes: sent}
+}
+
+// demonstrateTempTableMerge shows the most common real-world reason for
+// Tx.Raw beyond a plain CopyFrom: bulk-loading a batch into an ON COMMIT DROP
+// temp table with CopyFrom and merging it into the real table with SQL joins,
+// all in one transaction.
+//
+// The transaction runs on a pinned sql.Conn so that, after the commit, the
+// same session can show that the temp table is gone.
+func demonstrateTempTableMerge(ctx context.Context, db, verifyDB *sql.DB) scenarioResult {
+	log.Println("--- Scenario 4: CopyFrom into an ON COMMIT DROP temp table, merged by join ---")
+	log.Println("Uses reflection-based Tx.Raw() - the temp table only exists inside the transaction")
+
+	if err := clearTable(ctx, db); err != nil {
+		log.Fatalf("Failed to clear table: %v", err)
+	}
+	_, err := db.ExecContext(ctx, fmt.Sprintf(
+		"INSERT INTO %s (name, data) SELECT 'Merge Name ' || g, 'Merge Data ' || g FROM generate_series(1, %d) g",
+		tableName, mergeExisting))
+	if err != nil {
+		log.Fatalf("Failed to seed %s: %v", tableName, err)
+	}
+	log.Printf("Seeded %d existing rows", mergeExisting)
+
+	// The batch overlaps the existing rows: the first ones update, the rest
+	// are new.
+	batch := make([][]any, mergeBatch)
+	for i := range batch {
+		n := mergeExisting - mergeOverlap + i + 1
+		batch[i] = []any{fmt.Sprintf("Merge Name %d", n), fmt.Sprintf("Merge Data %d (merged)", n)}
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		log.Fatalf("Failed to reserve connection: %v", err)
+	}
+	defer conn.Close()
+	sqlTx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		log.Fatalf("Failed to begin transaction: %v", err)
+	}
+	tx := txraw.Wrap(sqlTx)
+
+	var updated, inserted int64
+	err = func() error {
+		err := tx.RawContext(ctx, txraw.UnwrapAs(func(conn *pgx.Conn) error {
+			_, err := conn.Exec(ctx, fmt.Sprintf(
+				"CREATE TEMP TABLE %s (name VARCHAR(255) NOT NULL, data TEXT) ON COMMIT DROP", mergeStagingTable))
+			if err != nil {
+				return fmt.Errorf("creating temp table failed: %w", err)
+			}
+			copied, err := conn.CopyFrom(ctx, pgx.Identifier{mergeStagingTable}, []string{"name", "data"}, pgx.CopyFromRows(batch))
+			if err != nil {
+				return fmt.Errorf("CopyFrom into temp table failed: %w", err)
+			}
+			log.Printf("✓ Copied %d rows into temp table %s", copied, mergeStagingTable)
+			return nil
+		}))
+		if err != nil {
+			return err
+		}
+
+		// From here on it is plain SQL through the same transaction.
+		res, err := tx.ExecContext(ctx, fmt.Sprintf(
+			"UPDATE %s i SET data = s.data FROM %s s WHERE i.name = s.name", tableName, mergeStagingTable))
+		if err != nil {
+			return fmt.Errorf("merging updates failed: %w", err)
+		}
+		if updated, err = res.RowsAffected(); err != nil {
+			return err
+		}
+		res, err = tx.ExecContext(ctx, fmt.Sprintf(
+			"INSERT INTO %s (name, data) SELECT s.name, s.data FROM %s s WHERE NOT EXISTS (SELECT 1 FROM %s i WHERE i.name = s.name)",
+			tableName, mergeStagingTable, tableName))
+		if err != nil {
+			return fmt.Errorf("merging inserts failed: %w", err)
+		}
+		if inserted, err = res.RowsAffected(); err != nil {
+			return err
+		}
+		log.Printf("✓ Merged by join: %d rows updated, %d rows inserted", updated, inserted)
+		return nil
+	}()
+	if err != nil {
+		_ = tx.Rollback()
+		log.Printf("✗ Transaction failed and was rolled back: %v", err)
+		return scenarioResult{loaded: len(batch), err: err}
+	}
+	// Checked on the raw connection just before commit, while the temp table
+	// still exists; a failing check rolls the merge back.
+	assertions := pgxraw.NewAssertions().Add(
+		pgxraw.Assertion{
+			Name:  "no empty names staged",
+			Query: fmt.Sprintf("SELECT count(*) FROM %s WHERE name = ''", mergeStagingTable),
+		},
+		pgxraw.Assertion{
+			Name: "every staged row merged",
+			Query: fmt.Sprintf("SELECT count(*) FROM %s s WHERE NOT EXISTS (SELECT 1 FROM %s i WHERE i.name = s.name AND i.data = s.data)",
+				mergeStagingTable, tableName),
+		},
+	)
+	if err := assertions.Commit(ctx, tx); err != nil {
+		if errors.Is(err, pgxraw.ErrAssertionFailed) {
+			log.Printf("✗ Pre-commit assertion failed, merge rolled back: %v", err)
+		} else {
+			log.Printf("✗ Commit failed: %v", err)
+		}
+		return scenarioResult{loaded: len(batch), err: err}
+	}
+	log.Println("✓ Pre-commit assertions passed")
+	log.Println("✓ Transaction committed successfully")
+
+	// Same session, after the commit: ON COMMIT DROP has removed the table
+	var stillThere bool
+	err = conn.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", "pg_temp."+mergeStagingTable).Scan(&stillThere)
+	if err != nil {
+		log.Fatalf("Failed to look up temp table: %v", err)
+	}
+	if stillThere {
+		log.Printf("✗ ERROR: Temp table %s survived the commit!", mergeStagingTable)
+	} else {
+		log.Printf("✓ Temp table %s is gone after commit (same session)", mergeStagingTable)
+	}
+
+	rowCount, err := countRows(ctx, verifyDB)
+	if err != nil {
+		log.Fatalf("Failed to count rows (temp-table merge): %v", err)
+	}
+	expected := mergeExisting + mergeBatch - mergeOverlap
+	log.Printf("✓ Result: %d rows persisted after merge (Expected: %d)", rowCount, expected)
+	if rowCount != expected || updated != mergeOverlap {
+		log.Printf("✗ ERROR: Row counts do not match the merge!")
+	}
+	log.Println()
+	return scenarioResult{loaded: len(batch), persisted: rowCount}
+}
+
+// demonstrateLibPQCopyIn runs the transactional bulk insert through the
+// lib/pq driver instead of pgx, using the same Raw-based approach: pqraw
+// drives pq's COPY FROM STDIN on the transaction's driver connection.
+func demonstrateLibPQCopyIn(ctx context.Context, verifyDB *sql.DB) scenarioResult {
+	log.Println("--- Extra scenario: lib/pq CopyIn WITH transaction (COMMIT) ---")
+
+	db, err := sql.Open("postgres", dsn(""))
+	if err != nil {
+		log.Fatalf("sql.Open (lib/pq) failed: %v", err)
+	}
+	defer db.Close()
+
+	if err := clearTable(ctx, db); err != nil {
+		log.Fatalf("Failed to clear table: %v", err)
+	}
+
+	sampleData := generateSampleData(12, "LibPQ")
+	log.Printf("Generated %d rows for lib/pq insertion", len(sampleData))
+
+	err = txraw.WithTx(ctx, db, func(tx *txraw.Tx) error {
+		copied, err := pqraw.CopyIn(ctx, tx, tableName, []string{"name", "data"}, sampleData)
+		if err != nil {
+			return err
+		}
+		log.Printf("✓ Successfully inserted %d rows using pq.CopyIn (transactional)", copied)
+		return nil
+	})
+	if err != nil {
+		log.Printf("✗ lib/pq transaction failed and was rolled back: %v", err)
+		return scenarioResult{loaded: len(sampleData), err: err}
+	}
+
+	rowCount, err := countRows(ctx, verifyDB)
+	if err != nil {
+		log.Fatalf("Failed to count rows (lib/pq): %v", err)
+	}
+	log.Printf("✓ Result: %d rows persisted after commit (Expected: %d)", rowCount, len(sampleData))
+	if rowCount != len(sampleData) {
+		log.Printf("✗ ERROR: Row count mismatch for lib/pq CopyIn!")
+	}
+	log.Println()
+	return scenarioResult{loaded: len(sampleData), persisted: rowCount}
+}
+
+// demonstrateMySQLLoadData runs the transactional bulk insert against MySQL,
+// showing that the Raw-based approach is not specific to PostgreSQL: mysqlraw
+// streams the rows with LOAD DATA LOCAL INFILE on the transaction's driver
+// connection.
+func demonstrateMySQLLoadData(ctx context.Context, mysqlDSN string) {
+	log.Println("--- Extra scenario: MySQL LOAD DATA WITH transaction (COMMIT) ---")
+
+	db, err := sql.Open("mysql", mysqlDSN)
+	if err != nil {
+		log.Fatalf("sql.Open (mysql) failed: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS items (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		data TEXT
+	)`)
+	if err != nil {
+		log.Fatalf("Failed to create MySQL table: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, "DELETE FROM items"); err != nil {
+		log.Fatalf("Failed to clear MySQL table: %v", err)
+	}
+
+	sampleData := generateSampleData(12, "MySQL")
+	log.Printf("Generated %d rows for MySQL insertion", len(sampleData))
+
+	err = txraw.WithTx(ctx, db, func(tx *txraw.Tx) error {
+		loaded, err := mysqlraw.LoadData(ctx, tx, tableName, []string{"name", "data"}, sampleData)
+		if err != nil {
+			return err
+		}
+		log.Printf("✓ Successfully inserted %d rows using LOAD DATA LOCAL INFILE (transactional)", loaded)
+		return nil
+	})
+	if err != nil {
+		log.Printf("✗ MySQL transaction failed and was rolled back: %v", err)
+		return
+	}
+
+	rowCount, err := countRows(ctx, db)
+	if err != nil {
+		log.Fatalf("Failed to count rows (MySQL): %v", err)
+	}
+	log.Printf("✓ Result: %d rows persisted after commit (Expected: %d)", rowCount, len(sampleData))
+	if rowCount != len(sampleData) {
+		log.Printf("✗ ERROR: Row count mismatch for MySQL LOAD DATA!")
+	}
+	log.Println()
+}
+
+// demonstrateMSSQLCopyIn runs the transactional bulk insert against SQL
+// Server: mssqlraw drives the TDS bulk copy protocol on the transaction's
+// *mssql.Conn, mirroring the PostgreSQL CopyFrom scenarios.
+func demonstrateMSSQLCopyIn(ctx context.Context, mssqlDSN string) {
+	log.Println("--- Extra scenario: SQL Server bulk copy WITH transaction (COMMIT) ---")
+
+	db, err := sql.Open("sqlserver", mssqlDSN)
+	if err != nil {
+		log.Fatalf("sql.Open (sqlserver) failed: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, `IF OBJECT_ID('items', 'U') IS NULL CREATE TABLE items (
+		id INT IDENTITY PRIMARY KEY,
+		name NVARCHAR(255) NOT NULL,
+		data NVARCHAR(MAX)
+	)`)
+	if err != nil {
+		log.Fatalf("Failed to create SQL Server table: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, "DELETE FROM items"); err != nil {
+		log.Fatalf("Failed to clear SQL Server table: %v", err)
+	}
+
+	sampleData := generateSampleData(12, "MSSQL")
+	log.Printf("Generated %d rows for SQL Server insertion", len(sampleData))
+
+	err = txraw.WithTx(ctx, db, func(tx *txraw.Tx) error {
+		copied, err := mssqlraw.CopyIn(ctx, tx, tableName, []string{"name", "data"}, sampleData, mssql.BulkOptions{})
+		if err != nil {
+			return err
+		}
+		log.Printf("✓ Successfully inserted %d rows using TDS bulk copy (transactional)", copied)
+		return nil
+	})
+	if err != nil {
+		log.Printf("✗ SQL Server transaction failed and was rolled back: %v", err)
+		return
+	}
+
+	rowCount, err := countRows(ctx, db)
+	if err != nil {
+		log.Fatalf("Failed to count rows (SQL Server): %v", err)
+	}
+	log.Printf("✓ Result: %d rows persisted after commit (Expected: %d)", rowCount, len(sampleData))
+	if rowCount != len(sampleData) {
+		log.Printf("✗ ERROR: Row count mismatch for SQL Server bulk copy!")
+	}
+	log.Println()
+}
+
+// demonstrateSQLiteRaw uses the raw SQLite connection inside a transaction:
+// it takes an online backup of the snapshot the transaction starts from,
+// watches the transaction's inserts with an update hook (go-sqlite3 only) and
+// then commits. The backup must be taken before the first write, since SQLite
+// cannot back up from a connection in a write transaction.
+func demonstrateSQLiteRaw(ctx context.Context, driverName string) {
+	log.Printf("--- Extra scenario: SQLite raw connection WITH transaction (%s) ---", driverName)
+
+	dir, err := os.MkdirTemp("", "txraw-sqlite-")
+	if err != nil {
+		log.Fatalf("Failed to create SQLite directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := sql.Open(driverName, filepath.Join(dir, "items.db"))
+	if err != nil {
+		log.Fatalf("sql.Open (%s) failed: %v", driverName, err)
+	}
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE items (
+		id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		data TEXT
+	)`)
+	if err != nil {
+		log.Fatalf("Failed to create SQLite table: %v", err)
+	}
+
+	sampleData := generateSampleData(12, "SQLite")
+	backupPath := filepath.Join(dir, "backup.db")
+	inserts := 0
+
+	err = txraw.WithTx(ctx, db, func(tx *txraw.Tx) error {
+		if err := sqliteraw.Backup(ctx, tx, backupPath); err != nil {
+			return err
+		}
+		log.Println("✓ Online backup taken through the transaction's connection")
+
+		remove, err := sqliteraw.OnUpdate(ctx, tx, func(op sqliteraw.Op, _, _ string, _ int64) {
+			if op == sqliteraw.OpInsert {
+				inserts++
+			}
+		})
+		switch {
+		case errors.Is(err, txraw.ErrUnsupportedDriver):
+			log.Printf("⚠️  Update hooks are not available with %s", driverName)
+		case err != nil:
+			return err
+		default:
+			defer remove()
+		}
+
+		for _, row := range sampleData {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO items (name, data) VALUES (?, ?)", row...); err != nil {
+				return fmt.Errorf("insert failed: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("✗ SQLite transaction failed and was rolled back: %v", err)
+		return
+	}
+	if inserts > 0 {
+		log.Printf("✓ Update hook saw %d inserts", inserts)
+	}
+
+	rowCount, err := countRows(ctx, db)
+	if err != nil {
+		log.Fatalf("Failed to count rows (SQLite): %v", err)
+	}
+	log.Printf("✓ Result: %d rows persisted after commit (Expected: %d)", rowCount, len(sampleData))
+
+	backupDB, err := sql.Open(driverName, backupPath)
+	if err != nil {
+		log.Fatalf("sql.Open (SQLite backup) failed: %v", err)
+	}
+	defer backupDB.Close()
+	backupCount, err := countRows(ctx, backupDB)
+	if err != nil {
+		log.Fatalf("Failed to count rows (SQLite backup): %v", err)
+	}
+	log.Printf("✓ Backup holds %d rows, the snapshot before the transaction wrote (Expected: 0)", backupCount)
+	log.Println()
 }
 
 // performCopyFrom encapsulates the common logic for executing pgx.CopyFrom
 // with proper error handling and logging.
 //
-// The function expects a driver connection (should be *stdlib.Conn for pgx)
-// and performs the bulk insertion using pgx's efficient CopyFrom method.
-func performCopyFrom(ctx context.Context, driverConn any, data [][]any, scenario string) error {
-	// Cast the driver connection to pgx's stdlib.Conn
-	stdlibConn, ok := driverConn.(*stdlib.Conn)
+// The function accepts any driver connection whose registered txraw adapter
+// unwraps to *pgx.Conn (pgx's *stdlib.Conn out of the box) and performs the
+// bulk insertion using pgx's efficient CopyFrom method. It returns the number
+// of bytes the COPY wrote to the network, which the demo's pool counts (see
+// pgxraw.WithByteCounting).
+func performCopyFrom(ctx context.Context, driverConn any, data [][]any, scenario string) (int64, error) {
+	// Resolve the native pgx.Conn, which provides the CopyFrom method, through
+	// the adapter registry instead of hard-coding pgx's stdlib.Conn
+	native, err := txraw.UnwrapConn(driverConn)
+	if err != nil {
+		return 0, err
+	}
+	pgxConn, ok := native.(*pgx.Conn)
 	if !ok {
-		return fmt.Errorf("driverConn is not *stdlib.Conn, got %T", driverConn)
+		return 0, fmt.Errorf("%w: CopyFrom needs *pgx.Conn, adapter returned %T", txraw.ErrUnsupportedDriver, native)
 	}
 
-	// Get the underlying pgx.Conn which provides the CopyFrom method
-	pgxConn := stdlibConn.Conn()
+	ctx, cancel := deadline.Context(ctx, deadline.Copy)
+	defer cancel()
 
 	// Perform the bulk insertion using pgx's high-performance CopyFrom
 	// This is significantly faster than individual INSERT statements
+	before, _, counted := pgxraw.WireBytes(pgxConn)
+	start := time.Now()
 	copyCount, err := pgxConn.CopyFrom(
 		ctx,
 		pgx.Identifier{tableName},
 		[]string{"name", "data"}, // Column names must match table schema
 		pgx.CopyFromRows(data),
 	)
+	elapsed := time.Since(start)
 	if err != nil {
-		return fmt.Errorf("pgxConn.CopyFrom failed: %w", err)
+		return 0, fmt.Errorf("pgxConn.CopyFrom failed: %w", err)
 	}
 
 	log.Printf("✓ Successfully inserted %d rows using CopyFrom (%s)", copyCount, scenario)
-	return nil
+	if !counted {
+		return 0, nil
+	}
+	after, _, _ := pgxraw.WireBytes(pgxConn)
+	sent := after - before
+	log.Printf("  %d bytes sent in %s (%.2f MB/s)", sent, elapsed.Round(time.Microsecond), pgxraw.MBPerSec(sent, elapsed))
+	return sent, nil
 }
 
 // dbConnect establishes a connection to the PostgreSQL database using pgx driver.
-// The connection string is configured for the Docker container setup.
+// The connection string is configured for the Docker container setup, and
+// the connections count their network bytes so COPY throughput can be shown
+// in MB/s, not only rows.
 func dbConnect(ctx context.Context) (*sql.DB, error) {
-	dsn := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
-		dbUser, dbPassword, dbHost, dbPort, dbName)
-
-	db, err := sql.Open("pgx", dsn)
+	db, err := pgxraw.Open(dsn(""), pgxraw.WithByteCounting())
 	if err != nil {
-		return nil, fmt.Errorf("sql.Open failed: %w", err)
+		return nil, fmt.Errorf("pgxraw.Open failed: %w", err)
 	}
 
-	if err = db.PingContext(ctx); err != nil {
+	pingCtx, cancel := deadline.Context(ctx, deadline.Connect)
+	defer cancel()
+	if err = db.PingContext(pingCtx); err != nil {
 		return nil, fmt.Errorf("db.PingContext failed: %w", err)
 	}
 
 	log.Println("✓ Successfully connected to PostgreSQL")
+
+	// Warn early about driver/server combinations with known problems
+	fp, err := takeFingerprint(ctx, db, dbPort)
+	if err != nil {
+		log.Printf("⚠️  Could not fingerprint driver and server: %v", err)
+	} else {
+		log.Printf("✓ pgx %s, server_version_num %d", fp.PgxVersion, fp.ServerVersionNum)
+		warnKnownIssues(fp)
+	}
+
+	return db, nil
+}
+
+// dbConnectVerify opens a dedicated pool for verification and count queries.
+//
+// The pool is capped at verifyPoolSize connections so verification never
+// steals connections from the load path. PostgreSQL has no per-session query
+// priority, so "lower priority" is expressed as read-only sessions with a
+// short statement_timeout and a distinct application_name that operators can
+// spot in pg_stat_activity.
+func dbConnectVerify(ctx context.Context) (*sql.DB, error) {
+	db, err := sql.Open("pgx", dsn("&default_transaction_read_only=on&statement_timeout=10000&application_name=tx_raw_example_verify"))
+	if err != nil {
+		return nil, fmt.Errorf("sql.Open (verify) failed: %w", err)
+	}
+	db.SetMaxOpenConns(verifyPoolSize)
+	db.SetMaxIdleConns(1)
+
+	if err = db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("db.PingContext (verify) failed: %w", err)
+	}
+
+	log.Printf("✓ Opened read-only verification pool (max %d connections)", verifyPoolSize)
 	return db, nil
 }
 
+// dsn returns the connection string for the Docker container setup, with
+// extra appended verbatim to the query string.
+func dsn(extra string) string {
+	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable%s",
+		dbUser, dbPassword, dbHost, dbPort, dbName, extra)
+}
+
 // generateSampleData creates a slice of sample data for CopyFrom operations.
 // Each row contains a name and data field with the specified prefix.
 //
@@ -337,6 +979,9 @@ func generateSampleData(numRows int, prefix string) [][]any {
 func countRows(ctx context.Context, querier interface {
 	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
 }) (int, error) {
+	ctx, cancel := deadline.Context(ctx, deadline.Verify)
+	defer cancel()
+
 	var count int
 	err := querier.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s", tableName)).Scan(&count)
 	if err != nil {
@@ -0,0 +1,71 @@
+// Package mssqlraw is the github.com/denisenkom/go-mssqldb counterpart of
+// pgxraw and pqraw: it unwraps the *mssql.Conn behind a transaction and
+// streams rows with the TDS bulk copy protocol (INSERT BULK), the same path
+// mssql.CopyIn uses, so SQL Server users get a transactional bulk load too.
+package mssqlraw
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// adapter registers go-mssqldb with txraw's adapter registry; its driver
+// connection already is the native *mssql.Conn.
+type adapter struct{}
+
+func (adapter) Match(conn driver.Conn) bool {
+	_, ok := conn.(*mssql.Conn)
+	return ok
+}
+
+func (adapter) Unwrap(conn driver.Conn) any { return conn }
+
+func init() {
+	txraw.RegisterAdapter("mssql", adapter{})
+}
+
+// CopyIn bulk-inserts rows into table within tx using TDS bulk copy, the
+// SQL Server equivalent of pgx's CopyFrom. It returns the number of rows
+// copied. opts is passed through to the bulk copy; its zero value uses the
+// server defaults.
+//
+// Unlike preparing mssql.CopyIn through database/sql, rows are handed to the
+// driver as they are, without database/sql's parameter conversion, so types
+// such as mssql.UniqueIdentifier and civil.Date keep their SQL Server
+// encoding.
+//
+// If tx is read-only, the error matches txraw.ErrReadOnly; if tx does not
+// run on go-mssqldb, it matches txraw.ErrUnsupportedDriver.
+func CopyIn(ctx context.Context, tx txraw.RawTx, table string, columns []string, rows [][]any, opts mssql.BulkOptions) (int64, error) {
+	var copied int64
+	err := txraw.RawWrite(ctx, tx, "CopyIn into "+table, func(driverConn any) error {
+		conn, ok := driverConn.(*mssql.Conn)
+		if !ok {
+			return fmt.Errorf("%w: driverConn is not *mssql.Conn, got %T", txraw.ErrUnsupportedDriver, driverConn)
+		}
+
+		bulk := conn.CreateBulkContext(ctx, table, columns)
+		bulk.Options = opts
+		for i, row := range rows {
+			if len(row) != len(columns) {
+				return fmt.Errorf("row %d has %d values, want %d", i+1, len(row), len(columns))
+			}
+			if err := bulk.AddRow(row); err != nil {
+				return fmt.Errorf("bulk copy into %s row %d failed: %w", table, i+1, err)
+			}
+		}
+
+		var err error
+		copied, err = bulk.Done()
+		if err != nil {
+			return fmt.Errorf("finishing bulk copy into %s failed: %w", table, err)
+		}
+		return nil
+	})
+	return copied, err
+}
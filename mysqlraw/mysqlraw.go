@@ -0,0 +1,192 @@
+// Package mysqlraw is the go-sql-driver/mysql counterpart of pgxraw and
+// pqraw: it bulk-loads rows inside a transaction with LOAD DATA LOCAL INFILE,
+// streaming them from a reader registered with mysql.RegisterReaderHandler
+// and executing the statement directly on the transaction's driver
+// connection.
+//
+// The server must allow local infile (local_infile=ON); the client side needs
+// no DSN option because Reader:: handlers are always permitted.
+package mysqlraw
+
+import (
+	"bufio"
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// mysqlPkgPath is the package path of go-sql-driver/mysql's unexported
+// driver connection.
+const mysqlPkgPath = "github.com/go-sql-driver/mysql"
+
+// handlerSeq makes reader handler names unique, since the handler registry
+// is global to the process and loads may run concurrently.
+var handlerSeq atomic.Uint64
+
+// IsMySQL reports whether driverConn, as passed to a Raw callback, is a
+// go-sql-driver/mysql connection. The driver does not export its connection
+// type, so this checks the package the type comes from.
+func IsMySQL(driverConn any) bool {
+	t := reflect.TypeOf(driverConn)
+	if t == nil {
+		return false
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.PkgPath() == mysqlPkgPath
+}
+
+// adapter registers go-sql-driver/mysql with txraw's adapter registry. The
+// driver has no separate native connection, so Unwrap returns the connection
+// itself.
+type adapter struct{}
+
+func (adapter) Match(conn driver.Conn) bool { return IsMySQL(conn) }
+func (adapter) Unwrap(conn driver.Conn) any { return conn }
+
+func init() {
+	txraw.RegisterAdapter("mysql", adapter{})
+}
+
+// LoadData bulk-inserts rows into table within tx using LOAD DATA LOCAL
+// INFILE, the MySQL equivalent of pgx's CopyFrom. Rows are encoded as
+// tab-separated text while the server reads them, so they are never buffered
+// in full. It returns the number of rows loaded.
+//
+// Values are converted with database/sql's default parameter conversion; nil
+// is loaded as NULL. Note that outside strict SQL mode MySQL turns bad values
+// into warnings rather than errors.
+//
+// If tx is read-only, the error matches txraw.ErrReadOnly; if tx does not
+// run on go-sql-driver/mysql, it matches txraw.ErrUnsupportedDriver.
+func LoadData(ctx context.Context, tx txraw.RawTx, table string, columns []string, rows [][]any) (int64, error) {
+	var loaded int64
+	err := txraw.RawWrite(ctx, tx, "LoadData into "+table, func(driverConn any) error {
+		if !IsMySQL(driverConn) {
+			return fmt.Errorf("%w: driverConn is not a go-sql-driver/mysql connection, got %T", txraw.ErrUnsupportedDriver, driverConn)
+		}
+		execer, ok := driverConn.(driver.ExecerContext)
+		if !ok {
+			return fmt.Errorf("%w: %T does not implement driver.ExecerContext", txraw.ErrUnsupportedDriver, driverConn)
+		}
+
+		pr, pw := io.Pipe()
+		defer pr.Close() // unblocks the writer if the server stops reading early
+		go func() {
+			pw.CloseWithError(writeRows(pw, columns, rows))
+		}()
+
+		name := "txraw-" + strconv.FormatUint(handlerSeq.Add(1), 10)
+		mysql.RegisterReaderHandler(name, func() io.Reader { return pr })
+		defer mysql.DeregisterReaderHandler(name)
+
+		query := fmt.Sprintf("LOAD DATA LOCAL INFILE 'Reader::%s' INTO TABLE %s "+
+			"CHARACTER SET utf8mb4 FIELDS TERMINATED BY '\\t' ESCAPED BY '\\\\' LINES TERMINATED BY '\\n' (%s)",
+			name, identifier(table), columnList(columns))
+		result, err := execer.ExecContext(ctx, query, nil)
+		if err != nil {
+			return fmt.Errorf("LOAD DATA into %s failed: %w", table, err)
+		}
+		loaded, err = result.RowsAffected()
+		return err
+	})
+	return loaded, err
+}
+
+// writeRows encodes rows in LOAD DATA's default text format.
+func writeRows(w io.Writer, columns []string, rows [][]any) error {
+	bw := bufio.NewWriter(w)
+	for i, row := range rows {
+		if len(row) != len(columns) {
+			return fmt.Errorf("row %d has %d values, want %d", i+1, len(row), len(columns))
+		}
+		for j, v := range row {
+			if j > 0 {
+				bw.WriteByte('\t')
+			}
+			value, err := driver.DefaultParameterConverter.ConvertValue(v)
+			if err != nil {
+				return fmt.Errorf("row %d, column %s: %w", i+1, columns[j], err)
+			}
+			writeValue(bw, value)
+		}
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// writeValue writes a single driver.Value, escaping the characters that are
+// special to LOAD DATA.
+func writeValue(bw *bufio.Writer, v driver.Value) {
+	switch v := v.(type) {
+	case nil:
+		bw.WriteString(`\N`)
+	case bool:
+		if v {
+			bw.WriteByte('1')
+		} else {
+			bw.WriteByte('0')
+		}
+	case int64:
+		bw.WriteString(strconv.FormatInt(v, 10))
+	case float64:
+		bw.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+	case time.Time:
+		bw.WriteString(v.Format("2006-01-02 15:04:05.999999"))
+	case []byte:
+		writeEscaped(bw, string(v))
+	case string:
+		writeEscaped(bw, v)
+	}
+}
+
+func writeEscaped(bw *bufio.Writer, s string) {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			bw.WriteString(`\\`)
+		case '\t':
+			bw.WriteString(`\t`)
+		case '\n':
+			bw.WriteString(`\n`)
+		case '\r':
+			bw.WriteString(`\r`)
+		case 0:
+			bw.WriteString(`\0`)
+		default:
+			bw.WriteByte(c)
+		}
+	}
+}
+
+// identifier quotes a possibly database-qualified table name.
+func identifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		parts[i] = quote(p)
+	}
+	return strings.Join(parts, ".")
+}
+
+func columnList(columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = quote(c)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func quote(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/eqld/example-tx-raw/pgxraw"
+)
+
+const (
+	// partitionedTable is range-partitioned by id into partitionedParts
+	// partitions of partitionedPartSize ids each.
+	partitionedTable    = "items_partitioned"
+	partitionedParts    = 4
+	partitionedPartSize = 25
+	partitionedRows     = partitionedParts * partitionedPartSize
+)
+
+// demonstratePartitionedLoad loads a partitioned table with one transaction
+// per partition running in parallel, committed all-or-nothing through
+// two-phase commit.
+func demonstratePartitionedLoad(ctx context.Context, db *sql.DB) scenarioResult {
+	log.Println("--- Extra scenario: Parallel CopyFrom across partitions with two-phase commit ---")
+
+	if err := createPartitionedTable(ctx, db); err != nil {
+		log.Fatalf("Failed to create %s: %v", partitionedTable, err)
+	}
+
+	rows := make([][]any, partitionedRows)
+	for i, row := range generateSampleData(partitionedRows, "Partitioned") {
+		rows[i] = append([]any{int32(i + 1)}, row...)
+	}
+	results, err := pgxraw.CopyFromPartitions(ctx, db, partitionedTable, []string{"id", "name", "data"},
+		pgx.CopyFromRows(rows), pgxraw.PartitionOptions{
+			Route: func(row []any) (string, error) {
+				id, ok := row[0].(int32)
+				if !ok {
+					return "", fmt.Errorf("id is %T, not int32", row[0])
+				}
+				return fmt.Sprintf("public.%s_p%d", partitionedTable, (id-1)/partitionedPartSize), nil
+			},
+		})
+
+	var loaded int64
+	for _, r := range results {
+		loaded += r.Rows
+		status := "✓"
+		if r.Err != nil {
+			status = "✗"
+		}
+		log.Printf("  %s %s: %d rows in %s, committed=%t", status, r.Partition, r.Rows, r.Duration, r.Committed)
+	}
+
+	var persisted int
+	if err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s", partitionedTable)).Scan(&persisted); err != nil {
+		log.Fatalf("Failed to count rows in %s: %v", partitionedTable, err)
+	}
+
+	if errors.Is(err, pgxraw.ErrInDoubt) {
+		log.Printf("⚠️  Some partitions committed, others are left prepared: %v", err)
+		log.Println()
+		return scenarioResult{loaded: int(loaded), persisted: persisted, err: err}
+	}
+	if err != nil {
+		log.Printf("✗ Partitioned load failed, all partitions rolled back: %v", err)
+		log.Println("  (two-phase commit needs max_prepared_transactions > 0 on the server)")
+		log.Println()
+		return scenarioResult{loaded: int(loaded), persisted: persisted, err: err}
+	}
+	log.Printf("✓ Result: %d rows persisted across %d partitions (Expected: %d)", persisted, len(results), partitionedRows)
+	if persisted != partitionedRows {
+		log.Printf("✗ ERROR: Row count mismatch for the partitioned load!")
+	}
+	log.Println()
+	return scenarioResult{loaded: int(loaded), persisted: persisted}
+}
+
+// createPartitionedTable (re)creates partitionedTable and its partitions.
+func createPartitionedTable(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s", partitionedTable)); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE %s (
+		id INT NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		data TEXT
+	) PARTITION BY RANGE (id)`, partitionedTable))
+	if err != nil {
+		return err
+	}
+	for p := 0; p < partitionedParts; p++ {
+		_, err := db.ExecContext(ctx, fmt.Sprintf("CREATE TABLE %s_p%d PARTITION OF %s FOR VALUES FROM (%d) TO (%d)",
+			partitionedTable, p, partitionedTable, p*partitionedPartSize+1, (p+1)*partitionedPartSize+1))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
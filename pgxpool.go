@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// comparisonRounds is how often each path copies the sample data when
+// measuring the overhead of database/sql.
+const comparisonRounds = 20
+
+// demonstratePgxPool runs the three scenarios again on a native pgxpool,
+// without database/sql, and then times transactional CopyFrom through both
+// paths so the cost of the reflection workaround can be quantified.
+func demonstratePgxPool(ctx context.Context, db *sql.DB) {
+	log.Println("--- Comparison: the same scenarios on native pgxpool (no database/sql) ---")
+
+	pool, err := pgxpool.New(ctx, dsn(""))
+	if err != nil {
+		log.Fatalf("pgxpool.New failed: %v", err)
+	}
+	defer pool.Close()
+
+	columns := []string{"name", "data"}
+
+	// Scenario 1: no transaction, CopyFrom straight on the pool
+	if err := clearTable(ctx, db); err != nil {
+		log.Fatalf("Failed to clear table: %v", err)
+	}
+	sampleData := generateSampleData(10, "PoolNoTx")
+	copied, err := pool.CopyFrom(ctx, pgx.Identifier{tableName}, columns, pgx.CopyFromRows(sampleData))
+	if err != nil {
+		log.Fatalf("pool.CopyFrom failed: %v", err)
+	}
+	logPoolResult(ctx, pool, "non-transactional", copied, len(sampleData))
+
+	// Scenario 2: transaction committed; pgx.Tx has CopyFrom, no Raw needed
+	if err := clearTable(ctx, db); err != nil {
+		log.Fatalf("Failed to clear table: %v", err)
+	}
+	sampleData = generateSampleData(15, "PoolTxCommit")
+	err = pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		copied, err = tx.CopyFrom(ctx, pgx.Identifier{tableName}, columns, pgx.CopyFromRows(sampleData))
+		return err
+	})
+	if err != nil {
+		log.Fatalf("pgx transaction (commit) failed: %v", err)
+	}
+	logPoolResult(ctx, pool, "transactional (commit)", copied, len(sampleData))
+
+	// Scenario 3: transaction rolled back
+	if err := clearTable(ctx, db); err != nil {
+		log.Fatalf("Failed to clear table: %v", err)
+	}
+	sampleData = generateSampleData(20, "PoolTxRollback")
+	err = pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		copied, err = tx.CopyFrom(ctx, pgx.Identifier{tableName}, columns, pgx.CopyFromRows(sampleData))
+		if err != nil {
+			return err
+		}
+		return errDemoRollback
+	})
+	if !errors.Is(err, errDemoRollback) {
+		log.Fatalf("pgx transaction (rollback) failed: %v", err)
+	}
+	logPoolResult(ctx, pool, "transactional (rollback)", copied, 0)
+
+	// Time the transactional path both ways
+	sampleData = generateSampleData(1000, "Compare")
+	viaSQL, err := timeRounds(ctx, db, func() error {
+		// db.BeginTx + Wrap rather than txraw.Begin, which would add an
+		// application_name round trip for the run labels
+		sqlTx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		tx := txraw.Wrap(sqlTx)
+		err = tx.Raw(txraw.UnwrapAs(func(conn *pgx.Conn) error {
+			_, err := conn.CopyFrom(ctx, pgx.Identifier{tableName}, columns, pgx.CopyFromRows(sampleData))
+			return err
+		}))
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		log.Fatalf("database/sql comparison round failed: %v", err)
+	}
+	viaPool, err := timeRounds(ctx, db, func() error {
+		return pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+			_, err := tx.CopyFrom(ctx, pgx.Identifier{tableName}, columns, pgx.CopyFromRows(sampleData))
+			return err
+		})
+	})
+	if err != nil {
+		log.Fatalf("pgxpool comparison round failed: %v", err)
+	}
+
+	log.Printf("✓ %d rows x %d rounds, transactional CopyFrom:", len(sampleData), comparisonRounds)
+	log.Printf("  database/sql + txraw: %v per round", viaSQL)
+	log.Printf("  native pgxpool:       %v per round", viaPool)
+	log.Printf("  overhead:             %+.1f%%", 100*(float64(viaSQL)/float64(viaPool)-1))
+	log.Println()
+
+	if err := clearTable(ctx, db); err != nil {
+		log.Fatalf("Failed to clear table: %v", err)
+	}
+}
+
+// timeRounds runs round comparisonRounds times after one warm-up run and
+// returns the mean duration. The table is cleared between rounds.
+func timeRounds(ctx context.Context, db *sql.DB, round func() error) (time.Duration, error) {
+	var total time.Duration
+	for i := 0; i <= comparisonRounds; i++ {
+		if err := clearTable(ctx, db); err != nil {
+			return 0, err
+		}
+		start := time.Now()
+		if err := round(); err != nil {
+			return 0, err
+		}
+		if i > 0 { // the first round warms up connections and caches
+			total += time.Since(start)
+		}
+	}
+	return total / comparisonRounds, nil
+}
+
+// logPoolResult verifies a pgxpool scenario by counting the rows persisted.
+func logPoolResult(ctx context.Context, pool *pgxpool.Pool, scenario string, copied int64, want int) {
+	var rowCount int
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s", tableName)
+	if err := pool.QueryRow(ctx, query).Scan(&rowCount); err != nil {
+		log.Fatalf("Failed to count rows (pgxpool %s): %v", scenario, err)
+	}
+	log.Printf("✓ pgxpool %s: copied %d rows, %d persisted (Expected: %d)", scenario, copied, rowCount, want)
+	if rowCount != want {
+		log.Printf("✗ ERROR: Row count mismatch for pgxpool %s!", scenario)
+	}
+}
@@ -0,0 +1,198 @@
+// Package arrowcopy loads Apache Arrow record batches into PostgreSQL inside
+// a database/sql transaction with pgx.CopyFrom. It is kept out of pgxraw so
+// that only code loading Arrow data depends on arrow-go.
+package arrowcopy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/eqld/example-tx-raw/pgxraw"
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// Options configures CopyFrom.
+type Options struct {
+	// Mapping renames Arrow field names to table columns. Fields missing
+	// from Mapping are used unchanged; fields mapped to "-" are skipped.
+	Mapping map[string]string
+	// OverrideIdentity loads identity columns from the input, the way INSERT
+	// ... OVERRIDING SYSTEM VALUE would, instead of leaving them out for the
+	// server to fill in. Stored generated columns are always left out.
+	OverrideIdentity bool
+}
+
+// CopyFrom streams the record batches of reader into table within tx
+// with pgx.CopyFrom and returns the number of rows copied. The fields of
+// reader's schema name the table columns (see Options.Mapping), less
+// identity and generated columns (see Options.OverrideIdentity). Arrow
+// Flight streams, IPC readers and DataFusion results all implement
+// array.RecordReader; wrap single records with array.NewRecordReader.
+//
+// Values are handed to pgx straight from the Arrow buffers: strings and
+// binary values are not copied, as pgx encodes each row before the next
+// record batch is read. Supported types are booleans, integers, floats,
+// (large) strings and binaries, fixed-size binaries, dates, timestamps,
+// times and 128-bit decimals.
+func CopyFrom(ctx context.Context, tx txraw.RawTx, table string, reader array.RecordReader, opts Options) (int64, error) {
+	if err := txraw.CheckWritable(tx, "arrowcopy.CopyFrom into "+table); err != nil {
+		return 0, err
+	}
+	// fields[i] is the schema field index of table column i.
+	var columns []string
+	var fields []int
+	for i, field := range reader.Schema().Fields() {
+		column := field.Name
+		if mapped, ok := opts.Mapping[column]; ok {
+			column = mapped
+		}
+		if column == "-" {
+			continue
+		}
+		if _, err := arrowGetter(field.Type, nil); err != nil {
+			return 0, fmt.Errorf("Arrow field %q: %w", field.Name, err)
+		}
+		columns = append(columns, column)
+		fields = append(fields, i)
+	}
+	if len(columns) == 0 {
+		return 0, errors.New("Arrow schema maps to no columns")
+	}
+
+	var (
+		getters []func(int) any
+		row     int
+		rows    int
+		batch   int
+		values  []any
+	)
+	src := pgx.CopyFromFunc(func() ([]any, error) {
+		for row >= rows {
+			if !reader.Next() {
+				if err := reader.Err(); err != nil {
+					return nil, fmt.Errorf("reading Arrow record batch %d failed: %w", batch+1, err)
+				}
+				return nil, nil
+			}
+			batch++
+			record := reader.Record()
+			for i, field := range fields {
+				col := record.Column(field)
+				var err error
+				if getters[i], err = arrowGetter(col.DataType(), col); err != nil {
+					return nil, fmt.Errorf("Arrow record batch %d, column %s: %w", batch, columns[i], err)
+				}
+			}
+			row, rows = 0, int(record.NumRows())
+		}
+
+		for i, get := range getters {
+			values[i] = get(row)
+		}
+		row++
+		return values, nil
+	})
+
+	var copied int64
+	err := tx.RawContext(ctx, txraw.UnwrapAs(func(conn *pgx.Conn) error {
+		keep, err := pgxraw.LoadableColumns(ctx, conn, table, columns, func(int) bool { return opts.OverrideIdentity })
+		if err != nil {
+			return err
+		}
+		columns, fields = pick(columns, keep), pick(fields, keep)
+		getters, values = make([]func(int) any, len(columns)), make([]any, len(columns))
+
+		copied, err = conn.CopyFrom(ctx, pgx.Identifier(strings.Split(table, ".")), columns, src)
+		if err != nil {
+			return fmt.Errorf("CopyFrom into %s failed: %w", table, err)
+		}
+		return nil
+	}))
+	return copied, err
+}
+
+// arrowGetter returns a function reading row i of col as a value pgx can
+// encode, with nulls as nil. With a nil col it only checks that typ is
+// supported.
+func arrowGetter(typ arrow.DataType, col arrow.Array) (func(int) any, error) {
+	var get func(int) any
+	switch typ := typ.(type) {
+	case *arrow.BooleanType:
+		get = func(i int) any { return col.(*array.Boolean).Value(i) }
+	case *arrow.Int8Type:
+		get = func(i int) any { return int16(col.(*array.Int8).Value(i)) }
+	case *arrow.Int16Type:
+		get = func(i int) any { return col.(*array.Int16).Value(i) }
+	case *arrow.Int32Type:
+		get = func(i int) any { return col.(*array.Int32).Value(i) }
+	case *arrow.Int64Type:
+		get = func(i int) any { return col.(*array.Int64).Value(i) }
+	case *arrow.Uint8Type:
+		get = func(i int) any { return int16(col.(*array.Uint8).Value(i)) }
+	case *arrow.Uint16Type:
+		get = func(i int) any { return int32(col.(*array.Uint16).Value(i)) }
+	case *arrow.Uint32Type:
+		get = func(i int) any { return int64(col.(*array.Uint32).Value(i)) }
+	case *arrow.Uint64Type:
+		get = func(i int) any { return col.(*array.Uint64).Value(i) }
+	case *arrow.Float32Type:
+		get = func(i int) any { return col.(*array.Float32).Value(i) }
+	case *arrow.Float64Type:
+		get = func(i int) any { return col.(*array.Float64).Value(i) }
+	case *arrow.StringType:
+		get = func(i int) any { return col.(*array.String).Value(i) }
+	case *arrow.LargeStringType:
+		get = func(i int) any { return col.(*array.LargeString).Value(i) }
+	case *arrow.BinaryType:
+		get = func(i int) any { return col.(*array.Binary).Value(i) }
+	case *arrow.LargeBinaryType:
+		get = func(i int) any { return col.(*array.LargeBinary).Value(i) }
+	case *arrow.FixedSizeBinaryType:
+		get = func(i int) any { return col.(*array.FixedSizeBinary).Value(i) }
+	case *arrow.Date32Type:
+		get = func(i int) any { return col.(*array.Date32).Value(i).ToTime() }
+	case *arrow.Date64Type:
+		get = func(i int) any { return col.(*array.Date64).Value(i).ToTime() }
+	case *arrow.TimestampType:
+		get = func(i int) any { return col.(*array.Timestamp).Value(i).ToTime(typ.Unit) }
+	case *arrow.Time32Type:
+		get = func(i int) any { return arrowTime(int64(col.(*array.Time32).Value(i)), typ.Unit) }
+	case *arrow.Time64Type:
+		get = func(i int) any { return arrowTime(int64(col.(*array.Time64).Value(i)), typ.Unit) }
+	case *arrow.Decimal128Type:
+		get = func(i int) any {
+			return pgtype.Numeric{Int: col.(*array.Decimal128).Value(i).BigInt(), Exp: -typ.Scale, Valid: true}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported Arrow type %s", typ)
+	}
+
+	return func(i int) any {
+		if col.IsNull(i) {
+			return nil
+		}
+		return get(i)
+	}, nil
+}
+
+// arrowTime converts a time of day of unit since midnight.
+func arrowTime(n int64, unit arrow.TimeUnit) pgtype.Time {
+	return pgtype.Time{Microseconds: n * int64(unit.Multiplier()) / int64(time.Microsecond), Valid: true}
+}
+
+// pick returns the elements of s at indices.
+func pick[T any](s []T, indices []int) []T {
+	picked := make([]T, len(indices))
+	for i, j := range indices {
+		picked[i] = s[j]
+	}
+	return picked
+}
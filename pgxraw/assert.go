@@ -0,0 +1,104 @@
+package pgxraw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// ErrAssertionFailed is matched by *AssertionError.
+var ErrAssertionFailed = errors.New("pgxraw: pre-commit assertion failed")
+
+// Assertion is a SQL check on the data a transaction is about to commit.
+// Query must return a single integer, which must equal Want for the check to
+// pass; the zero Want suits counts of offending rows:
+//
+//	pgxraw.Assertion{
+//		Name:  "no negative prices",
+//		Query: "SELECT count(*) FROM staging WHERE price < 0",
+//	}
+type Assertion struct {
+	Name  string
+	Query string
+	Args  []any
+	Want  int64
+}
+
+// AssertionError reports the assertion that failed and what its query
+// returned. It matches ErrAssertionFailed with errors.Is.
+type AssertionError struct {
+	Assertion Assertion
+	Got       int64
+}
+
+func (e *AssertionError) Error() string {
+	return fmt.Sprintf("%v: %s: got %d, want %d", ErrAssertionFailed, e.Assertion.Name, e.Got, e.Assertion.Want)
+}
+
+func (e *AssertionError) Unwrap() error {
+	return ErrAssertionFailed
+}
+
+// Assertions is a list of Assertions run just before a load commits, so that
+// a load leaving the data in a bad state is rolled back instead:
+//
+//	assertions := pgxraw.NewAssertions().Add(pgxraw.Assertion{
+//		Name:  "no negative prices",
+//		Query: "SELECT count(*) FROM staging WHERE price < 0",
+//	})
+//	// ... load into staging and merge, within tx ...
+//	if err := assertions.Commit(ctx, tx); errors.Is(err, pgxraw.ErrAssertionFailed) {
+//		// rolled back; err names the failed check
+//	}
+//
+// Inside txraw.WithTx, return the result of Check from the callback instead;
+// a failing check then rolls the transaction back.
+type Assertions struct {
+	list []Assertion
+}
+
+// NewAssertions returns an empty list.
+func NewAssertions() *Assertions {
+	return &Assertions{}
+}
+
+// Add appends assertions. They run in the order they were added.
+func (a *Assertions) Add(assertions ...Assertion) *Assertions {
+	a.list = append(a.list, assertions...)
+	return a
+}
+
+// Check runs the assertions on tx's raw connection and returns an
+// *AssertionError for the first one that fails. An assertion whose query
+// fails, or does not return a single integer, fails the check too.
+func (a *Assertions) Check(ctx context.Context, tx txraw.RawTx) error {
+	return tx.RawContext(ctx, txraw.UnwrapAs(func(conn *pgx.Conn) error {
+		for _, assertion := range a.list {
+			var got int64
+			if err := conn.QueryRow(ctx, assertion.Query, assertion.Args...).Scan(&got); err != nil {
+				return fmt.Errorf("running assertion %q failed: %w", assertion.Name, err)
+			}
+			if got != assertion.Want {
+				return &AssertionError{Assertion: assertion, Got: got}
+			}
+		}
+		return nil
+	}))
+}
+
+// Commit runs Check and commits tx if every assertion holds. Otherwise tx is
+// rolled back and the check's error returned; if the rollback fails too, the
+// error wraps both.
+func (a *Assertions) Commit(ctx context.Context, tx txraw.RawTx) error {
+	if err := a.Check(ctx, tx); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return fmt.Errorf("%w (rollback also failed: %w)", err, rollbackErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
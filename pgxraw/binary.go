@@ -0,0 +1,135 @@
+package pgxraw
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// binaryCopySignature starts every PostgreSQL binary COPY stream.
+const binaryCopySignature = "PGCOPY\n\377\r\n\000"
+
+// CopyFromBinary streams r, which must be in PostgreSQL's binary COPY format
+// (header, tuples, trailer; see BinaryWriter), into columns of table within
+// tx and returns the number of rows copied.
+//
+// Unlike pgx.CopyFrom, which encodes Go values itself, the bytes go to the
+// server as they are, through the raw connection's pgconn. It is meant for
+// pipelines that already hold binary-encoded tuples, e.g. from another
+// server's COPY TO ... BINARY, and want to skip re-encoding. The server
+// validates the data; a malformed stream fails the COPY.
+func CopyFromBinary(ctx context.Context, tx txraw.RawTx, table string, columns []string, r io.Reader) (int64, error) {
+	if err := txraw.CheckWritable(tx, "CopyFromBinary into "+table); err != nil {
+		return 0, err
+	}
+	sql := fmt.Sprintf("COPY %s (%s) FROM STDIN BINARY",
+		pgx.Identifier(strings.Split(table, ".")).Sanitize(), quoteIdentifiers(columns))
+
+	var copied int64
+	err := RawPgconn(ctx, tx, func(conn *pgconn.PgConn) error {
+		tag, err := conn.CopyFrom(ctx, r, sql)
+		if err != nil {
+			return fmt.Errorf("binary COPY into %s failed: %w", table, err)
+		}
+		copied = tag.RowsAffected()
+		return nil
+	})
+	return copied, err
+}
+
+// ErrWriterClosed is returned by BinaryWriter methods after Close.
+var ErrWriterClosed = errors.New("pgxraw: binary COPY writer is closed")
+
+// BinaryWriter writes PostgreSQL's binary COPY format to an io.Writer, for
+// use with CopyFromBinary, typically through an io.Pipe:
+//
+//	pr, pw := io.Pipe()
+//	go func() {
+//		w := pgxraw.NewBinaryWriter(pw)
+//		for _, tuple := range tuples {
+//			if err := w.WriteTuple(tuple...); err != nil {
+//				pw.CloseWithError(err)
+//				return
+//			}
+//		}
+//		pw.CloseWithError(w.Close())
+//	}()
+//	n, err := pgxraw.CopyFromBinary(ctx, tx, "items", columns, pr)
+//
+// Fields are the binary send representation of each column's type, as
+// produced by the type's send function or pgx's binary encoders; a nil field
+// is NULL. The writer does not check fields against the column types.
+type BinaryWriter struct {
+	w      io.Writer
+	buf    []byte
+	header bool
+	closed bool
+}
+
+// NewBinaryWriter returns a BinaryWriter writing to w.
+func NewBinaryWriter(w io.Writer) *BinaryWriter {
+	return &BinaryWriter{w: w}
+}
+
+// WriteTuple writes one tuple with the given pre-encoded fields. The header
+// is written before the first tuple.
+func (b *BinaryWriter) WriteTuple(fields ...[]byte) error {
+	if b.closed {
+		return ErrWriterClosed
+	}
+	if len(fields) > math.MaxInt16 {
+		return fmt.Errorf("tuple has %d fields, binary COPY allows at most %d", len(fields), math.MaxInt16)
+	}
+
+	buf := b.buf[:0]
+	if !b.header {
+		buf = b.appendHeader(buf)
+	}
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(fields)))
+	for _, field := range fields {
+		if field == nil {
+			buf = binary.BigEndian.AppendUint32(buf, math.MaxUint32) // -1: NULL
+			continue
+		}
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(field)))
+		buf = append(buf, field...)
+	}
+	b.buf = buf
+
+	_, err := b.w.Write(buf)
+	return err
+}
+
+// Close writes the trailer, and the header if no tuple was written. It does
+// not close the underlying writer.
+func (b *BinaryWriter) Close() error {
+	if b.closed {
+		return ErrWriterClosed
+	}
+	b.closed = true
+
+	buf := b.buf[:0]
+	if !b.header {
+		buf = b.appendHeader(buf)
+	}
+	buf = binary.BigEndian.AppendUint16(buf, math.MaxUint16) // -1: end of data
+	_, err := b.w.Write(buf)
+	return err
+}
+
+// appendHeader appends the signature, flags and header extension length.
+func (b *BinaryWriter) appendHeader(buf []byte) []byte {
+	b.header = true
+	buf = append(buf, binaryCopySignature...)
+	buf = binary.BigEndian.AppendUint32(buf, 0) // flags: no OIDs
+	return binary.BigEndian.AppendUint32(buf, 0)
+}
@@ -0,0 +1,110 @@
+package pgxraw
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ChannelSource is a pgx.CopyFromSource fed by a channel, so application
+// goroutines can produce rows concurrently while a Raw callback runs
+// CopyFrom:
+//
+//	rows := make(chan []any, 64)
+//	src := pgxraw.CopyFromChannel(ctx, rows)
+//	go func() {
+//		defer close(rows)
+//		for ... {
+//			if err != nil {
+//				src.Fail(err)
+//				return
+//			}
+//			rows <- row
+//		}
+//	}()
+//	_, err := conn.CopyFrom(ctx, table, columns, src)
+//
+// The copy ends when the channel is closed. It fails, aborting the COPY so the
+// transaction can be rolled back, when a producer calls Fail or ctx is done.
+// Producers should also watch ctx (or keep the channel buffered) so they do
+// not block forever on a copy that has stopped reading.
+type ChannelSource struct {
+	ctx  context.Context
+	ch   <-chan []any
+	row  []any
+	err  error
+	once sync.Once
+	fail chan struct{}
+	// failErr is written once before fail is closed.
+	failErr error
+}
+
+var _ pgx.CopyFromSource = (*ChannelSource)(nil)
+
+// ErrSourceFailed is the error a ChannelSource ends the copy with when Fail
+// is called with a nil error.
+var ErrSourceFailed = errors.New("pgxraw: channel source failed")
+
+// CopyFromChannel returns a ChannelSource reading rows from ch until it is
+// closed.
+func CopyFromChannel(ctx context.Context, ch <-chan []any) *ChannelSource {
+	return &ChannelSource{ctx: ctx, ch: ch, fail: make(chan struct{})}
+}
+
+// Fail ends the copy with err. It is safe to call from any goroutine; only
+// the first error is kept. A nil err still fails the copy, with
+// ErrSourceFailed, so that a partial load is never committed as complete.
+func (s *ChannelSource) Fail(err error) {
+	if err == nil {
+		err = ErrSourceFailed
+	}
+	s.once.Do(func() {
+		s.failErr = err
+		close(s.fail)
+	})
+}
+
+func (s *ChannelSource) Next() bool {
+	if s.err != nil {
+		return false
+	}
+	// A reported failure wins over rows that are still buffered.
+	select {
+	case <-s.fail:
+		s.err = s.failErr
+		return false
+	default:
+	}
+
+	select {
+	case <-s.fail:
+		s.err = s.failErr
+		return false
+	case <-s.ctx.Done():
+		s.err = context.Cause(s.ctx)
+		return false
+	case row, ok := <-s.ch:
+		if !ok {
+			// Producers typically Fail and then close the channel; make
+			// sure a closed channel never hides that failure.
+			select {
+			case <-s.fail:
+				s.err = s.failErr
+			default:
+			}
+			return false
+		}
+		s.row = row
+		return true
+	}
+}
+
+func (s *ChannelSource) Values() ([]any, error) {
+	return s.row, nil
+}
+
+func (s *ChannelSource) Err() error {
+	return s.err
+}
@@ -0,0 +1,163 @@
+package pgxraw
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// DefaultChunkSize is used when ChunkOptions.Size is not set.
+const DefaultChunkSize = 10000
+
+// ChunkOptions configures CopyFromChunks.
+type ChunkOptions struct {
+	// Size is the number of rows per COPY. Zero means DefaultChunkSize.
+	Size int
+	// CommitEach commits after every chunk and begins a new transaction for
+	// the next one, bounding WAL and lock duration per transaction at the
+	// cost of atomicity: a failure leaves earlier chunks committed. Without
+	// it all chunks share one transaction.
+	CommitEach bool
+	// OnChunk, if set, is called inside the transaction after each chunk has
+	// been copied, with the total number of rows copied so far. Writing a
+	// checkpoint here makes it commit atomically with the chunk. An error
+	// fails the chunk.
+	OnChunk func(ctx context.Context, tx *txraw.Tx, rowsDone int64) error
+}
+
+// ChunkResult summarizes one chunk.
+type ChunkResult struct {
+	// Chunk is the 1-based chunk number.
+	Chunk int
+	// Rows is the number of rows copied in the chunk.
+	Rows int64
+	// Duration is the time the COPY of the chunk took.
+	Duration time.Duration
+	// Bytes is the number of bytes written to the network for the chunk. It
+	// is only counted on connections opened with WithByteCounting.
+	Bytes int64
+	// Committed reports whether the chunk is durably stored: always after a
+	// successful return, and with CommitEach for every chunk before a failed
+	// one.
+	Committed bool
+}
+
+// MBPerSec returns the network throughput of the chunk in MB/s, or 0 if its
+// bytes were not counted.
+func (r ChunkResult) MBPerSec() float64 {
+	return MBPerSec(r.Bytes, r.Duration)
+}
+
+// CopyFromChunks copies src into table as a series of COPY statements of at
+// most opts.Size rows each, instead of one giant COPY. It returns a result
+// per chunk copied, including on error, so callers can tell how far a failed
+// load got.
+func CopyFromChunks(ctx context.Context, db *sql.DB, table string, columns []string, src pgx.CopyFromSource, opts ChunkOptions) ([]ChunkResult, error) {
+	size := opts.Size
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	identifier := pgx.Identifier(strings.Split(table, "."))
+
+	var results []ChunkResult
+	var rowsDone int64
+	exhausted := false
+	copyChunks := func(tx *txraw.Tx) error {
+		for src.Next() {
+			chunk := &chunkSource{src: src, size: size}
+			start := time.Now()
+			var copied, sent int64
+			err := tx.RawContext(ctx, txraw.UnwrapAs(func(conn *pgx.Conn) error {
+				before, _, _ := WireBytes(conn)
+				var err error
+				copied, err = conn.CopyFrom(ctx, identifier, columns, chunk)
+				after, _, _ := WireBytes(conn)
+				sent = after - before
+				return err
+			}))
+			if err != nil {
+				return fmt.Errorf("chunk %d: CopyFrom into %s failed: %w", len(results)+1, table, err)
+			}
+			results = append(results, ChunkResult{Chunk: len(results) + 1, Rows: copied, Duration: time.Since(start), Bytes: sent})
+			rowsDone += copied
+
+			if opts.OnChunk != nil {
+				if err := opts.OnChunk(ctx, tx, rowsDone); err != nil {
+					results = results[:len(results)-1]
+					rowsDone -= copied
+					return fmt.Errorf("chunk %d: %w", len(results)+1, err)
+				}
+			}
+
+			if chunk.exhausted {
+				exhausted = true
+				return nil
+			}
+			if opts.CommitEach {
+				return nil
+			}
+		}
+		exhausted = true
+		return src.Err()
+	}
+
+	if !opts.CommitEach {
+		if err := txraw.WithTx(ctx, db, copyChunks); err != nil {
+			return results, err
+		}
+		for i := range results {
+			results[i].Committed = true
+		}
+		return results, nil
+	}
+
+	for !exhausted {
+		before := len(results)
+		if err := txraw.WithTx(ctx, db, copyChunks); err != nil {
+			return results, err
+		}
+		if len(results) > before {
+			results[len(results)-1].Committed = true
+		}
+	}
+	return results, nil
+}
+
+// chunkSource yields at most size rows of src. The first row has already
+// been advanced to by the caller.
+type chunkSource struct {
+	src       pgx.CopyFromSource
+	size      int
+	n         int
+	exhausted bool
+}
+
+func (c *chunkSource) Next() bool {
+	if c.n == 0 {
+		c.n++
+		return true
+	}
+	if c.n >= c.size {
+		return false
+	}
+	if !c.src.Next() {
+		c.exhausted = true
+		return false
+	}
+	c.n++
+	return true
+}
+
+func (c *chunkSource) Values() ([]any, error) {
+	return c.src.Values()
+}
+
+func (c *chunkSource) Err() error {
+	return c.src.Err()
+}
@@ -0,0 +1,48 @@
+package pgxraw
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// ConnFromTx returns the *pgx.Conn underlying tx, so application code never
+// has to deal with driver.Conn, reflection or *stdlib.Conn at all:
+//
+//	conn, release, err := pgxraw.ConnFromTx(sqlTx)
+//	if err != nil {
+//		return err
+//	}
+//	defer release()
+//	_, err = conn.CopyFrom(ctx, pgx.Identifier{"items"}, columns, source)
+//
+// Until release is called, tx's connection stays locked exactly as during a
+// Raw callback: other operations on tx, including Commit and Rollback, block.
+// Always call release, and do not use conn afterwards. release is safe to
+// call more than once.
+//
+// If tx does not run on pgx, the error matches txraw.ErrUnsupportedDriver.
+func ConnFromTx(tx *sql.Tx) (conn *pgx.Conn, release func(), err error) {
+	conns := make(chan *pgx.Conn)
+	errs := make(chan error, 1)
+	released := make(chan struct{})
+
+	go func() {
+		errs <- txraw.Wrap(tx).Raw(txraw.As(func(c *stdlib.Conn) error {
+			conns <- c.Conn()
+			<-released
+			return nil
+		}))
+	}()
+
+	select {
+	case conn := <-conns:
+		return conn, sync.OnceFunc(func() { close(released) }), nil
+	case err := <-errs:
+		return nil, nil, err
+	}
+}
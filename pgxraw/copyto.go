@@ -0,0 +1,77 @@
+package pgxraw
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// CopyFormat is the output format of CopyTo.
+type CopyFormat int
+
+const (
+	// CSV writes comma-separated values with a header line.
+	CSV CopyFormat = iota
+	// Text writes COPY's tab-separated text format.
+	Text
+	// Binary writes PostgreSQL's binary COPY format, which CopyFromBinary
+	// can load into another table or server without re-encoding.
+	Binary
+)
+
+func (f CopyFormat) String() string {
+	switch f {
+	case CSV:
+		return "csv"
+	case Text:
+		return "text"
+	case Binary:
+		return "binary"
+	default:
+		return fmt.Sprintf("CopyFormat(%d)", int(f))
+	}
+}
+
+// options returns the COPY options clause for f.
+func (f CopyFormat) options() (string, error) {
+	switch f {
+	case CSV:
+		return "(FORMAT csv, HEADER true)", nil
+	case Text:
+		return "(FORMAT text)", nil
+	case Binary:
+		return "(FORMAT binary)", nil
+	default:
+		return "", fmt.Errorf("unsupported copy format %s", f)
+	}
+}
+
+// CopyTo streams the result of query out of tx into w in format, with
+// COPY (query) TO STDOUT on the raw connection, and returns the number of
+// rows written. Use "TABLE name" as query to export a whole table.
+//
+// Because the export runs inside tx, it sees tx's snapshot, including tx's
+// own uncommitted writes. Begin tx with sql.LevelRepeatableRead to get one
+// consistent snapshot across several exports and the writes around them.
+func CopyTo(ctx context.Context, tx txraw.RawTx, query string, w io.Writer, format CopyFormat) (int64, error) {
+	options, err := format.options()
+	if err != nil {
+		return 0, err
+	}
+	sql := fmt.Sprintf("COPY (%s) TO STDOUT WITH %s", query, options)
+
+	var copied int64
+	err = RawPgconn(ctx, tx, func(conn *pgconn.PgConn) error {
+		tag, err := conn.CopyTo(ctx, w, sql)
+		if err != nil {
+			return fmt.Errorf("COPY TO failed: %w", err)
+		}
+		copied = tag.RowsAffected()
+		return nil
+	})
+	return copied, err
+}
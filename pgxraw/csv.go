@@ -0,0 +1,247 @@
+package pgxraw
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// CSVOptions configures CopyFromCSV.
+type CSVOptions struct {
+	// Comma is the field delimiter. Zero means ','.
+	Comma rune
+	// Mapping renames CSV header names to table columns. Headers missing
+	// from Mapping are used as column names unchanged; headers mapped to "-"
+	// are skipped.
+	Mapping map[string]string
+	// Null is the field value loaded as NULL. As with COPY's CSV format it
+	// only matches unquoted fields, so with the default, "", an empty
+	// field loads NULL while a quoted empty string ("") loads ''.
+	Null string
+	// OverrideIdentity loads identity columns from the input, the way INSERT
+	// ... OVERRIDING SYSTEM VALUE would, instead of leaving them out for the
+	// server to fill in. Stored generated columns are always left out.
+	OverrideIdentity bool
+}
+
+// CopyFromCSV streams CSV from r into table within tx with pgx.CopyFrom and
+// returns the number of rows copied. The first record is the header naming
+// the columns (see CSVOptions.Mapping); identity and generated columns are
+// left out for the server to fill in (see CSVOptions.OverrideIdentity).
+//
+// Fields are converted to the destination column types by parsing them in
+// PostgreSQL's text format with pgx's codecs, so anything COPY ... CSV would
+// accept for a built-in type (numbers, timestamps, booleans, arrays, JSON)
+// works here too. Records are read and sent one at a time, so the input is
+// never held in memory as a whole. A record with more or fewer fields than
+// the header fails the copy.
+func CopyFromCSV(ctx context.Context, tx txraw.RawTx, table string, r io.Reader, opts CSVOptions) (int64, error) {
+	if err := txraw.CheckWritable(tx, "CopyFromCSV into "+table); err != nil {
+		return 0, err
+	}
+	reader := &csvReader{r: bufio.NewReader(r), comma: ','}
+	if opts.Comma != 0 {
+		reader.comma = opts.Comma
+	}
+
+	header, _, err := reader.Read()
+	if err != nil {
+		return 0, fmt.Errorf("reading CSV header failed: %w", err)
+	}
+
+	// fields[i] is the CSV field index of column i.
+	var columns []string
+	var fields []int
+	for i, name := range header {
+		column := name
+		if mapped, ok := opts.Mapping[name]; ok {
+			column = mapped
+		}
+		if column == "-" {
+			continue
+		}
+		columns = append(columns, column)
+		fields = append(fields, i)
+	}
+	if len(columns) == 0 {
+		return 0, errors.New("CSV header maps to no columns")
+	}
+
+	var copied int64
+	err = tx.RawContext(ctx, txraw.UnwrapAs(func(conn *pgx.Conn) error {
+		keep, err := LoadableColumns(ctx, conn, table, columns, overrideAll(opts.OverrideIdentity))
+		if err != nil {
+			return err
+		}
+		columns, fields = pick(columns, keep), pick(fields, keep)
+
+		types, err := columnTypes(ctx, conn, table, columns)
+		if err != nil {
+			return err
+		}
+
+		src := pgx.CopyFromFunc(func() ([]any, error) {
+			record, quoted, err := reader.ReadN(len(header))
+			if errors.Is(err, io.EOF) {
+				return nil, nil
+			}
+			if err != nil {
+				return nil, fmt.Errorf("reading CSV failed: %w", err)
+			}
+
+			values := make([]any, len(columns))
+			for i, field := range fields {
+				if !quoted[field] && record[field] == opts.Null {
+					continue
+				}
+				values[i], err = types[i].Codec.DecodeValue(conn.TypeMap(), types[i].OID, pgtype.TextFormatCode, []byte(record[field]))
+				if err != nil {
+					return nil, fmt.Errorf("CSV line %d, column %s: %w", reader.start, columns[i], err)
+				}
+			}
+			return values, nil
+		})
+
+		copied, err = conn.CopyFrom(ctx, pgx.Identifier(strings.Split(table, ".")), columns, src)
+		if err != nil {
+			return fmt.Errorf("CopyFrom into %s failed: %w", table, err)
+		}
+		return nil
+	}))
+	return copied, err
+}
+
+// columnTypes returns the pgx type of each of columns of table, in order.
+func columnTypes(ctx context.Context, conn *pgx.Conn, table string, columns []string) ([]*pgtype.Type, error) {
+	described, err := describeTable(ctx, conn, table)
+	if err != nil {
+		return nil, err
+	}
+	oids := make(map[string]uint32, len(described))
+	for _, c := range described {
+		oids[c.Name] = c.OID
+	}
+
+	types := make([]*pgtype.Type, len(columns))
+	for i, column := range columns {
+		oid, ok := oids[column]
+		if !ok {
+			return nil, fmt.Errorf("table %s has no column %q", table, column)
+		}
+		if types[i], ok = conn.TypeMap().TypeForOID(oid); !ok {
+			return nil, fmt.Errorf("column %s of %s has a type (oid %d) pgx cannot convert from text", column, table, oid)
+		}
+	}
+	return types, nil
+}
+
+// csvReader reads CSV records like encoding/csv, which cannot tell a quoted
+// field from an unquoted one. COPY's CSV format needs that distinction to
+// keep a quoted empty string apart from NULL, so Read reports it per field.
+type csvReader struct {
+	r     *bufio.Reader
+	comma rune
+	// line is the number of the last input line read, and start that of
+	// the first line of the last record read, for errors.
+	line  int
+	start int
+}
+
+// Read returns the next record and, for each field, whether it was quoted.
+// Empty lines are skipped, as encoding/csv does. At the end of the input it
+// returns io.EOF.
+func (c *csvReader) Read() (fields []string, quoted []bool, err error) {
+	line := ""
+	for line == "" {
+		if line, err = c.readLine(); err != nil {
+			return nil, nil, err
+		}
+	}
+	start := c.line
+	c.start = start
+
+	// line holds the rest of the record, starting at a field.
+	for {
+		if !strings.HasPrefix(line, `"`) {
+			field, rest, more := strings.Cut(line, string(c.comma))
+			if strings.Contains(field, `"`) {
+				return nil, nil, fmt.Errorf("line %d: bare quote in unquoted field", c.line)
+			}
+			fields, quoted = append(fields, field), append(quoted, false)
+			if !more {
+				return fields, quoted, nil
+			}
+			line = rest
+			continue
+		}
+
+		// A quoted field runs to the next quote that is not doubled, which
+		// may be on a later line.
+		var field strings.Builder
+		line = line[1:]
+		for {
+			i := strings.IndexByte(line, '"')
+			if i < 0 {
+				field.WriteString(line)
+				field.WriteByte('\n')
+				if line, err = c.readLine(); errors.Is(err, io.EOF) {
+					return nil, nil, fmt.Errorf("line %d: unterminated quoted field", start)
+				} else if err != nil {
+					return nil, nil, err
+				}
+				continue
+			}
+			field.WriteString(line[:i])
+			line = line[i+1:]
+			if !strings.HasPrefix(line, `"`) {
+				break
+			}
+			field.WriteByte('"')
+			line = line[1:]
+		}
+		fields, quoted = append(fields, field.String()), append(quoted, true)
+
+		if line == "" {
+			return fields, quoted, nil
+		}
+		r, size := utf8.DecodeRuneInString(line)
+		if r != c.comma {
+			return nil, nil, fmt.Errorf("line %d: unexpected %q after quoted field", c.line, r)
+		}
+		line = line[size:]
+	}
+}
+
+// ReadN is Read for a record that must have n fields, as every record after
+// the header must. Loading a short record with NULLs for the missing fields
+// would hide malformed input, so any other count is an error.
+func (c *csvReader) ReadN(n int) (fields []string, quoted []bool, err error) {
+	if fields, quoted, err = c.Read(); err != nil {
+		return nil, nil, err
+	}
+	if len(fields) != n {
+		return nil, nil, fmt.Errorf("CSV line %d: %d fields, header has %d", c.start, len(fields), n)
+	}
+	return fields, quoted, nil
+}
+
+// readLine returns the next input line without its line ending, or io.EOF
+// at the end of the input.
+func (c *csvReader) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+		return "", err
+	}
+	c.line++
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), nil
+}
@@ -0,0 +1,78 @@
+package pgxraw
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newCSVReader(input string) *csvReader {
+	return &csvReader{r: bufio.NewReader(strings.NewReader(input)), comma: ','}
+}
+
+func TestCSVReaderQuoted(t *testing.T) {
+	reader := newCSVReader("a,b,c\n,\"\",x\n")
+	if _, _, err := reader.Read(); err != nil {
+		t.Fatal(err)
+	}
+	fields, quoted, err := reader.ReadN(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"", "", "x"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("fields = %q, want %q", fields, want)
+	}
+	if want := []bool{false, true, false}; !reflect.DeepEqual(quoted, want) {
+		t.Errorf("quoted = %v, want %v", quoted, want)
+	}
+}
+
+func TestCSVReaderShortRecord(t *testing.T) {
+	reader := newCSVReader("id,name,comment\n1,alpha,first\n2,beta\n")
+	if _, _, err := reader.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := reader.ReadN(3); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err := reader.ReadN(3)
+	if err == nil || err.Error() != "CSV line 3: 2 fields, header has 3" {
+		t.Errorf("short record: err = %v, want the line and field counts", err)
+	}
+}
+
+func TestCSVReaderMultilineField(t *testing.T) {
+	reader := newCSVReader("id,note\n1,\"spans\nthree\nlines\"\n2,short\n3\n")
+	if _, _, err := reader.Read(); err != nil {
+		t.Fatal(err)
+	}
+
+	fields, _, err := reader.ReadN(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields[1] != "spans\nthree\nlines" {
+		t.Errorf("multi-line field = %q", fields[1])
+	}
+	if reader.start != 2 {
+		t.Errorf("multi-line record starts on line %d, want 2", reader.start)
+	}
+
+	if _, _, err := reader.ReadN(2); err != nil {
+		t.Fatal(err)
+	}
+	if reader.start != 5 {
+		t.Errorf("record after the multi-line one starts on line %d, want 5", reader.start)
+	}
+
+	_, _, err = reader.ReadN(2)
+	if err == nil || err.Error() != "CSV line 6: 1 fields, header has 2" {
+		t.Errorf("short record after a multi-line one: err = %v, want line 6", err)
+	}
+	if _, _, err := reader.ReadN(2); !errors.Is(err, io.EOF) {
+		t.Errorf("at the end of the input: err = %v, want io.EOF", err)
+	}
+}
@@ -0,0 +1,84 @@
+package pgxraw
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// DefaultCursorBatch is the number of rows QueryCursor fetches at a time
+// when batch is not positive.
+const DefaultCursorBatch = 1000
+
+// cursorSeq numbers the cursors declared by QueryCursor, so that several can
+// be open in one transaction.
+var cursorSeq atomic.Uint64
+
+// QueryCursor reads the result of query inside tx through a server-side
+// cursor, batch rows at a time, and yields every row as a T, matching columns
+// to struct fields like QueryStructs. It never holds more than one batch in
+// memory, so it suits result sets of any size, and unlike QueryStructs it
+// yields only between fetches, with the connection free: the loop body may
+// use tx, for instance to write rows derived from the ones it reads.
+//
+// args are interpolated into query by pgx on the client, since DECLARE
+// takes no bind parameters. The cursor is closed when iteration ends;
+// iteration stops at the first error, which is yielded with the zero T.
+func QueryCursor[T any](ctx context.Context, tx txraw.RawTx, query string, batch int, args ...any) iter.Seq2[T, error] {
+	if batch <= 0 {
+		batch = DefaultCursorBatch
+	}
+	return func(yield func(T, error) bool) {
+		var zero T
+		name := pgx.Identifier{fmt.Sprintf("pgxraw_cursor_%d", cursorSeq.Add(1))}.Sanitize()
+
+		err := tx.RawContext(ctx, txraw.UnwrapAs(func(conn *pgx.Conn) error {
+			_, err := conn.Exec(ctx, "DECLARE "+name+" NO SCROLL CURSOR FOR "+query, append([]any{pgx.QueryExecModeSimpleProtocol}, args...)...)
+			if err != nil {
+				return fmt.Errorf("declaring cursor failed: %w", err)
+			}
+			return nil
+		}))
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		// Closing fails only if the transaction is aborted, which the
+		// caller learns from its next statement anyway.
+		defer tx.ExecContext(context.WithoutCancel(ctx), "CLOSE "+name)
+
+		fetch := fmt.Sprintf("FETCH FORWARD %d FROM %s", batch, name)
+		for {
+			var rows []T
+			err := tx.RawContext(ctx, txraw.UnwrapAs(func(conn *pgx.Conn) error {
+				result, err := conn.Query(ctx, fetch)
+				if err != nil {
+					return fmt.Errorf("fetching from cursor failed: %w", err)
+				}
+				rows, err = pgx.CollectRows(result, pgx.RowToStructByName[T])
+				if err != nil {
+					return fmt.Errorf("scanning rows into %T failed: %w", zero, err)
+				}
+				return nil
+			}))
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+
+			for _, v := range rows {
+				if !yield(v, nil) {
+					return
+				}
+			}
+			if len(rows) < batch {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package pgxraw
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// Column describes a column of a table, as read from the system catalogs.
+type Column struct {
+	Name string
+	// Type is the SQL type as PostgreSQL prints it, e.g. "character
+	// varying(255)" or "timestamp with time zone".
+	Type string
+	// OID is the type's OID, for looking up pgx's codec.
+	OID uint32
+	// NotNull reports a NOT NULL constraint.
+	NotNull bool
+	// HasDefault reports a DEFAULT expression, e.g. a serial's nextval.
+	HasDefault bool
+	// Identity reports an identity column, GENERATED ALWAYS or BY DEFAULT.
+	Identity bool
+	// Generated reports a stored generated column, which cannot be written.
+	Generated bool
+}
+
+// DescribeTable returns the columns of table, in table order, read on tx's
+// raw connection, so it sees tables created earlier in the same transaction.
+func DescribeTable(ctx context.Context, tx txraw.RawTx, table string) ([]Column, error) {
+	var columns []Column
+	err := tx.RawContext(ctx, txraw.UnwrapAs(func(conn *pgx.Conn) error {
+		var err error
+		columns, err = describeTable(ctx, conn, table)
+		return err
+	}))
+	return columns, err
+}
+
+func describeTable(ctx context.Context, conn *pgx.Conn, table string) ([]Column, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT attname, format_type(atttypid, atttypmod), atttypid, attnotnull, atthasdef,
+			attidentity <> '', attgenerated <> ''
+		FROM pg_attribute
+		WHERE attrelid = $1::regclass AND attnum > 0 AND NOT attisdropped
+		ORDER BY attnum`, table)
+	if err != nil {
+		return nil, fmt.Errorf("reading columns of %s failed: %w", table, err)
+	}
+	var columns []Column
+	var c Column
+	_, err = pgx.ForEachRow(rows, []any{&c.Name, &c.Type, &c.OID, &c.NotNull, &c.HasDefault, &c.Identity, &c.Generated}, func() error {
+		columns = append(columns, c)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading columns of %s failed: %w", table, err)
+	}
+	return columns, nil
+}
+
+// AutoMap matches source field names to columns in two passes: every field
+// that names a column exactly takes it first, then the remaining fields are
+// matched to the remaining columns ignoring case and any characters other
+// than letters and digits, so "CreatedAt", "created-at" and "CREATED_AT" all
+// find created_at unless a field named created_at took it. Each column is
+// matched once, in the second pass by the first field to find it.
+// mapping[i] is the column for fields[i], or "" if none matched; unmapped
+// lists those fields.
+func AutoMap(columns []Column, fields []string) (mapping []string, unmapped []string) {
+	exact := make(map[string]bool, len(columns))
+	loose := make(map[string][]string, len(columns))
+	for _, c := range columns {
+		exact[c.Name] = true
+		key := looseName(c.Name)
+		loose[key] = append(loose[key], c.Name)
+	}
+
+	mapping = make([]string, len(fields))
+	taken := make(map[string]bool, len(fields))
+	for i, field := range fields {
+		if exact[field] && !taken[field] {
+			mapping[i] = field
+			taken[field] = true
+		}
+	}
+	for i, field := range fields {
+		if mapping[i] != "" {
+			continue
+		}
+		for _, column := range loose[looseName(field)] {
+			if !taken[column] {
+				mapping[i] = column
+				taken[column] = true
+				break
+			}
+		}
+		if mapping[i] == "" {
+			unmapped = append(unmapped, field)
+		}
+	}
+	return mapping, unmapped
+}
+
+// looseName folds name for AutoMap's second pass.
+func looseName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+// MappedResult reports what CopyFromMapped loaded.
+type MappedResult struct {
+	// Copied is the number of rows copied.
+	Copied int64
+	// Mapping maps each loaded source field to its column.
+	Mapping map[string]string
+	// Unmapped are the source fields matching no column. Their values are
+	// dropped.
+	Unmapped []string
+	// Skipped are the source fields matching identity or generated columns,
+	// left for the server to fill in.
+	Skipped []string
+}
+
+// CopyFromMapped copies the rows of src, whose values are named by fields,
+// into table within tx with pgx.CopyFrom. The fields are matched to the
+// table's columns with AutoMap; fields that match no column, or an identity
+// or generated one, are left out and reported in the result instead of
+// failing the load.
+//
+// Values are coerced to their column's type where pgx would not convert
+// them itself: strings are parsed in PostgreSQL's text format for non-text
+// columns, so "42" loads into an integer and "2024-01-02" into a date, and
+// numbers, booleans and times are formatted for text columns.
+func CopyFromMapped(ctx context.Context, tx txraw.RawTx, table string, fields []string, src pgx.CopyFromSource) (MappedResult, error) {
+	var result MappedResult
+	if err := txraw.CheckWritable(tx, "CopyFromMapped into "+table); err != nil {
+		return result, err
+	}
+	err := tx.RawContext(ctx, txraw.UnwrapAs(func(conn *pgx.Conn) error {
+		described, err := describeTable(ctx, conn, table)
+		if err != nil {
+			return err
+		}
+		byName := make(map[string]Column, len(described))
+		for _, c := range described {
+			byName[c.Name] = c
+		}
+
+		mapping, unmapped := AutoMap(described, fields)
+		result.Unmapped = unmapped
+		result.Mapping = make(map[string]string)
+
+		// indices[i] is the source value index of columns[i].
+		var columns []string
+		var indices []int
+		var types []*pgtype.Type
+		for i, column := range mapping {
+			if column == "" {
+				continue
+			}
+			c := byName[column]
+			if c.Identity || c.Generated {
+				result.Skipped = append(result.Skipped, fields[i])
+				continue
+			}
+			typ, ok := conn.TypeMap().TypeForOID(c.OID)
+			if !ok {
+				return fmt.Errorf("column %s of %s has a type (%s) pgx cannot convert", column, table, c.Type)
+			}
+			result.Mapping[fields[i]] = column
+			columns = append(columns, column)
+			indices = append(indices, i)
+			types = append(types, typ)
+		}
+		if len(columns) == 0 {
+			return fmt.Errorf("no source fields map to columns of %s", table)
+		}
+
+		row := 0
+		mapped := pgx.CopyFromFunc(func() ([]any, error) {
+			if !src.Next() {
+				return nil, src.Err()
+			}
+			row++
+			source, err := src.Values()
+			if err != nil {
+				return nil, err
+			}
+			if len(source) != len(fields) {
+				return nil, fmt.Errorf("row %d has %d values for %d fields", row, len(source), len(fields))
+			}
+			values := make([]any, len(columns))
+			for i, index := range indices {
+				if values[i], err = coerceValue(conn.TypeMap(), types[i], source[index]); err != nil {
+					return nil, fmt.Errorf("row %d, field %s: %w", row, fields[index], err)
+				}
+			}
+			return values, nil
+		})
+
+		result.Copied, err = conn.CopyFrom(ctx, pgx.Identifier(strings.Split(table, ".")), columns, mapped)
+		if err != nil {
+			return fmt.Errorf("CopyFrom into %s failed: %w", table, err)
+		}
+		return nil
+	}))
+	return result, err
+}
+
+// coerceValue converts value for a column of type typ where pgx would not:
+// strings into non-text types and scalars into text types.
+func coerceValue(m *pgtype.Map, typ *pgtype.Type, value any) (any, error) {
+	text := isTextType(typ.OID)
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if text || typ.OID == pgtype.JSONOID || typ.OID == pgtype.JSONBOID {
+			return v, nil
+		}
+		return typ.Codec.DecodeValue(m, typ.OID, pgtype.TextFormatCode, []byte(v))
+	case time.Time:
+		if text {
+			return v.Format(time.RFC3339Nano), nil
+		}
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		if text {
+			return fmt.Sprint(v), nil
+		}
+	}
+	return value, nil
+}
+
+func isTextType(oid uint32) bool {
+	switch oid {
+	case pgtype.TextOID, pgtype.VarcharOID, pgtype.BPCharOID, pgtype.NameOID:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,37 @@
+package pgxraw
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAutoMap(t *testing.T) {
+	columns := []Column{{Name: "id"}, {Name: "created_at"}, {Name: "name"}}
+	for _, tt := range []struct {
+		fields       []string
+		wantMapping  []string
+		wantUnmapped []string
+	}{
+		{
+			fields:      []string{"ID", "CreatedAt", "Name"},
+			wantMapping: []string{"id", "created_at", "name"},
+		},
+		{
+			// The exact match wins even though the loose one comes first.
+			fields:       []string{"CreatedAt", "created_at", "id"},
+			wantMapping:  []string{"", "created_at", "id"},
+			wantUnmapped: []string{"CreatedAt"},
+		},
+		{
+			fields:       []string{"created-at", "CREATED_AT", "extra"},
+			wantMapping:  []string{"created_at", "", ""},
+			wantUnmapped: []string{"CREATED_AT", "extra"},
+		},
+	} {
+		mapping, unmapped := AutoMap(columns, tt.fields)
+		if !reflect.DeepEqual(mapping, tt.wantMapping) || !reflect.DeepEqual(unmapped, tt.wantUnmapped) {
+			t.Errorf("AutoMap(%q) = %q, %q; want %q, %q",
+				tt.fields, mapping, unmapped, tt.wantMapping, tt.wantUnmapped)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package pgxraw
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// DryRunOptions configures DryRun.
+type DryRunOptions struct {
+	// CopyToTemp also copies the rows that pass validation into a temporary
+	// table shaped like the target, with its defaults and CHECK and NOT NULL
+	// constraints, so the server checks them too. The table disappears with
+	// the dry run's transaction.
+	CopyToTemp bool
+	// MaxErrors caps the number of RowErrors kept; rows beyond it are still
+	// counted as invalid. Zero means 100, negative means no cap.
+	MaxErrors int
+	// Validators, if set, also run on every row, after the built-in checks.
+	// Their policy is ignored: a dry run always reports every invalid row.
+	Validators *Validators
+}
+
+// DryRunResult reports what a load would have done.
+type DryRunResult struct {
+	// Rows is the number of rows the source produced.
+	Rows int64
+	// Valid is the number of rows that passed validation.
+	Valid int64
+	// Errors are the rejected rows, in source order, up to MaxErrors.
+	Errors []RowError
+	// Copied is the number of valid rows copied into the temporary table
+	// with CopyToTemp.
+	Copied int64
+	// CopyErr is the server's rejection of the copy into the temporary
+	// table, e.g. a CHECK constraint violation. COPY stops at the first such
+	// row; its position is in the error's context.
+	CopyErr error
+}
+
+// Invalid returns the number of rejected rows.
+func (r DryRunResult) Invalid() int64 {
+	return r.Rows - r.Valid
+}
+
+// DryRun streams src as a load of columns of table would, validating each
+// row the way pgx.CopyFrom would convert it, without writing to table. Wrap
+// src first (e.g. with Transforms.Source or WithProgress) to run those
+// stages too.
+//
+// A row is rejected if it has the wrong number of values, a NULL for a NOT
+// NULL column, a value pgx cannot encode as its column's type, or fails one
+// of opts.Validators. With opts.CopyToTemp the valid rows are also copied for
+// the server to check. Everything runs in a transaction of its own that is
+// always rolled back.
+//
+// The returned error is for failures of the dry run itself, such as the
+// source failing; rejected rows are reported in the result.
+func DryRun(ctx context.Context, db *sql.DB, table string, columns []string, src pgx.CopyFromSource, opts DryRunOptions) (DryRunResult, error) {
+	var result DryRunResult
+
+	tx, err := txraw.Begin(ctx, db, nil)
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	err = tx.RawContext(ctx, txraw.UnwrapAs(func(conn *pgx.Conn) error {
+		types, err := columnTypes(ctx, conn, table, columns)
+		if err != nil {
+			return err
+		}
+		notNull, err := notNullColumns(ctx, conn, table)
+		if err != nil {
+			return err
+		}
+
+		validators := NewValidators(SkipInvalid).Add(func(values []any) error {
+			if len(values) != len(columns) {
+				return fmt.Errorf("row has %d values for %d columns", len(values), len(columns))
+			}
+			for i, value := range values {
+				if value == nil {
+					if notNull[columns[i]] {
+						return fmt.Errorf("column %s: NULL in a NOT NULL column", columns[i])
+					}
+					continue
+				}
+				if _, err := conn.TypeMap().Encode(types[i].OID, pgtype.BinaryFormatCode, value, nil); err != nil {
+					return fmt.Errorf("column %s: %w", columns[i], err)
+				}
+			}
+			return nil
+		})
+		if opts.Validators != nil {
+			validators.Add(opts.Validators.Check)
+		}
+		validators.MaxErrors = opts.MaxErrors
+		valid, report := validators.Source(src)
+		defer func() {
+			result.Rows, result.Valid, result.Errors = report.Rows, report.Valid, report.Errors
+		}()
+
+		if !opts.CopyToTemp {
+			for valid.Next() {
+			}
+			return valid.Err()
+		}
+
+		temp := fmt.Sprintf("txraw_dryrun_%d", stagingSeq.Add(1))
+		_, err = conn.Exec(ctx, fmt.Sprintf("CREATE TEMP TABLE %s (LIKE %s INCLUDING DEFAULTS INCLUDING CONSTRAINTS)",
+			temp, pgx.Identifier(strings.Split(table, ".")).Sanitize()))
+		if err != nil {
+			return fmt.Errorf("creating temporary table for %s failed: %w", table, err)
+		}
+		result.Copied, result.CopyErr = conn.CopyFrom(ctx, pgx.Identifier{temp}, columns, valid)
+		if err := valid.Err(); err != nil {
+			// The source failed, not the server.
+			result.CopyErr = nil
+			return err
+		}
+		return nil
+	}))
+	if err != nil {
+		return result, fmt.Errorf("dry run of %s failed: %w", table, err)
+	}
+	return result, nil
+}
+
+// notNullColumns returns which columns of table are NOT NULL.
+func notNullColumns(ctx context.Context, conn *pgx.Conn, table string) (map[string]bool, error) {
+	described, err := describeTable(ctx, conn, table)
+	if err != nil {
+		return nil, err
+	}
+	notNull := make(map[string]bool, len(described))
+	for _, c := range described {
+		notNull[c.Name] = c.NotNull
+	}
+	return notNull, nil
+}
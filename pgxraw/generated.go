@@ -0,0 +1,68 @@
+package pgxraw
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// serverColumns returns the columns of table whose values the server fills
+// in: identity columns, mapped to true, and stored generated columns, mapped
+// to false.
+func serverColumns(ctx context.Context, conn *pgx.Conn, table string) (map[string]bool, error) {
+	described, err := describeTable(ctx, conn, table)
+	if err != nil {
+		return nil, err
+	}
+	server := make(map[string]bool)
+	for _, c := range described {
+		if c.Identity || c.Generated {
+			server[c.Name] = c.Identity
+		}
+	}
+	return server, nil
+}
+
+// LoadableColumns returns the indices of the columns that a load derived
+// from its input should write, leaving out those the server fills in. It is
+// exported for loaders that live outside this package, such as parquetcopy
+// and arrowcopy, so they keep their format dependencies to themselves.
+//
+// Stored generated columns are always left out: COPY cannot write them.
+// Identity columns, GENERATED ALWAYS or BY DEFAULT, are left out too unless
+// overrideIdentity is set; COPY then writes the input's values, as INSERT
+// ... OVERRIDING SYSTEM VALUE would.
+func LoadableColumns(ctx context.Context, conn *pgx.Conn, table string, columns []string, overrideIdentity func(i int) bool) ([]int, error) {
+	server, err := serverColumns(ctx, conn, table)
+	if err != nil {
+		return nil, err
+	}
+	keep := make([]int, 0, len(columns))
+	for i, column := range columns {
+		identity, ok := server[column]
+		if ok && !(identity && overrideIdentity(i)) {
+			continue
+		}
+		keep = append(keep, i)
+	}
+	if len(keep) == 0 {
+		return nil, fmt.Errorf("all columns loaded into %s are generated by the server", table)
+	}
+	return keep, nil
+}
+
+// overrideAll returns an overrideIdentity func for LoadableColumns giving
+// the same answer for every column.
+func overrideAll(override bool) func(int) bool {
+	return func(int) bool { return override }
+}
+
+// pick returns the elements of s at indices.
+func pick[T any](s []T, indices []int) []T {
+	picked := make([]T, len(indices))
+	for i, j := range indices {
+		picked[i] = s[j]
+	}
+	return picked
+}
@@ -0,0 +1,110 @@
+package pgxraw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// Large object open modes, from PostgreSQL's libpq/libpq-fs.h.
+const (
+	loWrite = 0x20000
+	loRead  = 0x40000
+)
+
+// largeObjectChunk is the number of bytes moved per loread or lowrite call.
+const largeObjectChunk = 256 << 10
+
+// ImportLargeObject creates a large object inside tx from the contents of r
+// and returns its OID and size. pgx only offers large objects on its own
+// pgx.Tx; these functions use the same server-side lo_* functions through
+// tx, so large objects take part in a database/sql transaction and vanish
+// with it on rollback.
+func ImportLargeObject(ctx context.Context, tx txraw.RawTx, r io.Reader) (oid uint32, n int64, err error) {
+	if err := txraw.CheckWritable(tx, "ImportLargeObject"); err != nil {
+		return 0, 0, err
+	}
+	if err := tx.QueryRowContext(ctx, "SELECT lo_create(0)").Scan(&oid); err != nil {
+		return 0, 0, fmt.Errorf("lo_create failed: %w", err)
+	}
+	fd, err := openLargeObject(ctx, tx, oid, loWrite)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	buf := make([]byte, largeObjectChunk)
+	for {
+		read, readErr := io.ReadFull(r, buf)
+		if read > 0 {
+			if _, err := tx.ExecContext(ctx, "SELECT lowrite($1, $2)", fd, buf[:read]); err != nil {
+				return 0, n, fmt.Errorf("lowrite to large object %d failed: %w", oid, err)
+			}
+			n += int64(read)
+		}
+		if errors.Is(readErr, io.EOF) || errors.Is(readErr, io.ErrUnexpectedEOF) {
+			break
+		}
+		if readErr != nil {
+			return 0, n, fmt.Errorf("reading large object input failed: %w", readErr)
+		}
+	}
+	return oid, n, closeLargeObject(ctx, tx, oid, fd)
+}
+
+// ExportLargeObject writes the contents of the large object oid, read inside
+// tx, to w and returns the number of bytes written. It sees tx's snapshot,
+// including large objects tx created or changed.
+func ExportLargeObject(ctx context.Context, tx txraw.RawTx, oid uint32, w io.Writer) (int64, error) {
+	fd, err := openLargeObject(ctx, tx, oid, loRead)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int64
+	for {
+		var chunk []byte
+		if err := tx.QueryRowContext(ctx, "SELECT loread($1, $2)", fd, largeObjectChunk).Scan(&chunk); err != nil {
+			return n, fmt.Errorf("loread from large object %d failed: %w", oid, err)
+		}
+		if len(chunk) == 0 {
+			break
+		}
+		written, err := w.Write(chunk)
+		n += int64(written)
+		if err != nil {
+			return n, fmt.Errorf("writing large object %d failed: %w", oid, err)
+		}
+	}
+	return n, closeLargeObject(ctx, tx, oid, fd)
+}
+
+// UnlinkLargeObject deletes the large object oid inside tx.
+func UnlinkLargeObject(ctx context.Context, tx txraw.RawTx, oid uint32) error {
+	if err := txraw.CheckWritable(tx, "UnlinkLargeObject"); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "SELECT lo_unlink($1)", oid); err != nil {
+		return fmt.Errorf("lo_unlink of large object %d failed: %w", oid, err)
+	}
+	return nil
+}
+
+// openLargeObject opens oid in mode and returns its descriptor, which is
+// valid until closed or until tx ends.
+func openLargeObject(ctx context.Context, tx txraw.RawTx, oid uint32, mode int32) (int32, error) {
+	var fd int32
+	if err := tx.QueryRowContext(ctx, "SELECT lo_open($1, $2)", oid, mode).Scan(&fd); err != nil {
+		return 0, fmt.Errorf("lo_open of large object %d failed: %w", oid, err)
+	}
+	return fd, nil
+}
+
+func closeLargeObject(ctx context.Context, tx txraw.RawTx, oid uint32, fd int32) error {
+	if _, err := tx.ExecContext(ctx, "SELECT lo_close($1)", fd); err != nil {
+		return fmt.Errorf("lo_close of large object %d failed: %w", oid, err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package pgxraw
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// loadLockNamespace occupies the high 32 bits of the advisory lock keys taken
+// by LockTable ("txrw"), so they do not collide with application locks keyed
+// by small integers.
+const loadLockNamespace int64 = 0x74787277
+
+// ErrLocked is returned by LockTable with wait unset when another session
+// already holds the load lock of the table.
+var ErrLocked = errors.New("pgxraw: table is locked by another load")
+
+// LockTable takes a session-level advisory lock keyed on table, so that two
+// processes cannot run conflicting loads into the same table at once. The
+// lock is held on a dedicated connection taken from db, outside any load
+// transaction, until unlock is called or the process exits.
+//
+// With wait set, LockTable blocks until the lock is free or ctx is done;
+// otherwise it fails immediately with ErrLocked.
+func LockTable(ctx context.Context, db *sql.DB, table string, wait bool) (unlock func() error, err error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reserving lock connection failed: %w", err)
+	}
+
+	var oid int64
+	if err := conn.QueryRowContext(ctx, "SELECT $1::regclass::oid::bigint", table).Scan(&oid); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("resolving table %s failed: %w", table, err)
+	}
+	key := loadLockNamespace<<32 | oid
+
+	if wait {
+		_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", key)
+	} else {
+		var locked bool
+		err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&locked)
+		if err == nil && !locked {
+			err = ErrLocked
+		}
+	}
+	if err != nil {
+		conn.Close()
+		if errors.Is(err, ErrLocked) {
+			return nil, fmt.Errorf("%w: %s", ErrLocked, table)
+		}
+		return nil, fmt.Errorf("locking table %s failed: %w", table, err)
+	}
+
+	return func() error {
+		defer conn.Close()
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key); err != nil {
+			return fmt.Errorf("unlocking table %s failed: %w", table, err)
+		}
+		return nil
+	}, nil
+}
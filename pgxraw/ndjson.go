@@ -0,0 +1,184 @@
+package pgxraw
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// NDJSONOptions configures CopyFromNDJSON.
+type NDJSONOptions struct {
+	// Columns are the table columns to load. If empty, they are the keys of
+	// the first object (after Mapping), in sorted order, less identity and
+	// generated columns.
+	Columns []string
+	// Mapping renames JSON keys to table columns. Keys missing from Mapping
+	// are used as column names unchanged; keys mapped to "-" are skipped.
+	Mapping map[string]string
+	// MaxLineSize caps the length of one line. Zero means 1 MiB.
+	MaxLineSize int
+	// OverrideIdentity keeps identity columns in derived Columns, loading
+	// them from the input the way INSERT ... OVERRIDING SYSTEM VALUE would.
+	// Stored generated columns are always left out.
+	OverrideIdentity bool
+}
+
+// CopyFromNDJSON streams newline-delimited JSON objects from r into table
+// within tx with pgx.CopyFrom and returns the number of rows copied. Blank
+// lines are ignored and keys missing from an object load NULL.
+//
+// Values are converted to the destination column types: strings and numbers
+// are parsed in PostgreSQL's text format with pgx's codecs, so a timestamp
+// can arrive as a JSON string; nested objects and arrays are loaded as-is into
+// json and jsonb columns. Lines are read and sent one at a time.
+func CopyFromNDJSON(ctx context.Context, tx txraw.RawTx, table string, r io.Reader, opts NDJSONOptions) (int64, error) {
+	if err := txraw.CheckWritable(tx, "CopyFromNDJSON into "+table); err != nil {
+		return 0, err
+	}
+	maxLine := opts.MaxLineSize
+	if maxLine <= 0 {
+		maxLine = 1 << 20
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLine)
+
+	line := 0
+	next := func() (map[string]json.RawMessage, error) {
+		for scanner.Scan() {
+			line++
+			text := bytes.TrimSpace(scanner.Bytes())
+			if len(text) == 0 {
+				continue
+			}
+			var raw map[string]json.RawMessage
+			if err := json.Unmarshal(text, &raw); err != nil {
+				return nil, fmt.Errorf("NDJSON line %d: %w", line, err)
+			}
+			object := make(map[string]json.RawMessage, len(raw))
+			for key, value := range raw {
+				column := key
+				if mapped, ok := opts.Mapping[key]; ok {
+					column = mapped
+				}
+				if column != "-" {
+					object[column] = value
+				}
+			}
+			return object, nil
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("reading NDJSON failed: %w", err)
+		}
+		return nil, io.EOF
+	}
+
+	// The first object is read up front to derive the columns if needed.
+	first, err := next()
+	if errors.Is(err, io.EOF) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	columns := opts.Columns
+	derived := len(columns) == 0
+	if derived {
+		for column := range first {
+			columns = append(columns, column)
+		}
+		slices.Sort(columns)
+	}
+	if len(columns) == 0 {
+		return 0, errors.New("NDJSON objects map to no columns")
+	}
+
+	var copied int64
+	err = tx.RawContext(ctx, txraw.UnwrapAs(func(conn *pgx.Conn) error {
+		if derived {
+			keep, err := LoadableColumns(ctx, conn, table, columns, overrideAll(opts.OverrideIdentity))
+			if err != nil {
+				return err
+			}
+			columns = pick(columns, keep)
+		}
+
+		types, err := columnTypes(ctx, conn, table, columns)
+		if err != nil {
+			return err
+		}
+
+		object := first
+		src := pgx.CopyFromFunc(func() ([]any, error) {
+			var err error
+			if object == nil {
+				if object, err = next(); errors.Is(err, io.EOF) {
+					return nil, nil
+				} else if err != nil {
+					return nil, err
+				}
+			}
+			defer func() { object = nil }()
+
+			values := make([]any, len(columns))
+			for i, column := range columns {
+				raw, ok := object[column]
+				if !ok {
+					continue
+				}
+				if values[i], err = jsonValue(conn.TypeMap(), types[i], raw); err != nil {
+					return nil, fmt.Errorf("NDJSON line %d, column %s: %w", line, column, err)
+				}
+			}
+			return values, nil
+		})
+
+		copied, err = conn.CopyFrom(ctx, pgx.Identifier(strings.Split(table, ".")), columns, src)
+		if err != nil {
+			return fmt.Errorf("CopyFrom into %s failed: %w", table, err)
+		}
+		return nil
+	}))
+	return copied, err
+}
+
+// jsonValue converts a JSON value to a Go value for a column of type typ.
+func jsonValue(m *pgtype.Map, typ *pgtype.Type, raw json.RawMessage) (any, error) {
+	if typ.OID == pgtype.JSONOID || typ.OID == pgtype.JSONBOID {
+		if string(raw) == "null" {
+			return nil, nil
+		}
+		return string(raw), nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case bool:
+		return typ.Codec.DecodeValue(m, typ.OID, pgtype.TextFormatCode, []byte(strconv.FormatBool(v)))
+	case json.Number:
+		return typ.Codec.DecodeValue(m, typ.OID, pgtype.TextFormatCode, []byte(v))
+	case string:
+		return typ.Codec.DecodeValue(m, typ.OID, pgtype.TextFormatCode, []byte(v))
+	default:
+		return nil, fmt.Errorf("cannot load a JSON %T into a %s column", v, typ.Name)
+	}
+}
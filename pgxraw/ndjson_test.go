@@ -0,0 +1,35 @@
+package pgxraw
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestJSONValueBool(t *testing.T) {
+	m := pgtype.NewMap()
+	for _, tt := range []struct {
+		oid  uint32
+		raw  string
+		want any
+	}{
+		{pgtype.BoolOID, "true", true},
+		{pgtype.TextOID, "false", "false"},
+		{pgtype.VarcharOID, "true", "true"},
+		{pgtype.JSONBOID, "true", "true"},
+	} {
+		typ, ok := m.TypeForOID(tt.oid)
+		if !ok {
+			t.Fatalf("no type for oid %d", tt.oid)
+		}
+		got, err := jsonValue(m, typ, json.RawMessage(tt.raw))
+		if err != nil {
+			t.Errorf("jsonValue(%s, %s): %v", typ.Name, tt.raw, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("jsonValue(%s, %s) = %#v, want %#v", typ.Name, tt.raw, got, tt.want)
+		}
+	}
+}
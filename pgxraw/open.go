@@ -0,0 +1,78 @@
+package pgxraw
+
+import (
+	"crypto/tls"
+	"database/sql"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// Option customizes the pgx connection configuration used by Open and
+// ParseConfig.
+type Option func(*pgx.ConnConfig) error
+
+// WithDialFunc makes every connection use dial to reach the server, e.g.
+// through an SSH tunnel or a SOCKS proxy.
+func WithDialFunc(dial pgconn.DialFunc) Option {
+	return func(cc *pgx.ConnConfig) error {
+		cc.DialFunc = dial
+		return nil
+	}
+}
+
+// WithLookupFunc replaces host name resolution, e.g. to resolve names on
+// the far side of a tunnel.
+func WithLookupFunc(lookup pgconn.LookupFunc) Option {
+	return func(cc *pgx.ConnConfig) error {
+		cc.LookupFunc = lookup
+		return nil
+	}
+}
+
+// WithTLSConfig uses cfg for TLS, including for fallback hosts, e.g. for
+// mTLS with a client certificate whose key lives in hardware
+// (tls.Config.GetClientCertificate).
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(cc *pgx.ConnConfig) error {
+		cc.TLSConfig = cfg
+		for _, fallback := range cc.Fallbacks {
+			fallback.TLSConfig = cfg
+		}
+		return nil
+	}
+}
+
+// WithConfig runs fn on the parsed configuration, for settings that have no
+// dedicated Option.
+func WithConfig(fn func(*pgx.ConnConfig) error) Option {
+	return Option(fn)
+}
+
+// ParseConfig parses dsn and applies opts. The result can be passed to
+// RotatingCredentials.OpenDB or stdlib.OpenDB.
+func ParseConfig(dsn string, opts ...Option) (*pgx.ConnConfig, error) {
+	config, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("parsing connection string failed: %w", err)
+	}
+	for _, opt := range opts {
+		if err := opt(config); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// Open opens a pgx-backed *sql.DB for dsn with opts applied, so custom
+// dialers and TLS setups work with database/sql pooling, txraw's Raw and the
+// bulk helpers of this package.
+func Open(dsn string, opts ...Option) (*sql.DB, error) {
+	config, err := ParseConfig(dsn, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return stdlib.OpenDB(*config), nil
+}
@@ -0,0 +1,259 @@
+// Package parquetcopy loads Apache Parquet files into PostgreSQL inside a
+// database/sql transaction with pgx.CopyFrom. It is kept out of pgxraw so
+// that only code loading Parquet depends on parquet-go.
+package parquetcopy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+
+	"github.com/eqld/example-tx-raw/pgxraw"
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// parquetBatchSize is the number of rows read from a row group at a time.
+const parquetBatchSize = 256
+
+// Options configures CopyFrom.
+type Options struct {
+	// Mapping renames Parquet column names to table columns. Columns missing
+	// from Mapping are used unchanged; columns mapped to "-" are skipped.
+	Mapping map[string]string
+	// OverrideIdentity loads identity columns from the input, the way INSERT
+	// ... OVERRIDING SYSTEM VALUE would, instead of leaving them out for the
+	// server to fill in. Stored generated columns are always left out.
+	OverrideIdentity bool
+}
+
+// CopyFrom streams the Parquet file in r, of size bytes, into table
+// within tx with pgx.CopyFrom and returns the number of rows copied. The
+// columns of the file name the table columns (see Options.Mapping);
+// identity and generated columns are left out for the server to fill in (see
+// Options.OverrideIdentity).
+//
+// Row groups are read one batch of rows at a time, so the file is never held
+// in memory as a whole. Values are converted from their Parquet logical
+// types: strings, JSON and enums to string, dates and timestamps (including
+// legacy INT96) to time.Time, times to pgtype.Time, decimals to
+// pgtype.Numeric and UUIDs to [16]byte. Only flat schemas are supported;
+// nested groups and repeated columns are rejected.
+func CopyFrom(ctx context.Context, tx txraw.RawTx, table string, r io.ReaderAt, size int64, opts Options) (int64, error) {
+	if err := txraw.CheckWritable(tx, "parquetcopy.CopyFrom into "+table); err != nil {
+		return 0, err
+	}
+	file, err := parquet.OpenFile(r, size)
+	if err != nil {
+		return 0, fmt.Errorf("opening Parquet file failed: %w", err)
+	}
+
+	// leaves[i] is the leaf column index of table column i.
+	var columns []string
+	var leaves []int
+	var fields []parquet.Field
+	for i, field := range file.Schema().Fields() {
+		if !field.Leaf() || field.Repeated() {
+			return 0, fmt.Errorf("Parquet column %q is nested or repeated; only flat schemas are supported", field.Name())
+		}
+		column := field.Name()
+		if mapped, ok := opts.Mapping[column]; ok {
+			column = mapped
+		}
+		if column == "-" {
+			continue
+		}
+		columns = append(columns, column)
+		leaves = append(leaves, i)
+		fields = append(fields, field)
+	}
+	if len(columns) == 0 {
+		return 0, errors.New("Parquet schema maps to no columns")
+	}
+
+	groups := file.RowGroups()
+	var rows parquet.Rows
+	defer func() {
+		if rows != nil {
+			rows.Close()
+		}
+	}()
+	buf := make([]parquet.Row, parquetBatchSize)
+	var batch []parquet.Row
+	var rowNum int64
+
+	next := func() (parquet.Row, error) {
+		for len(batch) == 0 {
+			if rows == nil {
+				if len(groups) == 0 {
+					return nil, nil
+				}
+				rows = groups[0].Rows()
+				groups = groups[1:]
+			}
+			n, err := rows.ReadRows(buf)
+			batch = buf[:n]
+			if errors.Is(err, io.EOF) {
+				rows.Close()
+				rows = nil
+			} else if err != nil {
+				return nil, fmt.Errorf("reading Parquet rows failed: %w", err)
+			}
+		}
+		row := batch[0]
+		batch = batch[1:]
+		return row, nil
+	}
+
+	src := pgx.CopyFromFunc(func() ([]any, error) {
+		row, err := next()
+		if row == nil || err != nil {
+			return nil, err
+		}
+		rowNum++
+
+		// A row of a flat schema holds one value per leaf column, in order.
+		values := make([]any, len(columns))
+		for i, leaf := range leaves {
+			values[i], err = parquetValue(fields[i].Type(), row[leaf])
+			if err != nil {
+				return nil, fmt.Errorf("Parquet row %d, column %s: %w", rowNum, columns[i], err)
+			}
+		}
+		return values, nil
+	})
+
+	var copied int64
+	err = tx.RawContext(ctx, txraw.UnwrapAs(func(conn *pgx.Conn) error {
+		keep, err := pgxraw.LoadableColumns(ctx, conn, table, columns, func(int) bool { return opts.OverrideIdentity })
+		if err != nil {
+			return err
+		}
+		columns, leaves, fields = pick(columns, keep), pick(leaves, keep), pick(fields, keep)
+
+		copied, err = conn.CopyFrom(ctx, pgx.Identifier(strings.Split(table, ".")), columns, src)
+		if err != nil {
+			return fmt.Errorf("CopyFrom into %s failed: %w", table, err)
+		}
+		return nil
+	}))
+	return copied, err
+}
+
+// parquetValue converts v, of Parquet type typ, to a value pgx can encode.
+func parquetValue(typ parquet.Type, v parquet.Value) (any, error) {
+	if v.IsNull() {
+		return nil, nil
+	}
+
+	logical := typ.LogicalType()
+	switch v.Kind() {
+	case parquet.Boolean:
+		return v.Boolean(), nil
+	case parquet.Int32:
+		switch {
+		case logical == nil:
+			return v.Int32(), nil
+		case logical.Date != nil:
+			return time.Unix(int64(v.Int32())*86400, 0).UTC(), nil
+		case logical.Time != nil:
+			return parquetTime(int64(v.Int32()), &logical.Time.Unit), nil
+		case logical.Decimal != nil:
+			return parquetDecimal(big.NewInt(int64(v.Int32())), logical.Decimal), nil
+		case logical.Integer != nil && !logical.Integer.IsSigned:
+			return int64(v.Uint32()), nil
+		}
+		return v.Int32(), nil
+	case parquet.Int64:
+		switch {
+		case logical == nil:
+			return v.Int64(), nil
+		case logical.Timestamp != nil:
+			return parquetTimestamp(v.Int64(), &logical.Timestamp.Unit), nil
+		case logical.Time != nil:
+			return parquetTime(v.Int64(), &logical.Time.Unit), nil
+		case logical.Decimal != nil:
+			return parquetDecimal(big.NewInt(v.Int64()), logical.Decimal), nil
+		case logical.Integer != nil && !logical.Integer.IsSigned:
+			return v.Uint64(), nil
+		}
+		return v.Int64(), nil
+	case parquet.Int96:
+		// Legacy timestamp: nanoseconds of the day, then the Julian day.
+		i := v.Int96()
+		nanos := int64(uint64(i[1])<<32 | uint64(i[0]))
+		return time.Unix((int64(i[2])-2440588)*86400, nanos).UTC(), nil
+	case parquet.Float:
+		return v.Float(), nil
+	case parquet.Double:
+		return v.Double(), nil
+	case parquet.ByteArray, parquet.FixedLenByteArray:
+		b := v.ByteArray()
+		switch {
+		case logical == nil:
+			return append([]byte(nil), b...), nil
+		case logical.UTF8 != nil, logical.Json != nil, logical.Enum != nil:
+			return string(b), nil
+		case logical.UUID != nil && len(b) == 16:
+			return [16]byte(b), nil
+		case logical.Decimal != nil:
+			return parquetDecimal(signedBigEndian(b), logical.Decimal), nil
+		}
+		return append([]byte(nil), b...), nil
+	}
+	return nil, fmt.Errorf("unsupported Parquet type %s", typ)
+}
+
+// parquetTimestamp converts a timestamp of unit since the Unix epoch.
+func parquetTimestamp(n int64, unit *format.TimeUnit) time.Time {
+	switch {
+	case unit.Millis != nil:
+		return time.UnixMilli(n).UTC()
+	case unit.Micros != nil:
+		return time.UnixMicro(n).UTC()
+	default:
+		return time.Unix(0, n).UTC()
+	}
+}
+
+// parquetTime converts a time of day of unit since midnight.
+func parquetTime(n int64, unit *format.TimeUnit) pgtype.Time {
+	switch {
+	case unit.Millis != nil:
+		n *= 1000
+	case unit.Nanos != nil:
+		n /= 1000
+	}
+	return pgtype.Time{Microseconds: n, Valid: true}
+}
+
+// parquetDecimal returns the decimal with unscaled value unscaled.
+func parquetDecimal(unscaled *big.Int, decimal *format.DecimalType) pgtype.Numeric {
+	return pgtype.Numeric{Int: unscaled, Exp: -decimal.Scale, Valid: true}
+}
+
+// signedBigEndian decodes b as a big-endian two's complement integer.
+func signedBigEndian(b []byte) *big.Int {
+	n := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+	}
+	return n
+}
+
+// pick returns the elements of s at indices.
+func pick[T any](s []T, indices []int) []T {
+	picked := make([]T, len(indices))
+	for i, j := range indices {
+		picked[i] = s[j]
+	}
+	return picked
+}
@@ -0,0 +1,293 @@
+package pgxraw
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// ErrInDoubt is returned by CopyFromPartitions when some partitions committed
+// and the commit of others failed. Their prepared transactions keep their
+// rows and locks until they are resolved with COMMIT PREPARED; see
+// InDoubtError.
+var ErrInDoubt = errors.New("pgxraw: prepared transactions left in doubt")
+
+// InDoubtError lists the prepared transactions CopyFromPartitions could not
+// commit. The decision to commit was already made, so they should be
+// finished with COMMIT PREPARED 'gid' once the cause is fixed. It matches
+// ErrInDoubt with errors.Is.
+type InDoubtError struct {
+	GIDs []string
+	Err  error
+}
+
+func (e *InDoubtError) Error() string {
+	return fmt.Sprintf("%v: %s: %v", ErrInDoubt, strings.Join(e.GIDs, ", "), e.Err)
+}
+
+func (e *InDoubtError) Unwrap() []error {
+	return []error{ErrInDoubt, e.Err}
+}
+
+// PartitionRouter returns the partition a row belongs in, as it is named by
+// ListPartitions.
+type PartitionRouter func(row []any) (partition string, err error)
+
+// PartitionOptions configures CopyFromPartitions.
+type PartitionOptions struct {
+	// Route assigns rows to partitions. It is required: routing on the
+	// client is what lets the partitions load in parallel.
+	Route PartitionRouter
+	// Buffer is the number of rows queued for each partition's worker. Zero
+	// means 256.
+	Buffer int
+	// GIDPrefix starts the global transaction IDs of the prepared
+	// transactions. Empty means "txraw".
+	GIDPrefix string
+}
+
+// PartitionResult is the outcome for one partition.
+type PartitionResult struct {
+	Partition string
+	Rows      int64
+	// Duration is the time the partition's COPY took.
+	Duration  time.Duration
+	Committed bool
+	Err       error
+}
+
+// ListPartitions returns the leaf partitions of the partitioned table as
+// unquoted "schema.name", sorted.
+func ListPartitions(ctx context.Context, db *sql.DB, table string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT n.nspname || '.' || c.relname AS name
+		FROM pg_partition_tree($1::regclass) t
+		JOIN pg_class c ON c.oid = t.relid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE t.isleaf AND t.relid <> $1::regclass
+		ORDER BY name`, table)
+	if err != nil {
+		return nil, fmt.Errorf("listing partitions of %s failed: %w", table, err)
+	}
+	defer rows.Close()
+	var partitions []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		partitions = append(partitions, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("listing partitions of %s failed: %w", table, err)
+	}
+	if len(partitions) == 0 {
+		return nil, fmt.Errorf("%s has no partitions", table)
+	}
+	return partitions, nil
+}
+
+// partitionWorker copies the rows routed to one partition in its own
+// transaction.
+type partitionWorker struct {
+	rows   chan []any
+	src    *ChannelSource
+	tx     *txraw.Tx
+	result PartitionResult
+}
+
+// CopyFromPartitions loads src into the partitions of the partitioned table
+// in parallel: opts.Route sends each row to a worker for its partition, and
+// every worker copies straight into its partition in its own transaction on
+// its own connection, so db must allow one connection per partition loaded.
+//
+// The outcome is all-or-nothing through two-phase commit: once every COPY has
+// succeeded, each transaction is prepared with PREPARE TRANSACTION, and only
+// if all of them prepared are they committed with COMMIT PREPARED; otherwise
+// all are rolled back. This needs max_prepared_transactions on the server to
+// be at least the number of partitions. A commit failing after others went
+// through leaves the rest prepared, reported as an *InDoubtError.
+//
+// Results are returned per partition that received rows, sorted by
+// partition, including on error.
+func CopyFromPartitions(ctx context.Context, db *sql.DB, table string, columns []string, src pgx.CopyFromSource, opts PartitionOptions) ([]PartitionResult, error) {
+	if opts.Route == nil {
+		return nil, errors.New("CopyFromPartitions needs a PartitionOptions.Route")
+	}
+	buffer := opts.Buffer
+	if buffer <= 0 {
+		buffer = 256
+	}
+	prefix := opts.GIDPrefix
+	if prefix == "" {
+		prefix = "txraw"
+	}
+
+	partitions, err := ListPartitions(ctx, db, table)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var wg sync.WaitGroup
+	workers := make(map[string]*partitionWorker)
+	start := func(partition string) *partitionWorker {
+		w := &partitionWorker{rows: make(chan []any, buffer), result: PartitionResult{Partition: partition}}
+		w.src = CopyFromChannel(ctx, w.rows)
+		workers[partition] = w
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := w.copy(ctx, db, columns); err != nil {
+				w.result.Err = err
+				// Stop the dispatcher and the other workers.
+				cancel(fmt.Errorf("partition %s: %w", partition, err))
+			}
+		}()
+		return w
+	}
+
+	// Phase 1: dispatch the rows to the workers.
+	dispatchErr := func() error {
+		for src.Next() {
+			values, err := src.Values()
+			if err != nil {
+				return err
+			}
+			partition, err := opts.Route(values)
+			if err != nil {
+				return fmt.Errorf("routing row failed: %w", err)
+			}
+			w, ok := workers[partition]
+			if !ok {
+				if !slices.Contains(partitions, partition) {
+					return fmt.Errorf("routed row to %q, which is not a partition of %s", partition, table)
+				}
+				w = start(partition)
+			}
+			// Sources may reuse the values slice for the next row.
+			select {
+			case w.rows <- slices.Clone(values):
+			case <-ctx.Done():
+				return context.Cause(ctx)
+			}
+		}
+		return src.Err()
+	}()
+	for _, w := range workers {
+		if dispatchErr != nil {
+			w.src.Fail(dispatchErr)
+		}
+		close(w.rows)
+	}
+	wg.Wait()
+
+	results := make([]PartitionResult, 0, len(workers))
+	names := make([]string, 0, len(workers))
+	for name := range workers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	collect := func() []PartitionResult {
+		results = results[:0]
+		for _, name := range names {
+			results = append(results, workers[name].result)
+		}
+		return results
+	}
+
+	rollback := func() {
+		for _, w := range workers {
+			if w.tx != nil {
+				_ = w.tx.Rollback()
+			}
+		}
+	}
+	if dispatchErr != nil {
+		rollback()
+		return collect(), fmt.Errorf("partitioned CopyFrom into %s failed: %w", table, dispatchErr)
+	}
+	if err := context.Cause(ctx); err != nil {
+		rollback()
+		return collect(), fmt.Errorf("partitioned CopyFrom into %s failed: %w", table, err)
+	}
+
+	// Phase 2: prepare every transaction. PREPARE TRANSACTION ends the
+	// transaction on its session, so the following Commit only returns the
+	// connection to the pool.
+	gids := make(map[string]string, len(workers))
+	batch := time.Now().UnixNano()
+	var prepareErr error
+	for i, name := range names {
+		w := workers[name]
+		gid := fmt.Sprintf("%s_%d_%d", prefix, batch, i)
+		if _, err := w.tx.ExecContext(ctx, fmt.Sprintf("PREPARE TRANSACTION '%s'", gid)); err != nil {
+			w.result.Err = fmt.Errorf("PREPARE TRANSACTION failed: %w", err)
+			prepareErr = fmt.Errorf("partition %s: %w", name, w.result.Err)
+			break
+		}
+		gids[name] = gid
+		_ = w.tx.Commit()
+		w.tx = nil
+	}
+	if prepareErr != nil {
+		rollback()
+		for name, gid := range gids {
+			if _, err := db.ExecContext(ctx, fmt.Sprintf("ROLLBACK PREPARED '%s'", gid)); err != nil {
+				workers[name].result.Err = fmt.Errorf("ROLLBACK PREPARED %s failed: %w", gid, err)
+			}
+		}
+		return collect(), fmt.Errorf("partitioned CopyFrom into %s failed: %w", table, prepareErr)
+	}
+
+	// Phase 3: commit. Every partition is durable from here on, so a failed
+	// commit is left prepared instead of being rolled back.
+	var inDoubt []string
+	var commitErrs []error
+	for _, name := range names {
+		w := workers[name]
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("COMMIT PREPARED '%s'", gids[name])); err != nil {
+			w.result.Err = fmt.Errorf("COMMIT PREPARED failed: %w", err)
+			inDoubt = append(inDoubt, gids[name])
+			commitErrs = append(commitErrs, fmt.Errorf("partition %s: %w", name, w.result.Err))
+			continue
+		}
+		w.result.Committed = true
+	}
+	if len(inDoubt) > 0 {
+		return collect(), &InDoubtError{GIDs: inDoubt, Err: errors.Join(commitErrs...)}
+	}
+	return collect(), nil
+}
+
+// copy begins the worker's transaction and copies its rows into its
+// partition.
+func (w *partitionWorker) copy(ctx context.Context, db *sql.DB, columns []string) error {
+	tx, err := txraw.Begin(ctx, db, nil)
+	if err != nil {
+		return err
+	}
+	w.tx = tx
+
+	start := time.Now()
+	defer func() { w.result.Duration = time.Since(start) }()
+	return tx.RawContext(ctx, txraw.UnwrapAs(func(conn *pgx.Conn) error {
+		copied, err := conn.CopyFrom(ctx, pgx.Identifier(strings.Split(w.result.Partition, ".")), columns, w.src)
+		w.result.Rows = copied
+		if err != nil {
+			return fmt.Errorf("CopyFrom into %s failed: %w", w.result.Partition, err)
+		}
+		return nil
+	}))
+}
@@ -0,0 +1,39 @@
+// Package pgxraw contains pgx-specific helpers built on top of txraw, for
+// code that runs inside a database/sql transaction but needs pgx features.
+package pgxraw
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
+
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// BackendPID returns the process ID of the server backend serving tx. It is
+// the key for server-side views such as pg_stat_activity and
+// pg_stat_progress_copy.
+func BackendPID(tx *txraw.Tx) (uint32, error) {
+	var pid uint32
+	err := tx.Raw(txraw.As(func(conn *stdlib.Conn) error {
+		pid = conn.Conn().PgConn().PID()
+		return nil
+	}))
+	return pid, err
+}
+
+// RawPgconn calls f with the *pgconn.PgConn underlying tx, for the
+// lower-level protocol API that *pgx.Conn does not expose: CopyFrom and
+// CopyTo with raw SQL, ExecBatch and ExecParams, or the backend PID and
+// secret key needed to cancel a query from elsewhere.
+//
+// f runs inside the transaction like any Raw callback. It must leave the
+// connection idle and must not close it or end the transaction behind tx's
+// back; pgx.Conn and database/sql keep their own state on top of it.
+func RawPgconn(ctx context.Context, tx txraw.RawTx, f func(*pgconn.PgConn) error) error {
+	return tx.RawContext(ctx, txraw.UnwrapAs(func(conn *pgx.Conn) error {
+		return f(conn.PgConn())
+	}))
+}
@@ -0,0 +1,103 @@
+package pgxraw
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// tupleOverhead approximates the per-row storage overhead of a heap tuple
+// (23-byte header rounded up plus a 4-byte line pointer).
+const tupleOverhead = 28
+
+// ErrInsufficientSpace is matched by *SpaceError.
+var ErrInsufficientSpace = errors.New("pgxraw: load would exceed the tablespace disk budget")
+
+// Querier is satisfied by *sql.DB, *sql.Conn and *sql.Tx.
+type Querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// DiskBudget describes how much space a tablespace may use.
+//
+// PostgreSQL cannot report free space on the underlying filesystem, so the
+// budget is configured explicitly: the space left for a load is MaxBytes
+// minus the current pg_tablespace_size.
+type DiskBudget struct {
+	// Tablespace to check. If empty, the tablespace of Table is used.
+	Tablespace string
+	// Table is the load target, used to resolve Tablespace when it is empty.
+	Table string
+	// MaxBytes is the total size the tablespace is allowed to reach.
+	MaxBytes int64
+}
+
+// SpaceError reports a load that does not fit into the disk budget.
+type SpaceError struct {
+	Tablespace string
+	Used       int64
+	Budget     int64
+	Needed     int64
+}
+
+func (e *SpaceError) Error() string {
+	return fmt.Sprintf("pgxraw: tablespace %s uses %d of %d budgeted bytes, load needs an estimated %d more",
+		e.Tablespace, e.Used, e.Budget, e.Needed)
+}
+
+func (e *SpaceError) Is(target error) bool {
+	return target == ErrInsufficientSpace
+}
+
+// CheckDiskSpace aborts a load early, before any row is copied, if an
+// estimated `needed` bytes would push the tablespace over budget. It returns
+// a *SpaceError in that case, rather than letting COPY fail midway on a full
+// disk.
+func CheckDiskSpace(ctx context.Context, q Querier, budget DiskBudget, needed int64) error {
+	tablespace := budget.Tablespace
+	if tablespace == "" {
+		if budget.Table == "" {
+			return errors.New("pgxraw: DiskBudget needs Tablespace or Table")
+		}
+		err := q.QueryRowContext(ctx, `
+			SELECT COALESCE(t.spcname, 'pg_default')
+			FROM pg_class c
+			LEFT JOIN pg_tablespace t ON t.oid = c.reltablespace
+			WHERE c.oid = $1::regclass`, budget.Table).Scan(&tablespace)
+		if err != nil {
+			return fmt.Errorf("resolving tablespace of %s failed: %w", budget.Table, err)
+		}
+	}
+
+	var used int64
+	if err := q.QueryRowContext(ctx, "SELECT pg_tablespace_size($1)", tablespace).Scan(&used); err != nil {
+		return fmt.Errorf("pg_tablespace_size(%s) failed: %w", tablespace, err)
+	}
+
+	if used+needed > budget.MaxBytes {
+		return &SpaceError{Tablespace: tablespace, Used: used, Budget: budget.MaxBytes, Needed: needed}
+	}
+	return nil
+}
+
+// EstimateLoadSize extrapolates the on-disk size of totalRows rows from a
+// sample, using the text length of each value plus per-tuple overhead. It
+// ignores indexes and TOAST compression, so treat the result as a rough
+// lower bound and leave headroom in the budget.
+func EstimateLoadSize(sample [][]any, totalRows int64) int64 {
+	if len(sample) == 0 {
+		return 0
+	}
+
+	var sampleBytes int64
+	for _, row := range sample {
+		sampleBytes += tupleOverhead
+		for _, v := range row {
+			if v != nil {
+				sampleBytes += int64(len(fmt.Sprint(v)))
+			}
+		}
+	}
+	return sampleBytes * totalRows / int64(len(sample))
+}
@@ -0,0 +1,193 @@
+package pgxraw
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/eqld/example-tx-raw/clock"
+)
+
+// minProgressCopyVersion is the first server_version_num with
+// pg_stat_progress_copy.
+const minProgressCopyVersion = 140000
+
+// ErrProgressUnsupported is returned by WatchCopyProgress when the server is
+// older than PostgreSQL 14 and has no pg_stat_progress_copy view.
+var ErrProgressUnsupported = errors.New("pgxraw: pg_stat_progress_copy requires PostgreSQL 14 or newer")
+
+// CopyProgress is one server-reported sample of pg_stat_progress_copy.
+//
+// Unlike client-side row counting it reflects what the server has actually
+// processed, including bytes for wide rows.
+type CopyProgress struct {
+	BytesProcessed  int64
+	BytesTotal      int64
+	TuplesProcessed int64
+	TuplesExcluded  int64
+	SampledAt       time.Time
+}
+
+// MBPerSec returns the rate at which the server processed bytes between
+// prev and p, in MB/s. It returns 0 if prev is not an earlier sample.
+func (p CopyProgress) MBPerSec(prev CopyProgress) float64 {
+	return MBPerSec(p.BytesProcessed-prev.BytesProcessed, p.SampledAt.Sub(prev.SampledAt))
+}
+
+// DefaultWatchInterval is the polling interval of WatchCopyProgress when
+// WatchOptions.Interval is not positive.
+const DefaultWatchInterval = time.Second
+
+// WatchOptions controls how WatchCopyProgress polls.
+type WatchOptions struct {
+	// Interval is the time between polls. Zero or negative means
+	// DefaultWatchInterval.
+	Interval time.Duration
+	// Clock times the polls and stamps the samples; nil means clock.Real.
+	Clock clock.Clock
+}
+
+func (o WatchOptions) interval() time.Duration {
+	if o.Interval > 0 {
+		return o.Interval
+	}
+	return DefaultWatchInterval
+}
+
+// WatchCopyProgress polls pg_stat_progress_copy for the backend pid every
+// opts.Interval and calls fn with each sample while a COPY is running on it.
+// Use BackendPID to get the pid of a transaction's connection.
+//
+// db must not be the pool of the connection running the COPY's transaction
+// exclusively; the polling query needs a connection of its own. Polling stops
+// when ctx is done or the returned stop function is called; stop waits for
+// the poller to exit.
+func WatchCopyProgress(ctx context.Context, db *sql.DB, pid uint32, opts WatchOptions, fn func(CopyProgress)) (stop func(), err error) {
+	var versionNum int
+	if err := db.QueryRowContext(ctx, "SELECT current_setting('server_version_num')::int").Scan(&versionNum); err != nil {
+		return nil, fmt.Errorf("reading server_version_num failed: %w", err)
+	}
+	if versionNum < minProgressCopyVersion {
+		return nil, ErrProgressUnsupported
+	}
+
+	const query = `
+		SELECT bytes_processed, bytes_total, tuples_processed, tuples_excluded
+		FROM pg_stat_progress_copy
+		WHERE pid = $1`
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		c, interval := clock.Or(opts.Clock), opts.interval()
+		for {
+			if err := clock.Sleep(ctx, c, interval); err != nil {
+				return
+			}
+
+			var p CopyProgress
+			err := db.QueryRowContext(ctx, query, int64(pid)).Scan(
+				&p.BytesProcessed, &p.BytesTotal, &p.TuplesProcessed, &p.TuplesExcluded)
+			if err != nil {
+				// No row means no COPY is running right now; other errors are
+				// transient from the poller's point of view.
+				continue
+			}
+			p.SampledAt = c.Now()
+			fn(p)
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}, nil
+}
+
+// ProgressFunc receives client-side progress of a CopyFrom: the number of
+// rows handed to the COPY so far and the time since the first row.
+type ProgressFunc func(rowsCopied int64, elapsed time.Duration)
+
+// ProgressOptions controls how often WithProgress reports.
+type ProgressOptions struct {
+	// EveryRows reports after every EveryRows rows. Zero disables row-based
+	// reports.
+	EveryRows int64
+	// Interval reports when at least Interval has passed since the last
+	// report. It is checked as rows flow, so a stalled source produces no
+	// reports. Zero disables time-based reports.
+	Interval time.Duration
+	// Clock times the reports; nil means clock.Real.
+	Clock clock.Clock
+}
+
+// WithProgress returns src wrapped so that fn is called as its rows are
+// consumed, per opts, and once more when src is exhausted. It works inside a
+// transaction like any other source, so callers can drive progress bars,
+// ETAs or metrics for multi-million-row loads:
+//
+//	src = pgxraw.WithProgress(src, func(rows int64, elapsed time.Duration) {
+//		log.Printf("%d rows in %s", rows, elapsed)
+//	}, pgxraw.ProgressOptions{EveryRows: 100000, Interval: 5 * time.Second})
+//
+// fn runs on the goroutine that encodes the COPY data and should return
+// quickly.
+func WithProgress(src pgx.CopyFromSource, fn ProgressFunc, opts ProgressOptions) pgx.CopyFromSource {
+	return &progressSource{src: src, fn: fn, opts: opts, clock: clock.Or(opts.Clock)}
+}
+
+type progressSource struct {
+	src   pgx.CopyFromSource
+	fn    ProgressFunc
+	opts  ProgressOptions
+	clock clock.Clock
+
+	rows       int64
+	start      time.Time
+	reportedAt time.Time
+	reported   int64
+	done       bool
+}
+
+func (p *progressSource) Next() bool {
+	if p.start.IsZero() {
+		p.start = p.clock.Now()
+		p.reportedAt = p.start
+	}
+	if !p.src.Next() {
+		if !p.done && p.rows != p.reported {
+			p.report(p.clock.Now())
+		}
+		p.done = true
+		return false
+	}
+	p.rows++
+
+	now := p.clock.Now()
+	if (p.opts.EveryRows > 0 && p.rows-p.reported >= p.opts.EveryRows) ||
+		(p.opts.Interval > 0 && now.Sub(p.reportedAt) >= p.opts.Interval) {
+		p.report(now)
+	}
+	return true
+}
+
+func (p *progressSource) report(now time.Time) {
+	p.reported = p.rows
+	p.reportedAt = now
+	p.fn(p.rows, now.Sub(p.start))
+}
+
+func (p *progressSource) Values() ([]any, error) {
+	return p.src.Values()
+}
+
+func (p *progressSource) Err() error {
+	return p.src.Err()
+}
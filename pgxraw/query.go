@@ -0,0 +1,53 @@
+package pgxraw
+
+import (
+	"context"
+	"fmt"
+	"iter"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// QueryStructs runs query inside tx on the raw pgx connection and streams
+// the result, yielding every row as a T as it is scanned, matching columns to
+// struct fields by name or `db` tag (see pgx.RowToStructByName).
+//
+// It gives a typed read API that coexists with the bulk write APIs in the
+// same transaction: rows written with CopyFrom can be read back before
+// commit. Only one row is held in memory at a time, but the loop body runs
+// inside Raw, with the connection locked and the result still being read
+// from it, so it must not use tx; doing so deadlocks. To write rows derived
+// from the ones read, use QueryCursor, which yields between fetches.
+// Breaking out of the loop closes the result. Iteration stops at the first
+// error, which is yielded with the zero T.
+func QueryStructs[T any](ctx context.Context, tx txraw.RawTx, query string, args ...any) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		stopped := false
+
+		err := tx.RawContext(ctx, txraw.UnwrapAs(func(conn *pgx.Conn) error {
+			rows, err := conn.Query(ctx, query, args...)
+			if err != nil {
+				return fmt.Errorf("pgxConn.Query failed: %w", err)
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+				v, err := pgx.RowToStructByName[T](rows)
+				if err != nil {
+					return fmt.Errorf("scanning row into %T failed: %w", zero, err)
+				}
+				if !yield(v, nil) {
+					stopped = true
+					return nil
+				}
+			}
+			return rows.Err()
+		}))
+		if err != nil && !stopped {
+			yield(zero, err)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package pgxraw
+
+func init() {
+	raceEnabled = true
+}
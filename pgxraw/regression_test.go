@@ -0,0 +1,297 @@
+package pgxraw
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// The copy pipeline is guarded against performance regressions by
+// TestRegression, which runs every case in regressionCases as a benchmark
+// and compares rows per second and bytes allocated per row with the baseline
+// recorded in testdata/regression_baseline.json. After an intended change in
+// performance, record a new baseline on a quiet machine with
+//
+//	go test -run TestRegression ./pgxraw -update-baseline
+//
+// Cases marked integration copy into a real database and only run when
+// TXRAW_TEST_DATABASE_URL is set. The same cases are available as
+// BenchmarkPipeline for ad hoc profiling.
+
+var (
+	updateBaseline = flag.Bool("update-baseline", false,
+		"record the measured performance as the new regression baseline")
+	throughputTolerance = flag.Float64("throughput-tolerance", 0.5,
+		"fraction by which rows/s may fall below the baseline before TestRegression fails")
+	allocTolerance = flag.Float64("alloc-tolerance", 0.1,
+		"fraction by which bytes allocated per row may exceed the baseline before TestRegression fails")
+)
+
+// raceEnabled is set by regression_race_test.go. The race detector slows
+// everything down and changes allocations, so TestRegression skips.
+var raceEnabled bool
+
+const (
+	baselinePath = "testdata/regression_baseline.json"
+	// testDatabaseEnv names the environment variable with the connection
+	// string of the database integration cases copy into.
+	testDatabaseEnv = "TXRAW_TEST_DATABASE_URL"
+	// regressionRows is the number of rows each case moves per operation.
+	regressionRows = 1000
+)
+
+// baseline is the recorded performance of one case.
+type baseline struct {
+	RowsPerSec  float64 `json:"rows_per_sec"`
+	BytesPerRow float64 `json:"bytes_per_row"`
+}
+
+// regressionCase is one stage, or all, of the copy pipeline. run moves
+// regressionRows rows per call.
+type regressionCase struct {
+	name        string
+	integration bool
+	run         func(b *testing.B)
+}
+
+var regressionCases = []regressionCase{
+	{name: "sources", run: benchSources},
+	{name: "csv", run: benchCSV},
+	{name: "binary", run: benchBinary},
+	{name: "copy-structs", integration: true, run: benchCopyStructs},
+}
+
+func TestRegression(t *testing.T) {
+	if testing.Short() {
+		t.Skip("benchmarks are skipped in short mode")
+	}
+	if raceEnabled {
+		t.Skip("performance is not comparable under the race detector")
+	}
+
+	baselines := make(map[string]baseline)
+	if data, err := os.ReadFile(baselinePath); err == nil {
+		if err := json.Unmarshal(data, &baselines); err != nil {
+			t.Fatalf("reading %s: %v", baselinePath, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) || !*updateBaseline {
+		t.Fatalf("reading %s: %v", baselinePath, err)
+	}
+
+	for _, c := range regressionCases {
+		t.Run(c.name, func(t *testing.T) {
+			if c.integration && os.Getenv(testDatabaseEnv) == "" {
+				t.Skipf("%s not set", testDatabaseEnv)
+			}
+			result := testing.Benchmark(c.run)
+			if result.N == 0 {
+				t.Fatal("benchmark failed")
+			}
+			got := baseline{
+				RowsPerSec:  float64(result.N) * regressionRows / result.T.Seconds(),
+				BytesPerRow: float64(result.AllocedBytesPerOp()) / regressionRows,
+			}
+			t.Logf("%.0f rows/s, %.1f B/row", got.RowsPerSec, got.BytesPerRow)
+
+			if *updateBaseline {
+				baselines[c.name] = got
+				return
+			}
+			want, ok := baselines[c.name]
+			if !ok {
+				t.Skipf("no baseline recorded; run with -update-baseline")
+			}
+			if least := want.RowsPerSec * (1 - *throughputTolerance); got.RowsPerSec < least {
+				t.Errorf("throughput regressed: %.0f rows/s, baseline %.0f, minimum %.0f",
+					got.RowsPerSec, want.RowsPerSec, least)
+			}
+			// Allow one byte per row on top of the tolerance so that
+			// nearly allocation-free cases do not fail on rounding.
+			if most := want.BytesPerRow*(1+*allocTolerance) + 1; got.BytesPerRow > most {
+				t.Errorf("allocations regressed: %.1f B/row, baseline %.1f, maximum %.1f",
+					got.BytesPerRow, want.BytesPerRow, most)
+			}
+		})
+	}
+
+	if *updateBaseline {
+		data, err := json.MarshalIndent(baselines, "", "\t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(baselinePath, append(data, '\n'), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPipeline(b *testing.B) {
+	for _, c := range regressionCases {
+		b.Run(c.name, func(b *testing.B) {
+			if c.integration && os.Getenv(testDatabaseEnv) == "" {
+				b.Skipf("%s not set", testDatabaseEnv)
+			}
+			c.run(b)
+			b.ReportMetric(float64(b.N)*regressionRows/b.Elapsed().Seconds(), "rows/s")
+		})
+	}
+}
+
+// regressionRow is the row shape the cases move: a typical narrow table.
+type regressionRow struct {
+	ID      int64     `db:"id"`
+	Name    string    `db:"name"`
+	Comment *string   `db:"comment"`
+	Created time.Time `db:"created"`
+}
+
+var regressionColumns = []string{"id", "name", "comment", "created"}
+
+// regressionData returns regressionRows rows as structs and as CopyFrom rows.
+func regressionData() ([]regressionRow, [][]any) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	comment := "  some comment  "
+	structs := make([]regressionRow, regressionRows)
+	rows := make([][]any, regressionRows)
+	for i := range structs {
+		structs[i] = regressionRow{ID: int64(i + 1), Name: " item " + strconv.Itoa(i) + " ", Created: created}
+		if i%2 == 0 {
+			structs[i].Comment = &comment
+		}
+		rows[i] = []any{structs[i].ID, structs[i].Name, structs[i].Comment, structs[i].Created}
+	}
+	return structs, rows
+}
+
+// drain consumes src like CopyFrom does and returns the number of rows.
+func drain(src pgx.CopyFromSource) (int, error) {
+	n := 0
+	for src.Next() {
+		if _, err := src.Values(); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, src.Err()
+}
+
+// benchSources measures the client-side source wrappers stacked the way a
+// load uses them: transforms, validators, the type map and progress.
+func benchSources(b *testing.B) {
+	_, rows := regressionData()
+	transforms := NewTransforms().Add("name", TrimSpace).Add("comment", TrimSpace, NullIfEmpty)
+	validators := NewValidators(AbortOnInvalid).Add(func(row []any) error {
+		if row[1] == "" {
+			return errors.New("name is empty")
+		}
+		return nil
+	})
+	types := NewTypeMap()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		src, err := transforms.Source(regressionColumns, pgx.CopyFromRows(rows))
+		if err != nil {
+			b.Fatal(err)
+		}
+		src, _ = validators.Source(src)
+		src = WithProgress(types.Source(src), func(int64, time.Duration) {}, ProgressOptions{EveryRows: 100})
+		if n, err := drain(src); err != nil || n != regressionRows {
+			b.Fatalf("drained %d rows: %v", n, err)
+		}
+	}
+}
+
+// benchCSV measures parsing CSV input as CopyFromCSV does.
+func benchCSV(b *testing.B) {
+	var input strings.Builder
+	input.WriteString("id,name,comment,created\n")
+	for i := range regressionRows {
+		fmt.Fprintf(&input, "%d,item %d,\"some, comment\",2024-01-02 03:04:05\n", i+1, i)
+	}
+	data := input.String()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		reader := &csvReader{r: bufio.NewReader(strings.NewReader(data)), comma: ','}
+		n := -1
+		for {
+			_, _, err := reader.Read()
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					b.Fatal(err)
+				}
+				break
+			}
+			n++
+		}
+		if n != regressionRows {
+			b.Fatalf("read %d records, want %d", n, regressionRows)
+		}
+	}
+}
+
+// benchBinary measures encoding tuples in PostgreSQL's binary COPY format.
+func benchBinary(b *testing.B) {
+	id := []byte{0, 0, 0, 0, 0, 0, 0, 1}
+	name := []byte("item")
+	created := []byte{0, 2, 186, 77, 57, 248, 48, 64}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		w := NewBinaryWriter(io.Discard)
+		for range regressionRows {
+			if err := w.WriteTuple(id, name, nil, created); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchCopyStructs measures the whole pipeline against a database: a
+// CopyFromStructs into a temporary table inside a wrapped transaction.
+func benchCopyStructs(b *testing.B) {
+	ctx := context.Background()
+	db, err := sql.Open("pgx", os.Getenv(testDatabaseEnv))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+	structs, _ := regressionData()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		err := txraw.WithTx(ctx, db, func(tx *txraw.Tx) error {
+			_, err := tx.ExecContext(ctx, `CREATE TEMP TABLE regression_items
+				(id bigint, name text, comment text, created timestamptz) ON COMMIT DROP`)
+			if err != nil {
+				return err
+			}
+			n, err := CopyFromStructs(ctx, tx, "regression_items", structs)
+			if err == nil && n != regressionRows {
+				err = fmt.Errorf("copied %d rows, want %d", n, regressionRows)
+			}
+			return err
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package pgxraw
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// Credentials are the user name and password (or token) used to connect.
+type Credentials struct {
+	User     string
+	Password string
+}
+
+// RotatingCredentials supplies the credentials of a pool opened with OpenDB
+// and lets them be replaced at runtime without restarting the process.
+//
+// After Rotate, new connections authenticate with the new credentials while
+// existing connections, and the transactions running on them, carry on
+// undisturbed. With drain, connections that authenticated with older
+// credentials are discarded instead of being handed out again, so none is
+// reused after the rotation.
+type RotatingCredentials struct {
+	mu      sync.RWMutex
+	current Credentials
+	revoked map[Credentials]bool
+}
+
+// NewRotatingCredentials returns RotatingCredentials starting at initial.
+func NewRotatingCredentials(initial Credentials) *RotatingCredentials {
+	return &RotatingCredentials{current: initial, revoked: make(map[Credentials]bool)}
+}
+
+// Current returns the credentials new connections use.
+func (r *RotatingCredentials) Current() Credentials {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.current
+}
+
+// Rotate switches new connections to next. If drain is set, idle and in-use
+// connections that authenticated with any earlier credentials are discarded
+// instead of being reused; in-flight transactions still complete first.
+func (r *RotatingCredentials) Rotate(next Credentials, drain bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if next == r.current {
+		return
+	}
+	if drain {
+		r.revoked[r.current] = true
+	}
+	delete(r.revoked, next)
+	r.current = next
+}
+
+// Watch polls fetch every interval, typically a secret-provider lookup, and
+// rotates to whatever it returns, draining old connections if drain is set.
+// It blocks until ctx is done, returning ctx.Err(), or until fetch fails.
+func (r *RotatingCredentials) Watch(ctx context.Context, interval time.Duration, drain bool, fetch func(context.Context) (Credentials, error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		next, err := fetch(ctx)
+		if err != nil {
+			return fmt.Errorf("fetching credentials failed: %w", err)
+		}
+		r.Rotate(next, drain)
+	}
+}
+
+// OpenDB opens a pgx-backed *sql.DB whose connections authenticate with the
+// current credentials of r. The User and Password of config are ignored.
+func (r *RotatingCredentials) OpenDB(config *pgx.ConnConfig, opts ...stdlib.OptionOpenDB) *sql.DB {
+	opts = append(opts,
+		stdlib.OptionBeforeConnect(func(ctx context.Context, cc *pgx.ConnConfig) error {
+			creds := r.Current()
+			cc.User, cc.Password = creds.User, creds.Password
+			return nil
+		}),
+		stdlib.OptionResetSession(func(ctx context.Context, conn *pgx.Conn) error {
+			if r.isRevoked(conn.Config()) {
+				return driver.ErrBadConn
+			}
+			return nil
+		}),
+	)
+	return stdlib.OpenDB(*config, opts...)
+}
+
+func (r *RotatingCredentials) isRevoked(cc *pgx.ConnConfig) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.revoked[Credentials{User: cc.User, Password: cc.Password}]
+}
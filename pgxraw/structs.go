@@ -0,0 +1,153 @@
+package pgxraw
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// structColumn is a column derived from a struct field: its name, the
+// field index path through embedded structs, and whether the field's tag has
+// the override option.
+type structColumn struct {
+	name     string
+	index    []int
+	override bool
+}
+
+// CopyFromStructs bulk-inserts rows into table within tx with pgx.CopyFrom,
+// deriving the columns from the fields of T so callers do not hand-build
+// [][]any:
+//
+//	type item struct {
+//		Name string  `db:"name"`
+//		Data *string `db:"data"` // nil is inserted as NULL
+//		ID   int     `db:"id"`   // identity: skipped, filled in by the server
+//	}
+//	n, err := pgxraw.CopyFromStructs(ctx, tx, "items", items)
+//
+// Columns follow the same rules as pgx.RowToStructByName: the db tag names
+// the column, a tag of "-" skips the field, untagged fields use the field name
+// in lower case, unexported fields are ignored, and embedded structs (or
+// pointers to them, nil meaning all NULL) contribute their fields, even if
+// their type is unexported. T may also be a pointer to a struct.
+//
+// Fields for identity and stored generated columns are left out, so the
+// server fills those in. Tag an identity column's field with the override
+// option, `db:"id,override"`, to load its values instead, the way INSERT ...
+// OVERRIDING SYSTEM VALUE would.
+func CopyFromStructs[T any](ctx context.Context, tx txraw.RawTx, table string, rows []T) (int64, error) {
+	if err := txraw.CheckWritable(tx, "CopyFromStructs into "+table); err != nil {
+		return 0, err
+	}
+	typ := reflect.TypeFor[T]()
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return 0, fmt.Errorf("CopyFromStructs: %v is not a struct type", typ)
+	}
+
+	columns := structColumns(typ, nil)
+	if len(columns) == 0 {
+		return 0, fmt.Errorf("CopyFromStructs: %v has no columns", typ)
+	}
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.name
+	}
+
+	i := -1
+	src := pgx.CopyFromFunc(func() ([]any, error) {
+		i++
+		if i >= len(rows) {
+			return nil, nil
+		}
+		v := reflect.ValueOf(rows[i])
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return nil, fmt.Errorf("CopyFromStructs: row %d is nil", i+1)
+			}
+			v = v.Elem()
+		}
+		values := make([]any, len(columns))
+		for j, c := range columns {
+			values[j] = fieldValue(v, c.index)
+		}
+		return values, nil
+	})
+
+	var copied int64
+	err := tx.RawContext(ctx, txraw.UnwrapAs(func(conn *pgx.Conn) error {
+		keep, err := LoadableColumns(ctx, conn, table, names, func(i int) bool { return columns[i].override })
+		if err != nil {
+			return err
+		}
+		columns, names = pick(columns, keep), pick(names, keep)
+
+		copied, err = conn.CopyFrom(ctx, pgx.Identifier(strings.Split(table, ".")), names, src)
+		if err != nil {
+			return fmt.Errorf("CopyFrom into %s failed: %w", table, err)
+		}
+		return nil
+	}))
+	return copied, err
+}
+
+// structColumns lists the columns of struct type typ in field order.
+func structColumns(typ reflect.Type, parent []int) []structColumn {
+	var columns []structColumn
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		index := append(append([]int(nil), parent...), i)
+
+		tag, hasTag := field.Tag.Lookup("db")
+		if tag == "-" {
+			continue
+		}
+		// Like pgx, descend into embedded structs even when their type is
+		// unexported: their exported fields are promoted all the same.
+		if field.Anonymous && !hasTag {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				columns = append(columns, structColumns(embedded, index)...)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		override := slices.Contains(strings.Split(options, ","), "override")
+		columns = append(columns, structColumn{name: name, index: index, override: override})
+	}
+	return columns
+}
+
+// fieldValue returns the field of v at index, or nil if the path crosses a
+// nil embedded pointer.
+func fieldValue(v reflect.Value, index []int) any {
+	for _, i := range index {
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return nil
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v.Interface()
+}
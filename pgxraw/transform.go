@@ -0,0 +1,157 @@
+package pgxraw
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Transformer rewrites one column value on its way into a COPY, e.g. to
+// trim it, normalize it or hash it. nil stands for NULL both ways.
+type Transformer func(value any) (any, error)
+
+// Transforms is a per-column registry of Transformers applied to rows as
+// they stream into CopyFrom, so an ingestion pipeline needs no pre-pass over
+// the data:
+//
+//	transforms := pgxraw.NewTransforms().
+//		Add("name", pgxraw.TrimSpace).
+//		Add("email", pgxraw.TrimSpace, pgxraw.HashSHA256("pepper"))
+//	src, err := transforms.Source(columns, src)
+//
+// A Transforms must not be modified while a Source built from it is in use.
+type Transforms struct {
+	columns map[string][]Transformer
+}
+
+// NewTransforms returns an empty registry.
+func NewTransforms() *Transforms {
+	return &Transforms{columns: make(map[string][]Transformer)}
+}
+
+// Add appends fns to the transformers of column. They run in the order they
+// were added, each on the result of the previous one.
+func (t *Transforms) Add(column string, fns ...Transformer) *Transforms {
+	t.columns[column] = append(t.columns[column], fns...)
+	return t
+}
+
+// Source returns src with the registered transformers applied to each row.
+// columns names the values of src's rows, as passed to CopyFrom. It fails if
+// a transformer is registered for a column that is not in columns, which
+// would otherwise be silently skipped.
+//
+// The rows of src are not modified; each row is copied before transforming.
+// A transformer error fails the COPY with the row number and column.
+func (t *Transforms) Source(columns []string, src pgx.CopyFromSource) (pgx.CopyFromSource, error) {
+	for column := range t.columns {
+		if !slices.Contains(columns, column) {
+			return nil, fmt.Errorf("transformer registered for unknown column %q", column)
+		}
+	}
+	fns := make([][]Transformer, len(columns))
+	for i, column := range columns {
+		fns[i] = t.columns[column]
+	}
+	return &transformSource{src: src, columns: columns, fns: fns}, nil
+}
+
+type transformSource struct {
+	src     pgx.CopyFromSource
+	columns []string
+	fns     [][]Transformer
+	row     int
+	values  []any
+}
+
+func (s *transformSource) Next() bool {
+	if !s.src.Next() {
+		return false
+	}
+	s.row++
+	return true
+}
+
+func (s *transformSource) Values() ([]any, error) {
+	values, err := s.src.Values()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != len(s.columns) {
+		return nil, fmt.Errorf("row %d: %d values for %d columns", s.row, len(values), len(s.columns))
+	}
+
+	s.values = append(s.values[:0], values...)
+	for i, fns := range s.fns {
+		for _, fn := range fns {
+			s.values[i], err = fn(s.values[i])
+			if err != nil {
+				return nil, fmt.Errorf("row %d, column %s: %w", s.row, s.columns[i], err)
+			}
+		}
+	}
+	return s.values, nil
+}
+
+func (s *transformSource) Err() error {
+	return s.src.Err()
+}
+
+// TrimSpace removes leading and trailing white space from string values.
+// Other values pass through unchanged.
+func TrimSpace(value any) (any, error) {
+	if s, ok := value.(string); ok {
+		return strings.TrimSpace(s), nil
+	}
+	return value, nil
+}
+
+// NullIfEmpty turns empty strings into NULL.
+func NullIfEmpty(value any) (any, error) {
+	if s, ok := value.(string); ok && s == "" {
+		return nil, nil
+	}
+	return value, nil
+}
+
+// InLocation converts time.Time values to loc, e.g. time.UTC, so timestamps
+// from sources in different zones are stored consistently. Values that are
+// not a time.Time are an error, as they would silently escape normalization.
+func InLocation(loc *time.Location) Transformer {
+	return func(value any) (any, error) {
+		switch v := value.(type) {
+		case nil:
+			return nil, nil
+		case time.Time:
+			return v.In(loc), nil
+		default:
+			return nil, fmt.Errorf("InLocation: want time.Time, got %T", value)
+		}
+	}
+}
+
+// HashSHA256 replaces string and []byte values with the hex SHA-256 of salt
+// followed by the value, to pseudonymize PII while keeping it joinable.
+// Other values are an error, as they would otherwise be stored in the clear.
+func HashSHA256(salt string) Transformer {
+	return func(value any) (any, error) {
+		h := sha256.New()
+		h.Write([]byte(salt))
+		switch v := value.(type) {
+		case nil:
+			return nil, nil
+		case string:
+			h.Write([]byte(v))
+		case []byte:
+			h.Write(v)
+		default:
+			return nil, fmt.Errorf("HashSHA256: want string or []byte, got %T", value)
+		}
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+}
@@ -0,0 +1,198 @@
+package pgxraw
+
+import (
+	"database/sql/driver"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// TypeMap converts Go values into values pgx.CopyFrom encodes the way the
+// target columns expect, so rows can be built from ordinary Go data instead
+// of pre-encoded strings:
+//
+//   - nil and nil pointers become NULL; other pointers are dereferenced.
+//   - maps and structs become JSON, for json and jsonb columns.
+//   - time.Time is converted to Location, if set, so timestamp columns
+//     without a time zone store a consistent wall clock.
+//   - slices become arrays, their elements mapped by the same rules; []byte
+//     stays bytea.
+//   - values of a registered type are converted by their registered func
+//     before any other rule.
+//
+// Structs that implement driver.Valuer or encoding.TextMarshaler (such as
+// pgtype values or netip.Addr) are left to pgx, not turned into JSON.
+//
+// A TypeMap must not be modified while it is in use.
+type TypeMap struct {
+	// Location, if set, is the zone time.Time values are converted to.
+	Location *time.Location
+
+	custom map[reflect.Type]func(value any) (any, error)
+}
+
+// NewTypeMap returns a TypeMap with no custom types.
+func NewTypeMap() *TypeMap {
+	return &TypeMap{custom: make(map[reflect.Type]func(value any) (any, error))}
+}
+
+// RegisterType makes m convert values of type T with fn. fn's result is
+// passed to pgx as it is, without further mapping.
+func RegisterType[T any](m *TypeMap, fn func(value T) (any, error)) *TypeMap {
+	m.custom[reflect.TypeFor[T]()] = func(value any) (any, error) {
+		return fn(value.(T))
+	}
+	return m
+}
+
+var (
+	valuerType        = reflect.TypeFor[driver.Valuer]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+	timeType          = reflect.TypeFor[time.Time]()
+	byteSliceType     = reflect.TypeFor[[]byte]()
+)
+
+// Value converts one value.
+func (m *TypeMap) Value(value any) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+	return m.value(reflect.ValueOf(value))
+}
+
+func (m *TypeMap) value(v reflect.Value) (any, error) {
+	typ := v.Type()
+	if fn, ok := m.custom[typ]; ok {
+		return fn(v.Interface())
+	}
+
+	switch typ.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if typ.Kind() == reflect.Pointer && typ.Implements(valuerType) {
+			return v.Interface(), nil
+		}
+		return m.value(v.Elem())
+
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return marshalJSON(v)
+
+	case reflect.Struct:
+		if typ == timeType {
+			t := v.Interface().(time.Time)
+			if m.Location != nil {
+				t = t.In(m.Location)
+			}
+			return t, nil
+		}
+		if typ.Implements(valuerType) || typ.Implements(textMarshalerType) {
+			return v.Interface(), nil
+		}
+		return marshalJSON(v)
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if typ == byteSliceType || !m.needsMapping(typ.Elem()) {
+			return v.Interface(), nil
+		}
+		elems := make([]any, v.Len())
+		for i := range elems {
+			var err error
+			if elems[i], err = m.value(v.Index(i)); err != nil {
+				return nil, fmt.Errorf("element %d: %w", i, err)
+			}
+		}
+		return elems, nil
+
+	default:
+		return v.Interface(), nil
+	}
+}
+
+// needsMapping reports whether values of typ are changed by the TypeMap, so
+// slices of any other type can go to pgx unchanged.
+func (m *TypeMap) needsMapping(typ reflect.Type) bool {
+	if _, ok := m.custom[typ]; ok {
+		return true
+	}
+	switch typ.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map:
+		return true
+	case reflect.Struct:
+		if typ == timeType {
+			return m.Location != nil
+		}
+		return !typ.Implements(valuerType) && !typ.Implements(textMarshalerType)
+	case reflect.Slice:
+		return typ != byteSliceType && m.needsMapping(typ.Elem())
+	default:
+		return false
+	}
+}
+
+func marshalJSON(v reflect.Value) (any, error) {
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil, fmt.Errorf("encoding %v as JSON failed: %w", v.Type(), err)
+	}
+	return string(b), nil
+}
+
+// Row converts the values of one row.
+func (m *TypeMap) Row(values ...any) ([]any, error) {
+	row := make([]any, len(values))
+	for i, value := range values {
+		var err error
+		if row[i], err = m.Value(value); err != nil {
+			return nil, fmt.Errorf("value %d: %w", i+1, err)
+		}
+	}
+	return row, nil
+}
+
+// Source returns src with every row converted by m. A conversion error fails
+// the COPY with the row number.
+func (m *TypeMap) Source(src pgx.CopyFromSource) pgx.CopyFromSource {
+	return &typeMapSource{src: src, m: m}
+}
+
+type typeMapSource struct {
+	src pgx.CopyFromSource
+	m   *TypeMap
+	row int
+}
+
+func (s *typeMapSource) Next() bool {
+	if !s.src.Next() {
+		return false
+	}
+	s.row++
+	return true
+}
+
+func (s *typeMapSource) Values() ([]any, error) {
+	values, err := s.src.Values()
+	if err != nil {
+		return nil, err
+	}
+	row, err := s.m.Row(values...)
+	if err != nil {
+		return nil, fmt.Errorf("row %d: %w", s.row, err)
+	}
+	return row, nil
+}
+
+func (s *typeMapSource) Err() error {
+	return s.src.Err()
+}
@@ -0,0 +1,264 @@
+package pgxraw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// ConflictStrategy is how UpsertCopy merges staged rows that conflict with
+// the target, on a unique key or any other constraint.
+type ConflictStrategy int
+
+const (
+	// FailFast merges with a plain INSERT: the first conflicting row fails
+	// the merge, and with it the transaction.
+	FailFast ConflictStrategy = iota
+	// SkipDuplicates keeps existing rows (ON CONFLICT ... DO NOTHING).
+	SkipDuplicates
+	// ReplaceExisting overwrites existing rows (ON CONFLICT ... DO UPDATE).
+	ReplaceExisting
+	// CollectErrors merges every row that can be merged and returns the
+	// others, with their errors, in UpsertResult.Errors.
+	CollectErrors
+)
+
+func (s ConflictStrategy) String() string {
+	switch s {
+	case FailFast:
+		return "fail-fast"
+	case SkipDuplicates:
+		return "skip-duplicates"
+	case ReplaceExisting:
+		return "replace-existing"
+	case CollectErrors:
+		return "collect-errors"
+	default:
+		return fmt.Sprintf("ConflictStrategy(%d)", int(s))
+	}
+}
+
+// UpsertOptions configures UpsertCopy.
+type UpsertOptions struct {
+	// ConflictColumns is the conflict target, the columns of a unique index
+	// or primary key of the table. ReplaceExisting requires it;
+	// SkipDuplicates without it skips rows conflicting on any unique index.
+	ConflictColumns []string
+	// Strategy is applied to conflicting rows.
+	Strategy ConflictStrategy
+	// UpdateColumns are the columns ReplaceExisting overwrites. If empty, all
+	// copied columns that are not in ConflictColumns are overwritten.
+	UpdateColumns []string
+}
+
+// RowError is a staged row that CollectErrors could not merge.
+type RowError struct {
+	// Row is the row's 1-based position in the source.
+	Row int64
+	Err error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+}
+
+func (e RowError) Unwrap() error { return e.Err }
+
+// UpsertResult summarizes an UpsertCopy.
+type UpsertResult struct {
+	// Staged is the number of rows copied into the staging table.
+	Staged int64
+	// Merged is the number of rows inserted or updated in the target.
+	// Rows neither merged nor in Errors were skipped by SkipDuplicates.
+	Merged int64
+	// Errors are the rows CollectErrors rejected, in source order.
+	Errors []RowError
+}
+
+// stagingSeq makes staging table names unique within a session.
+var stagingSeq atomic.Int64
+
+// UpsertCopy upserts the rows of src into columns of table within tx, which
+// plain COPY cannot do: the rows are copied into a temporary staging table
+// created in the transaction and then merged into table with
+// INSERT ... SELECT ... ON CONFLICT, all on the transaction's connection.
+//
+// The staging table has only the given columns and no constraints; it is
+// dropped after the merge and at the latest on commit. With ReplaceExisting,
+// src must not contain the same key twice, which PostgreSQL rejects as
+// affecting a row a second time.
+//
+// CollectErrors first merges all rows at once under a savepoint. Only if that
+// fails are the rows merged one by one, each under its own savepoint, so that
+// a rejected row leaves the transaction usable; this is much slower, but only
+// paid for when some row is bad.
+func UpsertCopy(ctx context.Context, tx txraw.RawTx, table string, columns []string, src pgx.CopyFromSource, opts UpsertOptions) (UpsertResult, error) {
+	var result UpsertResult
+	if err := txraw.CheckWritable(tx, "UpsertCopy into "+table); err != nil {
+		return result, err
+	}
+	if len(columns) == 0 {
+		return result, errors.New("UpsertCopy needs at least one column")
+	}
+	if opts.Strategy == ReplaceExisting && len(opts.ConflictColumns) == 0 {
+		return result, errors.New("UpsertCopy with ReplaceExisting needs ConflictColumns")
+	}
+
+	target := pgx.Identifier(strings.Split(table, ".")).Sanitize()
+	staging := fmt.Sprintf("txraw_upsert_%d", stagingSeq.Add(1))
+	quotedColumns := quoteIdentifiers(columns)
+
+	merge := fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s",
+		target, quotedColumns, quotedColumns, staging)
+	switch opts.Strategy {
+	case FailFast, CollectErrors:
+	case SkipDuplicates:
+		merge += " ON CONFLICT"
+		if len(opts.ConflictColumns) > 0 {
+			merge += fmt.Sprintf(" (%s)", quoteIdentifiers(opts.ConflictColumns))
+		}
+		merge += " DO NOTHING"
+	case ReplaceExisting:
+		merge += fmt.Sprintf(" ON CONFLICT (%s)", quoteIdentifiers(opts.ConflictColumns))
+		update := opts.UpdateColumns
+		if len(update) == 0 {
+			for _, c := range columns {
+				if !slices.Contains(opts.ConflictColumns, c) {
+					update = append(update, c)
+				}
+			}
+		}
+		if len(update) == 0 {
+			merge += " DO NOTHING"
+			break
+		}
+		set := make([]string, len(update))
+		for i, c := range update {
+			quoted := pgx.Identifier{c}.Sanitize()
+			set[i] = fmt.Sprintf("%s = EXCLUDED.%s", quoted, quoted)
+		}
+		merge += " DO UPDATE SET " + strings.Join(set, ", ")
+	default:
+		return result, fmt.Errorf("unsupported conflict strategy %s", opts.Strategy)
+	}
+
+	err := tx.RawContext(ctx, txraw.UnwrapAs(func(conn *pgx.Conn) error {
+		_, err := conn.Exec(ctx, fmt.Sprintf("CREATE TEMP TABLE %s ON COMMIT DROP AS SELECT %s FROM %s WITH NO DATA",
+			staging, quotedColumns, target))
+		if err != nil {
+			return fmt.Errorf("creating staging table for %s failed: %w", table, err)
+		}
+
+		if opts.Strategy == CollectErrors {
+			// The identity numbers the rows in the order COPY receives them.
+			_, err = conn.Exec(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s bigint GENERATED ALWAYS AS IDENTITY",
+				staging, stagingRowColumn))
+			if err != nil {
+				return fmt.Errorf("numbering staging table for %s failed: %w", table, err)
+			}
+		}
+
+		result.Staged, err = conn.CopyFrom(ctx, pgx.Identifier{staging}, columns, src)
+		if err != nil {
+			return fmt.Errorf("CopyFrom into staging table for %s failed: %w", table, err)
+		}
+
+		if opts.Strategy == CollectErrors {
+			result.Merged, result.Errors, err = mergeCollectingErrors(ctx, conn, staging, merge, result.Staged)
+			if err != nil {
+				return fmt.Errorf("merging into %s failed: %w", table, err)
+			}
+		} else {
+			tag, err := conn.Exec(ctx, merge)
+			if err != nil {
+				return fmt.Errorf("merging into %s failed: %w", table, err)
+			}
+			result.Merged = tag.RowsAffected()
+		}
+
+		if _, err := conn.Exec(ctx, "DROP TABLE "+staging); err != nil {
+			return fmt.Errorf("dropping staging table for %s failed: %w", table, err)
+		}
+		return nil
+	}))
+	return result, err
+}
+
+// stagingRowColumn numbers the staged rows for CollectErrors.
+const stagingRowColumn = "txraw_row"
+
+// mergeCollectingErrors runs merge for all staged rows under a savepoint and,
+// if that fails, once per row, collecting the rows that fail. The per-row
+// merges look rows up by number in staging, which is indexed first so that
+// each is a lookup rather than a scan of the whole load.
+func mergeCollectingErrors(ctx context.Context, conn *pgx.Conn, staging, merge string, staged int64) (int64, []RowError, error) {
+	attempt := func(sql string, args ...any) (int64, error) {
+		if _, err := conn.Exec(ctx, "SAVEPOINT txraw_upsert"); err != nil {
+			return 0, err
+		}
+		tag, err := conn.Exec(ctx, sql, args...)
+		if err != nil {
+			if _, rbErr := conn.Exec(ctx, "ROLLBACK TO SAVEPOINT txraw_upsert"); rbErr != nil {
+				return 0, rbErr
+			}
+			return 0, err
+		}
+		_, err = conn.Exec(ctx, "RELEASE SAVEPOINT txraw_upsert")
+		return tag.RowsAffected(), err
+	}
+	// Errors of the savepoint commands themselves leave the transaction
+	// unusable and are returned as such, not collected.
+	isRowError := func(err error) bool {
+		var pgErr *pgconn.PgError
+		return errors.As(err, &pgErr) && conn.PgConn().TxStatus() == 'T'
+	}
+
+	merged, err := attempt(merge)
+	if err == nil {
+		return merged, nil, nil
+	}
+	if !isRowError(err) {
+		return 0, nil, err
+	}
+
+	if _, err := conn.Exec(ctx, fmt.Sprintf("ALTER TABLE %s ADD PRIMARY KEY (%s)", staging, stagingRowColumn)); err != nil {
+		return 0, nil, fmt.Errorf("indexing staging table failed: %w", err)
+	}
+	// Temporary tables are never analyzed automatically; without statistics
+	// the planner may still prefer a scan.
+	if _, err := conn.Exec(ctx, "ANALYZE "+staging); err != nil {
+		return 0, nil, fmt.Errorf("analyzing staging table failed: %w", err)
+	}
+
+	var rowErrors []RowError
+	merged = 0
+	single := merge + fmt.Sprintf(" WHERE %s = $1", stagingRowColumn)
+	for row := int64(1); row <= staged; row++ {
+		n, err := attempt(single, row)
+		if err != nil {
+			if !isRowError(err) {
+				return merged, rowErrors, err
+			}
+			rowErrors = append(rowErrors, RowError{Row: row, Err: err})
+			continue
+		}
+		merged += n
+	}
+	return merged, rowErrors, nil
+}
+
+func quoteIdentifiers(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = pgx.Identifier{name}.Sanitize()
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,156 @@
+package pgxraw
+
+import (
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Validator checks one row on its way into a COPY. The row must not be
+// modified; use a Transformer for that.
+type Validator func(row []any) error
+
+// ValidationPolicy is what happens to a row that fails validation.
+type ValidationPolicy int
+
+const (
+	// AbortOnInvalid fails the COPY at the first invalid row, so the
+	// transaction can be rolled back.
+	AbortOnInvalid ValidationPolicy = iota
+	// SkipInvalid leaves invalid rows out of the COPY and loads the rest.
+	SkipInvalid
+)
+
+func (p ValidationPolicy) String() string {
+	switch p {
+	case AbortOnInvalid:
+		return "abort-on-invalid"
+	case SkipInvalid:
+		return "skip-invalid"
+	default:
+		return fmt.Sprintf("ValidationPolicy(%d)", int(p))
+	}
+}
+
+// ValidationReport is filled in while a validating source is consumed.
+type ValidationReport struct {
+	Policy ValidationPolicy
+	// Rows is the number of rows read from the source.
+	Rows int64
+	// Valid is the number of rows that passed every validator.
+	Valid int64
+	// Errors are the invalid rows, in source order, up to MaxErrors.
+	Errors []RowError
+	// Aborted reports that AbortOnInvalid stopped the COPY. The server
+	// reports such an abort with an error of its own that does not wrap the
+	// validator's; check Aborted and Errors instead.
+	Aborted bool
+}
+
+// Invalid returns the number of rows that failed validation.
+func (r *ValidationReport) Invalid() int64 {
+	return r.Rows - r.Valid
+}
+
+// Validators is a list of Validators run on rows before they reach
+// CopyFrom:
+//
+//	validators := pgxraw.NewValidators(pgxraw.SkipInvalid).Add(
+//		func(row []any) error {
+//			if name, _ := row[0].(string); name == "" {
+//				return errors.New("name is empty")
+//			}
+//			return nil
+//		})
+//	src, report := validators.Source(src)
+//	n, err := conn.CopyFrom(ctx, table, columns, src)
+//	log.Printf("%d loaded, %d invalid: %v", n, report.Invalid(), report.Errors)
+//
+// A Validators must not be modified while a Source built from it is in use.
+type Validators struct {
+	policy ValidationPolicy
+	fns    []Validator
+	// MaxErrors caps the number of RowErrors a report keeps; rows beyond it
+	// are still counted. Zero means 100, negative means no cap.
+	MaxErrors int
+}
+
+// NewValidators returns an empty list applying policy.
+func NewValidators(policy ValidationPolicy) *Validators {
+	return &Validators{policy: policy}
+}
+
+// Add appends fns. They run in the order they were added; the first failing
+// one decides the row's error.
+func (v *Validators) Add(fns ...Validator) *Validators {
+	v.fns = append(v.fns, fns...)
+	return v
+}
+
+// Check runs the validators on row.
+func (v *Validators) Check(row []any) error {
+	for _, fn := range v.fns {
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Source returns src with the validators applied to each row, and the
+// report it fills in as the COPY consumes it.
+func (v *Validators) Source(src pgx.CopyFromSource) (pgx.CopyFromSource, *ValidationReport) {
+	report := &ValidationReport{Policy: v.policy}
+	maxErrors := v.MaxErrors
+	if maxErrors == 0 {
+		maxErrors = 100
+	}
+	return &validatorSource{src: src, v: v, report: report, maxErrors: maxErrors}, report
+}
+
+type validatorSource struct {
+	src       pgx.CopyFromSource
+	v         *Validators
+	report    *ValidationReport
+	maxErrors int
+	values    []any
+	err       error
+}
+
+func (s *validatorSource) Next() bool {
+	for s.err == nil && s.src.Next() {
+		s.report.Rows++
+		values, err := s.src.Values()
+		if err != nil {
+			s.err = err
+			return false
+		}
+		if err := s.v.Check(values); err != nil {
+			rowErr := RowError{Row: s.report.Rows, Err: err}
+			if s.maxErrors < 0 || len(s.report.Errors) < s.maxErrors {
+				s.report.Errors = append(s.report.Errors, rowErr)
+			}
+			if s.v.policy == AbortOnInvalid {
+				s.report.Aborted = true
+				s.err = rowErr
+				return false
+			}
+			continue
+		}
+		s.report.Valid++
+		s.values = values
+		return true
+	}
+	return false
+}
+
+func (s *validatorSource) Values() ([]any, error) {
+	return s.values, nil
+}
+
+func (s *validatorSource) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.src.Err()
+}
@@ -0,0 +1,81 @@
+package pgxraw
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// countingConn counts the bytes that pass through a net.Conn.
+type countingConn struct {
+	net.Conn
+	written atomic.Int64
+	read    atomic.Int64
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.written.Add(int64(n))
+	return n, err
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.read.Add(int64(n))
+	return n, err
+}
+
+// WithByteCounting wraps the dialer of every connection so that the bytes
+// it writes to and reads from the network are counted; see WireBytes.
+// Apply it after WithDialFunc, whose dialer it wraps.
+//
+// Counting happens below TLS, so the counts include TLS record overhead:
+// they are what the network actually carried.
+func WithByteCounting() Option {
+	return func(cc *pgx.ConnConfig) error {
+		dial := cc.DialFunc
+		if dial == nil {
+			var d net.Dialer
+			dial = d.DialContext
+		}
+		cc.DialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dial(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return &countingConn{Conn: conn}, nil
+		}
+		return nil
+	}
+}
+
+// WireBytes returns the number of bytes conn has written to and read from
+// the network since it was opened. ok is false unless the connection was
+// opened with WithByteCounting.
+//
+// Take the difference of two calls to measure one operation, e.g. a COPY:
+// rows per second hides the real cost of wide rows, bytes do not.
+func WireBytes(conn *pgx.Conn) (written, read int64, ok bool) {
+	netConn := conn.PgConn().Conn()
+	if tlsConn, isTLS := netConn.(*tls.Conn); isTLS {
+		netConn = tlsConn.NetConn()
+	}
+	counter, ok := netConn.(*countingConn)
+	if !ok {
+		return 0, 0, false
+	}
+	return counter.written.Load(), counter.read.Load(), true
+}
+
+// MBPerSec returns the throughput of bytes transferred in d, in megabytes
+// (10^6 bytes) per second. It returns 0 for a zero or negative d.
+func MBPerSec(bytes int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(bytes) / 1e6 / d.Seconds()
+}
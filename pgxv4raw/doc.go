@@ -0,0 +1,11 @@
+// Package pgxv4raw brings the Tx.Raw + CopyFrom flow to codebases still on
+// github.com/jackc/pgx/v4, whose stdlib.Conn is a different type from v5's.
+//
+// It is only compiled with the pgxv4 build tag, so that projects on pgx v5
+// do not link a second major version:
+//
+//	go build -tags pgxv4 ./...
+//
+// Importing it registers a txraw adapter that unwraps a v4 *stdlib.Conn to
+// its *pgx.Conn, so txraw.UnwrapAs works for v4 exactly as for v5.
+package pgxv4raw
@@ -0,0 +1,48 @@
+//go:build pgxv4
+
+package pgxv4raw
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
+
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// adapter unwraps pgx v4's database/sql connection to its *pgx.Conn.
+type adapter struct{}
+
+func (adapter) Match(conn driver.Conn) bool {
+	_, ok := conn.(*stdlib.Conn)
+	return ok
+}
+
+func (adapter) Unwrap(conn driver.Conn) any {
+	return conn.(*stdlib.Conn).Conn()
+}
+
+func init() {
+	txraw.RegisterAdapter("pgx/v4", adapter{})
+}
+
+// CopyFrom bulk-inserts rows into table within tx using pgx v4's CopyFrom.
+// It returns the number of rows copied.
+//
+// If tx is read-only, the error matches txraw.ErrReadOnly; if tx does not
+// run on pgx v4, it matches txraw.ErrUnsupportedDriver.
+func CopyFrom(ctx context.Context, tx txraw.RawTx, table pgx.Identifier, columns []string, src pgx.CopyFromSource) (int64, error) {
+	var copied int64
+	err := txraw.RawWrite(ctx, tx, "CopyFrom into "+table.Sanitize(), txraw.UnwrapAs(func(conn *pgx.Conn) error {
+		var err error
+		copied, err = conn.CopyFrom(ctx, table, columns, src)
+		if err != nil {
+			return fmt.Errorf("pgx v4 CopyFrom into %s failed: %w", table.Sanitize(), err)
+		}
+		return nil
+	}))
+	return copied, err
+}
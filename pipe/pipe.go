@@ -0,0 +1,74 @@
+// Package pipe copies rows from one PostgreSQL database into another as a
+// stream, without staging them on disk or in memory.
+//
+// COPY TO runs on the source and COPY FROM on the destination at the same
+// time, connected by an io.Pipe, each inside its own transaction. The
+// destination commits only if both sides completed, so a failed pipe leaves
+// nothing behind: a transactional cross-database replication primitive.
+package pipe
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/eqld/example-tx-raw/pgxraw"
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// ErrRowCountMismatch is returned when the destination loaded a different
+// number of rows than the source exported. The destination is rolled back.
+var ErrRowCountMismatch = errors.New("pipe: rows loaded differ from rows exported")
+
+// Copy streams the result of query on src into columns of table on dst and
+// returns the number of rows copied. Use "TABLE name" as query to copy a
+// whole table.
+//
+// Rows travel in PostgreSQL's binary COPY format, so they are never decoded
+// on the way; the query's result columns must have the same types as the
+// destination columns. The source is read in a read-only REPEATABLE READ
+// transaction, giving one consistent snapshot. The destination transaction
+// commits only after both COPYs succeeded and their row counts agree.
+func Copy(ctx context.Context, src, dst *sql.DB, query, table string, columns []string) (int64, error) {
+	var loaded int64
+	err := txraw.WithTx(ctx, dst, func(dstTx *txraw.Tx) error {
+		srcTx, err := txraw.Begin(ctx, src, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+		if err != nil {
+			return fmt.Errorf("pipe: source: %w", err)
+		}
+		// The source only reads, so ending its snapshot is all there is to do.
+		defer srcTx.Rollback()
+
+		pr, pw := io.Pipe()
+		var exported int64
+		exportErr := make(chan error, 1)
+		go func() {
+			var err error
+			exported, err = pgxraw.CopyTo(ctx, srcTx, query, pw, pgxraw.Binary)
+			pw.CloseWithError(err)
+			exportErr <- err
+		}()
+
+		loaded, err = pgxraw.CopyFromBinary(ctx, dstTx, table, columns, pr)
+		// Unblock the exporter if the destination gave up early; its error
+		// is then only the closed pipe, so the destination's error wins.
+		pr.CloseWithError(err)
+		srcErr := <-exportErr
+		if err != nil {
+			return fmt.Errorf("pipe: destination: %w", err)
+		}
+		if srcErr != nil {
+			return fmt.Errorf("pipe: source: %w", srcErr)
+		}
+		if loaded != exported {
+			return fmt.Errorf("%w: exported %d, loaded %d", ErrRowCountMismatch, exported, loaded)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return loaded, nil
+}
@@ -0,0 +1,90 @@
+// Package pqraw is the github.com/lib/pq counterpart of pgxraw: it lets
+// teams still on lib/pq use the same Raw-based transactional bulk insert,
+// driving pq's COPY FROM STDIN support (pq.CopyIn) directly on the
+// transaction's driver connection.
+package pqraw
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+
+	"github.com/lib/pq"
+
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// pqPkgPath is the package path of lib/pq's unexported driver connection.
+const pqPkgPath = "github.com/lib/pq"
+
+// IsPQ reports whether driverConn, as passed to a Raw callback, is a lib/pq
+// connection. lib/pq does not export its connection type, so this checks the
+// package the type comes from.
+func IsPQ(driverConn any) bool {
+	t := reflect.TypeOf(driverConn)
+	if t == nil {
+		return false
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.PkgPath() == pqPkgPath
+}
+
+// adapter registers lib/pq with txraw's adapter registry. lib/pq has no
+// separate native connection, so Unwrap returns the connection itself.
+type adapter struct{}
+
+func (adapter) Match(conn driver.Conn) bool { return IsPQ(conn) }
+func (adapter) Unwrap(conn driver.Conn) any { return conn }
+
+func init() {
+	txraw.RegisterAdapter("pq", adapter{})
+}
+
+// CopyIn bulk-inserts rows into table within tx using COPY FROM STDIN, the
+// lib/pq equivalent of pgx's CopyFrom. It returns the number of rows copied.
+// Values are converted with database/sql's default parameter conversion.
+//
+// If tx is read-only, the error matches txraw.ErrReadOnly; if tx does not
+// run on lib/pq, it matches txraw.ErrUnsupportedDriver.
+func CopyIn(ctx context.Context, tx txraw.RawTx, table string, columns []string, rows [][]any) (int64, error) {
+	var copied int64
+	err := txraw.RawWrite(ctx, tx, "CopyIn into "+table, func(driverConn any) error {
+		if !IsPQ(driverConn) {
+			return fmt.Errorf("%w: driverConn is not a lib/pq connection, got %T", txraw.ErrUnsupportedDriver, driverConn)
+		}
+		conn := driverConn.(driver.Conn)
+
+		stmt, err := conn.Prepare(pq.CopyIn(table, columns...))
+		if err != nil {
+			return fmt.Errorf("preparing COPY %s failed: %w", table, err)
+		}
+		defer stmt.Close()
+
+		values := make([]driver.Value, len(columns))
+		for i, row := range rows {
+			if len(row) != len(columns) {
+				return fmt.Errorf("row %d has %d values, want %d", i+1, len(row), len(columns))
+			}
+			for j, v := range row {
+				if values[j], err = driver.DefaultParameterConverter.ConvertValue(v); err != nil {
+					return fmt.Errorf("row %d, column %s: %w", i+1, columns[j], err)
+				}
+			}
+			if _, err := stmt.Exec(values); err != nil {
+				return fmt.Errorf("COPY %s row %d failed: %w", table, i+1, err)
+			}
+		}
+
+		// An Exec without values flushes the buffered rows and ends the COPY.
+		result, err := stmt.Exec(nil)
+		if err != nil {
+			return fmt.Errorf("finishing COPY %s failed: %w", table, err)
+		}
+		copied, err = result.RowsAffected()
+		return err
+	})
+	return copied, err
+}
@@ -0,0 +1,225 @@
+// Package purge bulk-expires old rows from time-series tables.
+//
+// Users who bulk-load time-series data with CopyFrom eventually need to
+// bulk-expire it as well. Deleting millions of rows in one statement holds
+// locks and generates WAL for the whole duration, so this package works in
+// small batched transactions, each guarded by a lock timeout and separated by
+// an optional pause to leave room for concurrent traffic.
+package purge
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/eqld/example-tx-raw/clock"
+)
+
+// DefaultBatchSize is the number of rows deleted per transaction when
+// Options.BatchSize is not set.
+const DefaultBatchSize = 10000
+
+// Options configures a purge run.
+type Options struct {
+	// Table is the (optionally schema-qualified) table to purge.
+	Table string
+	// Column is the timestamp column compared against Before.
+	Column string
+	// Before is the cutoff: rows with Column < Before are expired.
+	Before time.Time
+
+	// BatchSize caps the number of rows deleted per transaction.
+	BatchSize int
+	// LockTimeout is applied with SET LOCAL to every batch transaction so a
+	// purge never queues behind long-running locks. Zero leaves the server
+	// default in place. It is rounded up to whole milliseconds, the
+	// resolution of lock_timeout, so a sub-millisecond value does not turn
+	// into 0, which would disable the timeout.
+	LockTimeout time.Duration
+	// Pause is slept between batches to pace the purge.
+	Pause time.Duration
+	// Clock times the pauses. Nil means clock.Real.
+	Clock clock.Clock
+
+	// DetachPartitions detaches range partitions of Table whose upper bound
+	// is at or before Before, instead of deleting their rows one by one.
+	DetachPartitions bool
+	// DropDetached drops partitions after detaching them.
+	DropDetached bool
+}
+
+// Result summarizes a purge run.
+type Result struct {
+	RowsDeleted int64
+	Batches     int
+	Detached    []string
+}
+
+// Purge expires rows older than opts.Before from opts.Table.
+//
+// When DetachPartitions is set, whole partitions that lie entirely before the
+// cutoff are detached (and optionally dropped) first. The remaining rows are
+// then deleted in batches of BatchSize, each in its own transaction, until no
+// expired rows are left or ctx is done.
+func Purge(ctx context.Context, db *sql.DB, opts Options) (Result, error) {
+	var res Result
+
+	if opts.Table == "" || opts.Column == "" {
+		return res, errors.New("purge: Table and Column are required")
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+
+	if opts.DetachPartitions {
+		detached, err := detachPartitions(ctx, db, opts)
+		res.Detached = detached
+		if err != nil {
+			return res, err
+		}
+	}
+
+	query := deleteStatement(opts.Table, opts.Column)
+	for {
+		var deleted int64
+		err := inTx(ctx, db, opts.LockTimeout, func(tx *sql.Tx) error {
+			result, err := tx.ExecContext(ctx, query, opts.Before, opts.BatchSize)
+			if err != nil {
+				return fmt.Errorf("batched DELETE failed: %w", err)
+			}
+			deleted, err = result.RowsAffected()
+			return err
+		})
+		if err != nil {
+			return res, err
+		}
+
+		res.Batches++
+		res.RowsDeleted += deleted
+		if deleted < int64(opts.BatchSize) {
+			return res, nil
+		}
+
+		if err := clock.Sleep(ctx, clock.Or(opts.Clock), opts.Pause); err != nil {
+			return res, err
+		}
+	}
+}
+
+// detachPartitions detaches every range partition of opts.Table whose upper
+// bound is at or before opts.Before. Each partition is handled in its own
+// transaction so a lock timeout on one does not undo the others.
+func detachPartitions(ctx context.Context, db *sql.DB, opts Options) ([]string, error) {
+	partitions, err := expiredPartitions(ctx, db, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	parent := identifier(opts.Table)
+	var detached []string
+	for _, partition := range partitions {
+		err := inTx(ctx, db, opts.LockTimeout, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s DETACH PARTITION %s", parent, partition)); err != nil {
+				return fmt.Errorf("DETACH PARTITION %s failed: %w", partition, err)
+			}
+			if opts.DropDetached {
+				if _, err := tx.ExecContext(ctx, fmt.Sprintf("DROP TABLE %s", partition)); err != nil {
+					return fmt.Errorf("DROP TABLE %s failed: %w", partition, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return detached, err
+		}
+		detached = append(detached, partition)
+
+		if err := clock.Sleep(ctx, clock.Or(opts.Clock), opts.Pause); err != nil {
+			return detached, err
+		}
+	}
+	return detached, nil
+}
+
+// expiredPartitions lists the range partitions of opts.Table whose upper
+// bound is at or before opts.Before. Partition names are returned already
+// quoted, as rendered by regclass.
+func expiredPartitions(ctx context.Context, db *sql.DB, opts Options) ([]string, error) {
+	const query = `
+		SELECT c.oid::regclass::text
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = $1::regclass
+		  AND pg_get_expr(c.relpartbound, c.oid) LIKE 'FOR VALUES FROM%'
+		  AND substring(pg_get_expr(c.relpartbound, c.oid) FROM 'TO \(''([^'']*)''\)')::timestamptz <= $2
+		ORDER BY 1`
+
+	rows, err := db.QueryContext(ctx, query, identifier(opts.Table), opts.Before)
+	if err != nil {
+		return nil, fmt.Errorf("listing partitions of %s failed: %w", opts.Table, err)
+	}
+	defer rows.Close()
+
+	var partitions []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scanning partition name failed: %w", err)
+		}
+		partitions = append(partitions, name)
+	}
+	return partitions, rows.Err()
+}
+
+// inTx runs f in its own transaction with the given lock timeout, committing
+// on success and rolling back otherwise.
+func inTx(ctx context.Context, db *sql.DB, lockTimeout time.Duration, f func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin purge transaction: %w", err)
+	}
+
+	if lockTimeout > 0 {
+		if _, err := tx.ExecContext(ctx, lockTimeoutStatement(lockTimeout)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("SET LOCAL lock_timeout failed: %w", err)
+		}
+	}
+
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit purge transaction: %w", err)
+	}
+	return nil
+}
+
+// deleteStatement returns the batched DELETE of rows of table whose column
+// is before $1, at most $2 of them.
+func deleteStatement(table, column string) string {
+	// (tableoid, ctid) identifies a row uniquely even on a partitioned
+	// parent, where ctid alone may repeat across partitions.
+	return fmt.Sprintf(
+		"DELETE FROM %[1]s WHERE (tableoid, ctid) IN (SELECT tableoid, ctid FROM %[1]s WHERE %[2]s < $1 LIMIT $2)",
+		identifier(table), identifier(column))
+}
+
+// lockTimeoutStatement returns the SET LOCAL for a positive lock timeout d,
+// rounded up to whole milliseconds.
+func lockTimeoutStatement(d time.Duration) string {
+	ms := (d + time.Millisecond - 1) / time.Millisecond
+	return fmt.Sprintf("SET LOCAL lock_timeout = %d", ms)
+}
+
+// identifier quotes a possibly schema-qualified name for use in SQL.
+func identifier(name string) string {
+	return pgx.Identifier(strings.Split(name, ".")).Sanitize()
+}
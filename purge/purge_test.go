@@ -0,0 +1,143 @@
+package purge
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLockTimeoutStatement(t *testing.T) {
+	for _, tt := range []struct {
+		timeout time.Duration
+		want    string
+	}{
+		{time.Nanosecond, "SET LOCAL lock_timeout = 1"},
+		{500 * time.Microsecond, "SET LOCAL lock_timeout = 1"},
+		{time.Millisecond, "SET LOCAL lock_timeout = 1"},
+		{time.Millisecond + time.Nanosecond, "SET LOCAL lock_timeout = 2"},
+		{2 * time.Second, "SET LOCAL lock_timeout = 2000"},
+	} {
+		if got := lockTimeoutStatement(tt.timeout); got != tt.want {
+			t.Errorf("lockTimeoutStatement(%v) = %q, want %q", tt.timeout, got, tt.want)
+		}
+	}
+}
+
+func TestDeleteStatement(t *testing.T) {
+	for _, tt := range []struct {
+		table, column, want string
+	}{
+		{"events", "created_at",
+			`DELETE FROM "events" WHERE (tableoid, ctid) IN (SELECT tableoid, ctid FROM "events" WHERE "created_at" < $1 LIMIT $2)`},
+		{"metrics.samples", "Time",
+			`DELETE FROM "metrics"."samples" WHERE (tableoid, ctid) IN (SELECT tableoid, ctid FROM "metrics"."samples" WHERE "Time" < $1 LIMIT $2)`},
+		{`odd"name`, "ts",
+			`DELETE FROM "odd""name" WHERE (tableoid, ctid) IN (SELECT tableoid, ctid FROM "odd""name" WHERE "ts" < $1 LIMIT $2)`},
+	} {
+		if got := deleteStatement(tt.table, tt.column); got != tt.want {
+			t.Errorf("deleteStatement(%q, %q) =\n%s\nwant\n%s", tt.table, tt.column, got, tt.want)
+		}
+	}
+}
+
+func TestPurgeBatches(t *testing.T) {
+	conn := &fakeConn{deleted: []int64{3, 3, 1}}
+	db := sql.OpenDB(fakeConnector{conn})
+	defer db.Close()
+
+	res, err := Purge(context.Background(), db, Options{
+		Table:       "events",
+		Column:      "created_at",
+		Before:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		BatchSize:   3,
+		LockTimeout: 250 * time.Microsecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Batches != 3 || res.RowsDeleted != 7 {
+		t.Errorf("Purge = %d batches, %d rows; want 3 batches, 7 rows", res.Batches, res.RowsDeleted)
+	}
+
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if conn.commits != 3 {
+		t.Errorf("%d commits, want one per batch", conn.commits)
+	}
+	var timeouts, deletes int
+	for _, stmt := range conn.execs {
+		switch {
+		case stmt == "SET LOCAL lock_timeout = 1":
+			timeouts++
+		case strings.HasPrefix(stmt, "DELETE FROM"):
+			deletes++
+		default:
+			t.Errorf("unexpected statement %q", stmt)
+		}
+	}
+	if timeouts != 3 || deletes != 3 {
+		t.Errorf("%d lock timeouts and %d deletes, want 3 of each", timeouts, deletes)
+	}
+}
+
+func TestPurgeRequiresTableAndColumn(t *testing.T) {
+	if _, err := Purge(context.Background(), nil, Options{Table: "events"}); err == nil {
+		t.Error("Purge without Column succeeded")
+	}
+}
+
+// fakeConnector hands out its one connection, which answers each DELETE with
+// the next count in deleted and records the statements it runs.
+type fakeConnector struct{ conn *fakeConn }
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) { return c.conn, nil }
+func (c fakeConnector) Driver() driver.Driver                        { return fakeDriver{c} }
+
+type fakeDriver struct{ c fakeConnector }
+
+func (d fakeDriver) Open(string) (driver.Conn, error) { return d.c.conn, nil }
+
+type fakeConn struct {
+	mu      sync.Mutex
+	deleted []int64
+	execs   []string
+	commits int
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fake driver: Prepare not supported")
+}
+
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{c}, nil }
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.execs = append(c.execs, query)
+	if !strings.HasPrefix(query, "DELETE") {
+		return driver.RowsAffected(0), nil
+	}
+	if len(c.deleted) == 0 {
+		return nil, errors.New("fake driver: more DELETEs than expected")
+	}
+	n := c.deleted[0]
+	c.deleted = c.deleted[1:]
+	return driver.RowsAffected(n), nil
+}
+
+type fakeTx struct{ c *fakeConn }
+
+func (tx fakeTx) Commit() error {
+	tx.c.mu.Lock()
+	defer tx.c.mu.Unlock()
+	tx.c.commits++
+	return nil
+}
+
+func (tx fakeTx) Rollback() error { return nil }
@@ -0,0 +1,221 @@
+// Package quota enforces per-job resource limits, so that a misconfigured
+// source cannot run away with cluster resources: a job stops with an error
+// matching ErrQuotaExceeded as soon as it loads more rows or bytes, runs
+// longer, or spills more temporary data than it was allowed.
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/eqld/example-tx-raw/source"
+)
+
+// ErrQuotaExceeded matches every *ExceededError.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Limits are the quotas of one job. Zero fields are unlimited.
+type Limits struct {
+	// MaxRows caps the number of rows loaded.
+	MaxRows int64
+	// MaxBytes caps the approximate size of the values loaded (see Size).
+	MaxBytes int64
+	// MaxDuration caps the wall-clock time of the job.
+	MaxDuration time.Duration
+	// MaxTempDisk caps the bytes of temporary files, as reported by the
+	// TempUsage passed to CheckTempDisk.
+	MaxTempDisk int64
+}
+
+// ExceededError reports which limit a job crossed.
+type ExceededError struct {
+	// Limit is "rows", "bytes", "duration" or "temp disk".
+	Limit string
+	// Used is the amount consumed when the limit was crossed; for duration
+	// it is in nanoseconds.
+	Used int64
+	// Max is the configured limit, in the same unit as Used.
+	Max int64
+}
+
+func (e *ExceededError) Error() string {
+	if e.Limit == "duration" {
+		return fmt.Sprintf("quota exceeded: %s limit of %s", e.Limit, time.Duration(e.Max))
+	}
+	return fmt.Sprintf("quota exceeded: %s %d over limit of %d", e.Limit, e.Used, e.Max)
+}
+
+// Is makes errors.Is(err, ErrQuotaExceeded) true.
+func (e *ExceededError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// TempUsage reports the bytes of temporary files a job holds.
+// *tempfiles.Manager implements it.
+type TempUsage interface {
+	Usage() (int64, error)
+}
+
+// Job tracks the consumption of one job against its Limits. It is safe for
+// concurrent use.
+type Job struct {
+	limits Limits
+
+	mu    sync.Mutex
+	rows  int64
+	bytes int64
+	err   error
+}
+
+// Start begins a job with limits. The returned context is cancelled with an
+// *ExceededError as its cause once MaxDuration has elapsed; run the job under
+// it, check context.Cause(ctx) against ErrQuotaExceeded when it fails, and
+// call cancel when the job ends.
+func Start(ctx context.Context, limits Limits) (*Job, context.Context, context.CancelFunc) {
+	job := &Job{limits: limits}
+	if limits.MaxDuration <= 0 {
+		ctx, cancel := context.WithCancel(ctx)
+		return job, ctx, cancel
+	}
+	cause := &ExceededError{Limit: "duration", Used: int64(limits.MaxDuration), Max: int64(limits.MaxDuration)}
+	ctx, cancel := context.WithTimeoutCause(ctx, limits.MaxDuration, cause)
+	return job, ctx, cancel
+}
+
+// Err returns the first row, byte or temp disk quota error of the job, or
+// nil. The duration limit is reported through the context from Start.
+func (j *Job) Err() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.err
+}
+
+// Add records rows rows of size bytes and returns an *ExceededError once the
+// job is over its row or byte limit. Once exceeded, every later call fails
+// with the same error.
+func (j *Job) Add(rows, bytes int64) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.err != nil {
+		return j.err
+	}
+
+	j.rows += rows
+	j.bytes += bytes
+	switch {
+	case j.limits.MaxRows > 0 && j.rows > j.limits.MaxRows:
+		j.err = &ExceededError{Limit: "rows", Used: j.rows, Max: j.limits.MaxRows}
+	case j.limits.MaxBytes > 0 && j.bytes > j.limits.MaxBytes:
+		j.err = &ExceededError{Limit: "bytes", Used: j.bytes, Max: j.limits.MaxBytes}
+	}
+	return j.err
+}
+
+// CheckTempDisk measures usage and returns an *ExceededError if the job holds
+// more temporary data than MaxTempDisk.
+func (j *Job) CheckTempDisk(usage TempUsage) error {
+	if j.limits.MaxTempDisk <= 0 {
+		return j.Err()
+	}
+	used, err := usage.Usage()
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.err == nil && used > j.limits.MaxTempDisk {
+		j.err = &ExceededError{Limit: "temp disk", Used: used, Max: j.limits.MaxTempDisk}
+	}
+	return j.err
+}
+
+// Size approximates the loaded size of a row: the length of strings and byte
+// slices, and 8 bytes for any other non-nil value.
+func Size(row []any) int64 {
+	var n int64
+	for _, v := range row {
+		switch v := v.(type) {
+		case nil:
+		case string:
+			n += int64(len(v))
+		case []byte:
+			n += int64(len(v))
+		default:
+			n += 8
+		}
+	}
+	return n
+}
+
+// CopyFromSource wraps src so that every row pgx.CopyFrom reads is counted
+// against j. Crossing a limit ends the copy with the *ExceededError, which
+// aborts the COPY and leaves the transaction to be rolled back.
+func (j *Job) CopyFromSource(src pgx.CopyFromSource) pgx.CopyFromSource {
+	return &copyFromSource{src: src, job: j}
+}
+
+type copyFromSource struct {
+	src pgx.CopyFromSource
+	job *Job
+	err error
+}
+
+func (s *copyFromSource) Next() bool {
+	return s.err == nil && s.src.Next()
+}
+
+func (s *copyFromSource) Values() ([]any, error) {
+	values, err := s.src.Values()
+	if err != nil {
+		return nil, err
+	}
+	if s.err = s.job.Add(1, Size(values)); s.err != nil {
+		return nil, s.err
+	}
+	return values, nil
+}
+
+func (s *copyFromSource) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.src.Err()
+}
+
+// Source wraps src so that every batch handed to source.Consume is counted
+// against j before it is loaded. A batch that would cross a limit is not
+// handed out; Next returns the *ExceededError and Consume stops.
+func (j *Job) Source(src source.Source) source.Source {
+	return &batchSource{Source: src, job: j}
+}
+
+type batchSource struct {
+	source.Source
+	job *Job
+}
+
+func (s *batchSource) Next(ctx context.Context) (source.Batch, error) {
+	batch, err := s.Source.Next(ctx)
+	if err != nil {
+		return batch, err
+	}
+
+	var size int64
+	for _, row := range batch.Rows {
+		size += Size(row)
+	}
+	if err := s.job.Add(int64(len(batch.Rows)), size); err != nil {
+		// Hand the batch back so it is redelivered to a later run.
+		if nackErr := s.Source.Nack(ctx, batch.ID); nackErr != nil {
+			return source.Batch{}, fmt.Errorf("%w (Nack of batch %s also failed: %w)", err, batch.ID, nackErr)
+		}
+		return source.Batch{}, err
+	}
+	return batch, nil
+}
@@ -0,0 +1,59 @@
+// Package random is the seam between the loaders and randomness. Backoff
+// jitter, sampling and generated test data draw from a Rand instead of the
+// global math/rand/v2 functions, so a run can be replayed exactly by seeding
+// it with New.
+package random
+
+import (
+	"math/rand/v2"
+	"sync"
+)
+
+// Rand is the subset of *rand.Rand the loaders use.
+type Rand interface {
+	// Int64N returns a value in [0, n). It panics if n <= 0.
+	Int64N(n int64) int64
+	// Float64 returns a value in [0.0, 1.0).
+	Float64() float64
+}
+
+// Default draws from the global, randomly seeded math/rand/v2 source.
+var Default Rand = globalRand{}
+
+// Or returns r, or Default if r is nil, so that a zero-valued Rand field in
+// an options struct means non-deterministic randomness.
+func Or(r Rand) Rand {
+	if r == nil {
+		return Default
+	}
+	return r
+}
+
+// New returns a deterministic Rand seeded with seed. Unlike *rand.Rand it is
+// safe for concurrent use, although concurrent callers make the sequence
+// each of them sees depend on scheduling.
+func New(seed uint64) Rand {
+	return &lockedRand{r: rand.New(rand.NewPCG(seed, seed))}
+}
+
+type globalRand struct{}
+
+func (globalRand) Int64N(n int64) int64 { return rand.Int64N(n) }
+func (globalRand) Float64() float64     { return rand.Float64() }
+
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func (l *lockedRand) Int64N(n int64) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Int64N(n)
+}
+
+func (l *lockedRand) Float64() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Float64()
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/eqld/example-tx-raw/checkpoint"
+	"github.com/eqld/example-tx-raw/pgxraw"
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+const (
+	// resumableTable is loaded by the resumable scenario. It is separate from
+	// items, which the other scenarios clear, so an interrupted load survives
+	// until the next run with -resume.
+	resumableTable = "items_resumable"
+
+	// resumableKey identifies the resumable load in txraw_checkpoints.
+	resumableKey = "demo/items_resumable"
+
+	resumableRows      = 50
+	resumableChunkSize = 10
+	// resumableProgressRows is how often the scenario reports progress.
+	resumableProgressRows = 15
+	// resumableInterrupt is the row at which a fresh run simulates a crash.
+	resumableInterrupt = 27
+)
+
+// errInterrupted simulates the load process dying partway through.
+var errInterrupted = errors.New("simulated interruption")
+
+// demonstrateResumableLoad loads resumableTable in committed chunks and
+// records a checkpoint with every chunk. A fresh run starts from zero and is
+// interrupted partway through; a run with -resume continues from the last
+// checkpoint instead of reloading the rows that were already committed.
+func demonstrateResumableLoad(ctx context.Context, db *sql.DB, resume bool) scenarioResult {
+	log.Println("--- Extra scenario: Resumable chunked CopyFrom with checkpoints ---")
+
+	if _, err := db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		id SERIAL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		data TEXT
+	)`, resumableTable)); err != nil {
+		log.Fatalf("Failed to create %s: %v", resumableTable, err)
+	}
+	if err := checkpoint.EnsureTable(ctx, db); err != nil {
+		log.Fatalf("Failed to prepare checkpoints: %v", err)
+	}
+
+	var done int64
+	if resume {
+		var err error
+		done, err = checkpoint.Load(ctx, db, resumableKey)
+		if err != nil {
+			log.Fatalf("Failed to load checkpoint: %v", err)
+		}
+		log.Printf("✓ Resuming from checkpoint: %d of %d rows already loaded", done, resumableRows)
+	} else {
+		if err := checkpoint.Clear(ctx, db, resumableKey); err != nil {
+			log.Fatalf("Failed to clear checkpoint: %v", err)
+		}
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s", resumableTable)); err != nil {
+			log.Fatalf("Failed to clear %s: %v", resumableTable, err)
+		}
+		log.Printf("✓ Starting from zero; the load will be interrupted at row %d", resumableInterrupt)
+	}
+
+	var src pgx.CopyFromSource = pgx.CopyFromRows(generateSampleData(resumableRows, "Resumable"))
+	src, err := checkpoint.Skip(src, done)
+	if err != nil {
+		log.Fatalf("Failed to skip to checkpoint: %v", err)
+	}
+	// The COPY error comes back from the server and does not wrap
+	// errInterrupted, so the source itself records whether it fired
+	interrupter := &interruptingSource{CopyFromSource: src, after: resumableInterrupt}
+	if !resume {
+		src = interrupter
+	}
+
+	src = pgxraw.WithProgress(src, func(rows int64, elapsed time.Duration) {
+		log.Printf("  progress: %d/%d rows after %s", done+rows, resumableRows, elapsed.Round(time.Microsecond))
+	}, pgxraw.ProgressOptions{EveryRows: resumableProgressRows, Interval: time.Second})
+
+	results, err := pgxraw.CopyFromChunks(ctx, db, resumableTable, []string{"name", "data"}, src, pgxraw.ChunkOptions{
+		Size:       resumableChunkSize,
+		CommitEach: true,
+		OnChunk: func(ctx context.Context, tx *txraw.Tx, rows int64) error {
+			return checkpoint.Save(ctx, tx, resumableKey, done+rows)
+		},
+	})
+	var loaded, sent int64
+	var elapsed time.Duration
+	for _, r := range results {
+		loaded += r.Rows
+		sent += r.Bytes
+		elapsed += r.Duration
+	}
+	log.Printf("✓ Copied %d rows in %d committed chunks (%d bytes, %.2f MB/s)",
+		loaded, len(results), sent, pgxraw.MBPerSec(sent, elapsed))
+
+	var persisted int
+	if err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s", resumableTable)).Scan(&persisted); err != nil {
+		log.Fatalf("Failed to count rows in %s: %v", resumableTable, err)
+	}
+
+	if interrupter.err != nil {
+		log.Printf("⚠️  Load interrupted: %v", err)
+		log.Printf("✓ Result: %d rows persisted; checkpoint at %d. Rerun with -resume to continue", persisted, done+loaded)
+		log.Println()
+		return scenarioResult{loaded: int(loaded), persisted: persisted, bytes: sent, err: err}
+	}
+	if err != nil {
+		log.Printf("✗ Resumable load failed: %v", err)
+		log.Println()
+		return scenarioResult{loaded: int(loaded), persisted: persisted, bytes: sent, err: err}
+	}
+
+	if err := checkpoint.Clear(ctx, db, resumableKey); err != nil {
+		log.Printf("⚠️  %v", err)
+	}
+	log.Printf("✓ Result: %d rows persisted after the load completed (Expected: %d)", persisted, resumableRows)
+	if persisted != resumableRows {
+		log.Printf("✗ ERROR: Row count mismatch for the resumable load!")
+	}
+	log.Println()
+	return scenarioResult{loaded: int(loaded), persisted: persisted, bytes: sent}
+}
+
+// interruptingSource fails with errInterrupted once after rows have been
+// produced, as if the loading process had been killed.
+type interruptingSource struct {
+	pgx.CopyFromSource
+	after int
+	n     int
+	err   error
+}
+
+func (s *interruptingSource) Next() bool {
+	if s.n >= s.after {
+		s.err = errInterrupted
+		return false
+	}
+	if !s.CopyFromSource.Next() {
+		return false
+	}
+	s.n++
+	return true
+}
+
+func (s *interruptingSource) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.CopyFromSource.Err()
+}
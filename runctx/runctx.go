@@ -0,0 +1,104 @@
+// Package runctx threads tenant, trace and job identifiers through every
+// subsystem of a load via context.Context, so logging, application_name and
+// any future metrics or audit records label work consistently instead of each
+// feature inventing its own plumbing.
+package runctx
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// maxApplicationName is PostgreSQL's NAMEDATALEN-1 limit for application_name.
+const maxApplicationName = 63
+
+// Labels identify the work a context belongs to. Empty fields are omitted.
+type Labels struct {
+	Tenant string
+	Trace  string
+	Job    string
+}
+
+type labelsKey struct{}
+
+// WithLabels returns a copy of ctx carrying labels. Non-empty fields of labels
+// override those already present in ctx; empty ones are inherited.
+func WithLabels(ctx context.Context, labels Labels) context.Context {
+	merged := FromContext(ctx)
+	if labels.Tenant != "" {
+		merged.Tenant = labels.Tenant
+	}
+	if labels.Trace != "" {
+		merged.Trace = labels.Trace
+	}
+	if labels.Job != "" {
+		merged.Job = labels.Job
+	}
+	return context.WithValue(ctx, labelsKey{}, merged)
+}
+
+// FromContext returns the labels carried by ctx, or the zero Labels.
+func FromContext(ctx context.Context) Labels {
+	labels, _ := ctx.Value(labelsKey{}).(Labels)
+	return labels
+}
+
+// IsZero reports whether no label is set.
+func (l Labels) IsZero() bool {
+	return l == Labels{}
+}
+
+// String renders the labels as space-separated key=value pairs, e.g.
+// "tenant=acme job=nightly".
+func (l Labels) String() string {
+	var parts []string
+	for _, kv := range [][2]string{{"tenant", l.Tenant}, {"trace", l.Trace}, {"job", l.Job}} {
+		if kv[1] != "" {
+			parts = append(parts, kv[0]+"="+kv[1])
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// ApplicationName returns a PostgreSQL application_name for base with the
+// labels appended, truncated to the server's 63-byte limit, so sessions can be
+// attributed in pg_stat_activity and server logs.
+func (l Labels) ApplicationName(base string) string {
+	name := base
+	if s := l.String(); s != "" {
+		name = strings.TrimSpace(base + " " + s)
+	}
+	if len(name) > maxApplicationName {
+		name = name[:maxApplicationName]
+	}
+	return name
+}
+
+// Attrs returns the labels as slog attributes for structured logging.
+func (l Labels) Attrs() []slog.Attr {
+	var attrs []slog.Attr
+	if l.Tenant != "" {
+		attrs = append(attrs, slog.String("tenant", l.Tenant))
+	}
+	if l.Trace != "" {
+		attrs = append(attrs, slog.String("trace", l.Trace))
+	}
+	if l.Job != "" {
+		attrs = append(attrs, slog.String("job", l.Job))
+	}
+	return attrs
+}
+
+// Logger returns logger annotated with the labels of ctx.
+func Logger(ctx context.Context, logger *slog.Logger) *slog.Logger {
+	attrs := FromContext(ctx).Attrs()
+	if len(attrs) == 0 {
+		return logger
+	}
+	args := make([]any, len(attrs))
+	for i, a := range attrs {
+		args[i] = a
+	}
+	return logger.With(args...)
+}
@@ -0,0 +1,95 @@
+// Package runlog persists a structured summary of every load into a
+// txraw_runs table in the target database, so load history can be queried
+// with SQL next to the data it describes.
+//
+// Entries are written with their own statement on the pool, outside the
+// data transaction: a rolled-back load is recorded just like a committed one.
+package runlog
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/eqld/example-tx-raw/runctx"
+)
+
+// Schema creates the txraw_runs table if it does not exist yet.
+const Schema = `
+CREATE TABLE IF NOT EXISTS txraw_runs (
+    id             BIGSERIAL PRIMARY KEY,
+    job            TEXT NOT NULL DEFAULT '',
+    trace          TEXT NOT NULL DEFAULT '',
+    tenant         TEXT NOT NULL DEFAULT '',
+    scenario       TEXT NOT NULL,
+    strategy       TEXT NOT NULL,
+    rows_loaded    BIGINT NOT NULL DEFAULT 0,
+    rows_persisted BIGINT NOT NULL DEFAULT 0,
+    bytes_sent     BIGINT NOT NULL DEFAULT 0,
+    started_at     TIMESTAMPTZ NOT NULL,
+    duration       INTERVAL NOT NULL,
+    error          TEXT
+)`
+
+// migrations bring a txraw_runs table created by an earlier Schema up to
+// date.
+var migrations = []string{
+	`ALTER TABLE txraw_runs ADD COLUMN IF NOT EXISTS bytes_sent BIGINT NOT NULL DEFAULT 0`,
+}
+
+// Entry is the summary of one load.
+type Entry struct {
+	// Scenario names the load, e.g. "transaction-commit".
+	Scenario string
+	// Strategy is how rows were written, e.g. "copy-from", "pq-copy-in".
+	Strategy string
+	// RowsLoaded is the number of rows sent to the server.
+	RowsLoaded int64
+	// RowsPersisted is the number of rows found in the table afterwards.
+	RowsPersisted int64
+	// BytesSent is the number of bytes written to the network for the rows,
+	// or 0 if they were not counted (see pgxraw.WithByteCounting).
+	BytesSent int64
+	StartedAt time.Time
+	Duration  time.Duration
+	// Err is the error the load ended with, if any.
+	Err error
+}
+
+// EnsureTable creates txraw_runs in db if needed.
+func EnsureTable(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, Schema); err != nil {
+		return fmt.Errorf("runlog: creating txraw_runs failed: %w", err)
+	}
+	for _, migration := range migrations {
+		if _, err := db.ExecContext(ctx, migration); err != nil {
+			return fmt.Errorf("runlog: migrating txraw_runs failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// Record inserts entry into txraw_runs and returns its id. Job, trace and
+// tenant are taken from the runctx labels of ctx.
+func Record(ctx context.Context, db *sql.DB, entry Entry) (int64, error) {
+	labels := runctx.FromContext(ctx)
+
+	var errText sql.NullString
+	if entry.Err != nil {
+		errText = sql.NullString{String: entry.Err.Error(), Valid: true}
+	}
+
+	var id int64
+	err := db.QueryRowContext(ctx, `
+		INSERT INTO txraw_runs (job, trace, tenant, scenario, strategy, rows_loaded, rows_persisted, bytes_sent, started_at, duration, error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, make_interval(secs => $10), $11)
+		RETURNING id`,
+		labels.Job, labels.Trace, labels.Tenant, entry.Scenario, entry.Strategy,
+		entry.RowsLoaded, entry.RowsPersisted, entry.BytesSent, entry.StartedAt, entry.Duration.Seconds(), errText,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("runlog: recording run failed: %w", err)
+	}
+	return id, nil
+}
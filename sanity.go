@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/eqld/example-tx-raw/rowhash"
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// rawPathRun is what one extraction path observed.
+type rawPathRun struct {
+	driverConnType string
+	adapter        string
+	bytes          int64
+	rows           int
+	checksum       string
+}
+
+// demonstrateSanityCheck performs the same COPY twice, once through the
+// official sql.Conn.Raw outside a transaction and once through txraw's
+// Tx.Raw inside one, and compares what both paths saw and stored. It is a
+// living regression check for the extraction strategy: after a Go or driver
+// upgrade, any difference between the paths shows up here first.
+//
+// Stored rows are compared by a streaming checksum with alg, so neither
+// path's rows are held in memory.
+func demonstrateSanityCheck(ctx context.Context, db *sql.DB, alg rowhash.Algorithm) scenarioResult {
+	log.Println("--- Sanity check: sql.Conn.Raw vs Tx.Raw on the same COPY ---")
+
+	sampleData := generateSampleData(25, "Sanity")
+
+	if err := clearTable(ctx, db); err != nil {
+		log.Fatalf("Failed to clear table: %v", err)
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		log.Fatalf("db.Conn failed: %v", err)
+	}
+	var viaConn rawPathRun
+	err = conn.Raw(func(driverConn any) error {
+		viaConn, err = runRawPath(ctx, driverConn, sampleData)
+		return err
+	})
+	conn.Close()
+	if err != nil {
+		log.Printf("✗ sql.Conn.Raw path failed: %v", err)
+		return scenarioResult{loaded: len(sampleData), err: err}
+	}
+	if viaConn.rows, viaConn.checksum, err = checksumItems(ctx, db, alg); err != nil {
+		log.Fatalf("Failed to read rows (sql.Conn.Raw path): %v", err)
+	}
+
+	if err := clearTable(ctx, db); err != nil {
+		log.Fatalf("Failed to clear table: %v", err)
+	}
+	var viaTx rawPathRun
+	err = txraw.WithTx(ctx, db, func(tx *txraw.Tx) error {
+		return tx.RawContext(ctx, func(driverConn any) (err error) {
+			viaTx, err = runRawPath(ctx, driverConn, sampleData)
+			return err
+		})
+	})
+	if err != nil {
+		log.Printf("✗ Tx.Raw path failed: %v", err)
+		return scenarioResult{loaded: len(sampleData), err: err}
+	}
+	if viaTx.rows, viaTx.checksum, err = checksumItems(ctx, db, alg); err != nil {
+		log.Fatalf("Failed to read rows (Tx.Raw path): %v", err)
+	}
+
+	mechanism := "reflection fallback"
+	if txraw.HasOfficialRaw() {
+		mechanism = "official sql.Tx.Raw"
+	}
+	log.Printf("Tx.Raw used the %s", mechanism)
+
+	checks := []struct {
+		name     string
+		ok       bool
+		got, exp any
+	}{
+		{"driver connection type", viaTx.driverConnType == viaConn.driverConnType, viaTx.driverConnType, viaConn.driverConnType},
+		{"resolved adapter", viaTx.adapter == viaConn.adapter, viaTx.adapter, viaConn.adapter},
+		{"rows stored", viaTx.rows == viaConn.rows, viaTx.rows, viaConn.rows},
+		{"row contents (" + string(alg) + ")", viaTx.checksum == viaConn.checksum, viaTx.checksum, viaConn.checksum},
+	}
+	// Bytes are informational only: the second COPY may reuse the cached
+	// statement description of the first on the same pooled connection.
+	log.Printf("Bytes sent: Tx.Raw %d, sql.Conn.Raw %d", viaTx.bytes, viaConn.bytes)
+
+	var mismatch error
+	for _, c := range checks {
+		if c.ok {
+			log.Printf("✓ Same %s on both paths", c.name)
+			continue
+		}
+		log.Printf("✗ ERROR: %s differs: Tx.Raw %v, sql.Conn.Raw %v", c.name, c.got, c.exp)
+		mismatch = fmt.Errorf("sanity check: %s differs between Tx.Raw and sql.Conn.Raw", c.name)
+	}
+	log.Println()
+	return scenarioResult{loaded: 2 * len(sampleData), persisted: viaTx.rows, bytes: viaConn.bytes + viaTx.bytes, err: mismatch}
+}
+
+// runRawPath copies data through driverConn and records what it found there.
+func runRawPath(ctx context.Context, driverConn any, data [][]any) (rawPathRun, error) {
+	run := rawPathRun{driverConnType: fmt.Sprintf("%T", driverConn)}
+	run.adapter, _, _ = txraw.LookupAdapter(driverConn)
+
+	var err error
+	run.bytes, err = performCopyFrom(ctx, driverConn, data, "sanity check")
+	return run, err
+}
+
+// checksumItems returns the number of rows in the items table and a
+// checksum of their name and data in insertion order, hashed with alg as
+// they are read.
+func checksumItems(ctx context.Context, db *sql.DB, alg rowhash.Algorithm) (int, string, error) {
+	hasher, err := rowhash.NewRowHasher(alg)
+	if err != nil {
+		return 0, "", err
+	}
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT name, data FROM %s ORDER BY id", tableName))
+	if err != nil {
+		return 0, "", fmt.Errorf("QueryContext failed: %w", err)
+	}
+	defer rows.Close()
+
+	count := 0
+	var name, data sql.RawBytes
+	for rows.Next() {
+		if err := rows.Scan(&name, &data); err != nil {
+			return 0, "", fmt.Errorf("Scan failed: %w", err)
+		}
+		hasher.WriteField(name)
+		hasher.WriteField(data)
+		count++
+	}
+	return count, hasher.Sum(), rows.Err()
+}
@@ -0,0 +1,79 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// SliceSource is an in-memory Source over a fixed list of batches. Nacked
+// batches are queued for redelivery. It is useful for demos and as a
+// reference implementation of the Source contract.
+type SliceSource struct {
+	mu       sync.Mutex
+	pending  []Batch
+	inFlight map[string]Batch
+	acked    []string
+}
+
+// NewSliceSource returns a SliceSource delivering batches in order.
+func NewSliceSource(batches ...Batch) *SliceSource {
+	return &SliceSource{
+		pending:  batches,
+		inFlight: make(map[string]Batch),
+	}
+}
+
+// Next implements Source.
+func (s *SliceSource) Next(ctx context.Context) (Batch, error) {
+	if err := ctx.Err(); err != nil {
+		return Batch{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.pending) == 0 {
+		return Batch{}, io.EOF
+	}
+	batch := s.pending[0]
+	s.pending = s.pending[1:]
+	s.inFlight[batch.ID] = batch
+	return batch, nil
+}
+
+// Ack implements Source.
+func (s *SliceSource) Ack(_ context.Context, batchID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.inFlight[batchID]; !ok {
+		return fmt.Errorf("source: batch %s is not in flight", batchID)
+	}
+	delete(s.inFlight, batchID)
+	s.acked = append(s.acked, batchID)
+	return nil
+}
+
+// Nack implements Source. The batch is put back at the head of the queue.
+func (s *SliceSource) Nack(_ context.Context, batchID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	batch, ok := s.inFlight[batchID]
+	if !ok {
+		return fmt.Errorf("source: batch %s is not in flight", batchID)
+	}
+	delete(s.inFlight, batchID)
+	s.pending = append([]Batch{batch}, s.pending...)
+	return nil
+}
+
+// Acked returns the IDs of acknowledged batches in acknowledgement order.
+func (s *SliceSource) Acked() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.acked...)
+}
@@ -0,0 +1,93 @@
+// Package source defines streaming batch sources whose batches are
+// acknowledged only after the transaction that loaded them has committed.
+//
+// Kafka topics, spooled files and message queues all need the same contract
+// for at-least-once delivery: hand out a batch, wait until it is durably
+// stored, then acknowledge it (or negatively acknowledge it so it is
+// redelivered). Consume implements that loop on top of txraw transactions.
+package source
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/eqld/example-tx-raw/runctx"
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// Batch is a unit of delivery from a Source.
+type Batch struct {
+	// ID identifies the batch for Ack and Nack.
+	ID string
+	// Rows holds the batch rows in CopyFrom order.
+	Rows [][]any
+}
+
+// Source is a stream of batches with explicit acknowledgement.
+//
+// Next returns io.EOF when the source is exhausted. Ack is called once the
+// batch has been committed; Nack is called when loading or committing it
+// failed and the batch should be redelivered.
+type Source interface {
+	Next(ctx context.Context) (Batch, error)
+	Ack(ctx context.Context, batchID string) error
+	Nack(ctx context.Context, batchID string) error
+}
+
+// LoadFunc loads a single batch inside tx.
+type LoadFunc func(ctx context.Context, tx *txraw.Tx, batch Batch) error
+
+// Stats summarizes a Consume run.
+type Stats struct {
+	Batches int
+	Rows    int
+}
+
+// Consume reads batches from src until it is exhausted, loading each one with
+// load in its own transaction. A batch is acknowledged only after its
+// transaction has committed, which gives at-least-once semantics: a crash
+// between commit and Ack redelivers an already stored batch, never loses one.
+//
+// Each batch is loaded under ctx labelled (see runctx) with the batch ID as
+// trace, unless ctx already carries a trace ID.
+//
+// If loading or committing a batch fails, the transaction is rolled back, the
+// batch is negatively acknowledged and Consume returns the error.
+func Consume(ctx context.Context, db *sql.DB, src Source, load LoadFunc) (Stats, error) {
+	var stats Stats
+	for {
+		batch, err := src.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			return stats, nil
+		}
+		if err != nil {
+			return stats, fmt.Errorf("source.Next failed: %w", err)
+		}
+
+		if err := loadBatch(ctx, db, batch, load); err != nil {
+			if nackErr := src.Nack(ctx, batch.ID); nackErr != nil {
+				return stats, fmt.Errorf("batch %s: %w (Nack also failed: %w)", batch.ID, err, nackErr)
+			}
+			return stats, fmt.Errorf("batch %s: %w", batch.ID, err)
+		}
+
+		if err := src.Ack(ctx, batch.ID); err != nil {
+			return stats, fmt.Errorf("batch %s committed but Ack failed: %w", batch.ID, err)
+		}
+		stats.Batches++
+		stats.Rows += len(batch.Rows)
+	}
+}
+
+// loadBatch runs load for batch in a new transaction and commits it.
+func loadBatch(ctx context.Context, db *sql.DB, batch Batch, load LoadFunc) error {
+	if runctx.FromContext(ctx).Trace == "" {
+		ctx = runctx.WithLabels(ctx, runctx.Labels{Trace: batch.ID})
+	}
+	return txraw.WithTx(ctx, db, func(tx *txraw.Tx) error {
+		return load(ctx, tx, batch)
+	})
+}
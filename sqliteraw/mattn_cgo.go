@@ -0,0 +1,39 @@
+//go:build cgo
+
+package sqliteraw
+
+import (
+	"fmt"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// mattnBackup copies the main database of src to a new connection on
+// destPath and runs the backup to completion.
+func mattnBackup(src *sqlite3.SQLiteConn, destPath string) error {
+	driverConn, err := (&sqlite3.SQLiteDriver{}).Open(destPath)
+	if err != nil {
+		return fmt.Errorf("opening backup destination %s failed: %w", destPath, err)
+	}
+	dest := driverConn.(*sqlite3.SQLiteConn)
+	defer dest.Close()
+
+	backup, err := dest.Backup("main", src, "main")
+	if err != nil {
+		return fmt.Errorf("starting backup to %s failed: %w", destPath, err)
+	}
+	// Step(-1) copies every page in one call, so anything short of done means
+	// the source was busy or locked.
+	done, err := backup.Step(-1)
+	if err == nil && !done {
+		err = ErrBackupBusy
+	}
+	if err != nil {
+		backup.Finish()
+		return fmt.Errorf("backup to %s failed: %w", destPath, err)
+	}
+	if err := backup.Finish(); err != nil {
+		return fmt.Errorf("finishing backup to %s failed: %w", destPath, err)
+	}
+	return nil
+}
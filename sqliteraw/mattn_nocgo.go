@@ -0,0 +1,16 @@
+//go:build !cgo
+
+package sqliteraw
+
+import (
+	"fmt"
+
+	"github.com/mattn/go-sqlite3"
+
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// mattnBackup is unavailable without cgo; go-sqlite3 is only a stub then.
+func mattnBackup(*sqlite3.SQLiteConn, string) error {
+	return fmt.Errorf("%w: go-sqlite3 backup requires cgo", txraw.ErrUnsupportedDriver)
+}
@@ -0,0 +1,176 @@
+// Package sqliteraw exposes SQLite connection APIs that database/sql cannot
+// reach, from inside a transaction: online backup, update hooks and custom
+// SQL functions. It supports github.com/mattn/go-sqlite3 (cgo) and
+// modernc.org/sqlite (pure Go), which offer different subsets:
+//
+//	                     mattn   modernc
+//	Backup                 ✓        ✓
+//	OnUpdate               ✓        -
+//	RegisterFunc           ✓        -   (modernc registers functions globally)
+//
+// Unsupported combinations fail with an error matching
+// txraw.ErrUnsupportedDriver.
+//
+// Hooks and functions are registered on the pooled connection, not on the
+// transaction, so they outlive it; OnUpdate returns a function that removes
+// the hook again.
+package sqliteraw
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+
+	"github.com/mattn/go-sqlite3"
+	moderncsqlite "modernc.org/sqlite"
+
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// Op is the kind of change reported to an update hook. The values are
+// SQLite's SQLITE_INSERT, SQLITE_DELETE and SQLITE_UPDATE codes.
+type Op int
+
+const (
+	OpDelete Op = 9
+	OpInsert Op = 18
+	OpUpdate Op = 23
+)
+
+func (op Op) String() string {
+	switch op {
+	case OpInsert:
+		return "INSERT"
+	case OpDelete:
+		return "DELETE"
+	case OpUpdate:
+		return "UPDATE"
+	default:
+		return fmt.Sprintf("Op(%d)", int(op))
+	}
+}
+
+// SQLite result codes for a busy or locked source database.
+const (
+	sqliteBusy   = 5
+	sqliteLocked = 6
+)
+
+// ErrBackupBusy is returned by Backup when SQLite refuses to read the source
+// database, most commonly because the transaction has already written to it:
+// the backup API cannot copy from a connection in a write transaction.
+var ErrBackupBusy = errors.New("sqliteraw: source database busy")
+
+// UpdateFunc is called for every row changed on the connection, with the
+// database ("main", "temp" or an attached name), table and rowid.
+type UpdateFunc func(op Op, database, table string, rowID int64)
+
+// moderncBackuper is implemented by modernc.org/sqlite's unexported conn.
+type moderncBackuper interface {
+	NewBackup(dstURI string) (*moderncsqlite.Backup, error)
+}
+
+// adapter registers both SQLite drivers with txraw's adapter registry. Their
+// driver connections already are the native connections.
+type adapter struct{}
+
+func (adapter) Match(conn driver.Conn) bool {
+	switch conn.(type) {
+	case *sqlite3.SQLiteConn, moderncBackuper:
+		return true
+	}
+	return false
+}
+
+func (adapter) Unwrap(conn driver.Conn) any { return conn }
+
+func init() {
+	txraw.RegisterAdapter("sqlite", adapter{})
+}
+
+// Backup copies the transaction's main database to a new database file at
+// destPath using SQLite's online backup API on the transaction's own
+// connection, so the copy is the snapshot the transaction reads.
+//
+// SQLite cannot back up from a connection in a write transaction, so call
+// Backup before the transaction's first write (or in a read-only one);
+// otherwise it fails with ErrBackupBusy.
+func Backup(ctx context.Context, tx txraw.RawTx, destPath string) error {
+	return tx.RawContext(ctx, func(driverConn any) error {
+		switch conn := driverConn.(type) {
+		case *sqlite3.SQLiteConn:
+			return mattnBackup(conn, destPath)
+		case moderncBackuper:
+			return moderncBackup(conn, destPath)
+		default:
+			return unsupported(driverConn)
+		}
+	})
+}
+
+// OnUpdate installs fn as the update hook of the transaction's connection and
+// returns a function that removes it. Call the returned function before the
+// transaction ends; the hook otherwise stays on the pooled connection. Only
+// one update hook can be installed per connection.
+func OnUpdate(ctx context.Context, tx txraw.RawTx, fn UpdateFunc) (remove func() error, err error) {
+	err = tx.RawContext(ctx, func(driverConn any) error {
+		conn, ok := driverConn.(*sqlite3.SQLiteConn)
+		if !ok {
+			return unsupported(driverConn)
+		}
+		conn.RegisterUpdateHook(func(op int, database, table string, rowID int64) {
+			fn(Op(op), database, table, rowID)
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		return tx.Raw(func(driverConn any) error {
+			driverConn.(*sqlite3.SQLiteConn).RegisterUpdateHook(nil)
+			return nil
+		})
+	}, nil
+}
+
+// RegisterFunc makes impl callable from SQL as name on the transaction's
+// connection, as (*sqlite3.SQLiteConn).RegisterFunc does. Set pure when impl
+// is deterministic so SQLite may use it in indexes and constant folding.
+func RegisterFunc(ctx context.Context, tx txraw.RawTx, name string, impl any, pure bool) error {
+	return tx.RawContext(ctx, func(driverConn any) error {
+		conn, ok := driverConn.(*sqlite3.SQLiteConn)
+		if !ok {
+			return unsupported(driverConn)
+		}
+		if err := conn.RegisterFunc(name, impl, pure); err != nil {
+			return fmt.Errorf("registering SQL function %s failed: %w", name, err)
+		}
+		return nil
+	})
+}
+
+// moderncBackup runs a modernc.org/sqlite backup to completion.
+func moderncBackup(conn moderncBackuper, destPath string) error {
+	backup, err := conn.NewBackup(destPath)
+	if err != nil {
+		return fmt.Errorf("starting backup to %s failed: %w", destPath, err)
+	}
+	if _, err := backup.Step(-1); err != nil {
+		var coded interface{ Code() int }
+		if errors.As(err, &coded) && (coded.Code() == sqliteBusy || coded.Code() == sqliteLocked) {
+			err = fmt.Errorf("%w: %w", ErrBackupBusy, err)
+		}
+		backup.Finish()
+		return fmt.Errorf("backup to %s failed: %w", destPath, err)
+	}
+	if err := backup.Finish(); err != nil {
+		return fmt.Errorf("finishing backup to %s failed: %w", destPath, err)
+	}
+	return nil
+}
+
+func unsupported(driverConn any) error {
+	return fmt.Errorf("%w: driverConn is not a supported SQLite connection, got %T", txraw.ErrUnsupportedDriver, driverConn)
+}
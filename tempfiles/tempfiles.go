@@ -0,0 +1,157 @@
+// Package tempfiles manages intermediate files (spill buffers, dead letters)
+// whose lifetime is tied to a single transaction or job.
+//
+// Every Manager owns a private directory. Cleanup removes it, so deferring
+// Cleanup right after beginning the transaction guarantees the files are gone
+// on commit, rollback or panic:
+//
+//	files, err := tempfiles.New("")
+//	if err != nil {
+//		return err
+//	}
+//	defer files.Cleanup()
+//
+// Directories left behind by a crashed process are removed by Sweep, which
+// is meant to be called once on startup.
+package tempfiles
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dirPrefix marks directories created by this package so that Sweep never
+// touches anything else in the shared temp root.
+const dirPrefix = "txraw-"
+
+// ErrClosed is returned by Create after Cleanup has been called.
+var ErrClosed = errors.New("tempfiles: manager already cleaned up")
+
+// Manager creates temporary files inside a private directory and removes
+// them all at once.
+type Manager struct {
+	mu     sync.Mutex
+	dir    string
+	closed bool
+}
+
+// New creates a Manager with a fresh directory under root. An empty root
+// means os.TempDir().
+func New(root string) (*Manager, error) {
+	if root == "" {
+		root = os.TempDir()
+	}
+	dir, err := os.MkdirTemp(root, fmt.Sprintf("%s%d-", dirPrefix, os.Getpid()))
+	if err != nil {
+		return nil, fmt.Errorf("tempfiles: creating job directory failed: %w", err)
+	}
+	return &Manager{dir: dir}, nil
+}
+
+// Dir returns the directory owned by m.
+func (m *Manager) Dir() string {
+	return m.dir
+}
+
+// Create creates a new temporary file in m's directory, following the naming
+// rules of os.CreateTemp. The caller is responsible for closing the file; it
+// is removed by Cleanup either way.
+func (m *Manager) Create(pattern string) (*os.File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	f, err := os.CreateTemp(m.dir, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("tempfiles: creating %q failed: %w", pattern, err)
+	}
+
+	// Keep the directory fresh so Sweep in another process does not mistake
+	// a long-running job for a crashed one.
+	now := time.Now()
+	_ = os.Chtimes(m.dir, now, now)
+
+	return f, nil
+}
+
+// Usage returns the total size in bytes of the files in m's directory.
+func (m *Manager) Usage() (int64, error) {
+	var total int64
+	err := filepath.WalkDir(m.dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			total += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("tempfiles: measuring %s failed: %w", m.dir, err)
+	}
+	return total, nil
+}
+
+// Cleanup removes m's directory and every file in it. It is safe to call
+// more than once.
+func (m *Manager) Cleanup() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+
+	if err := os.RemoveAll(m.dir); err != nil {
+		return fmt.Errorf("tempfiles: removing %s failed: %w", m.dir, err)
+	}
+	return nil
+}
+
+// Sweep removes job directories under root that have not been modified for
+// longer than maxAge. They are left behind when a process crashes before
+// Cleanup runs. An empty root means os.TempDir(). Sweep returns the removed
+// directories.
+func Sweep(root string, maxAge time.Duration) ([]string, error) {
+	if root == "" {
+		root = os.TempDir()
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("tempfiles: reading %s failed: %w", root, err)
+	}
+
+	var removed []string
+	cutoff := time.Now().Add(-maxAge)
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), dirPrefix) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+
+		dir := filepath.Join(root, entry.Name())
+		if err := os.RemoveAll(dir); err != nil {
+			return removed, fmt.Errorf("tempfiles: removing stale %s failed: %w", dir, err)
+		}
+		removed = append(removed, dir)
+	}
+	return removed, nil
+}
@@ -0,0 +1,148 @@
+// Package throttle slows down bulk loads to protect the rest of the cluster.
+package throttle
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/eqld/example-tx-raw/clock"
+)
+
+// DefaultPollInterval is used when LagGovernor.PollInterval is not set.
+const DefaultPollInterval = time.Second
+
+// LagGovernor paces bulk loads based on replica lag reported by
+// pg_stat_replication on the primary, so large ingests do not leave
+// downstream replicas far behind.
+//
+// Call Wait between batches (or wrap a CopyFromSource with GovernedSource):
+// above SlowAbove every call sleeps SlowDelay, and above PauseAbove calls
+// block until lag falls back to SlowAbove or below.
+//
+// Reading replay_lag of other sessions requires the pg_monitor role (or
+// superuser); without it the lag reads as zero and the governor never
+// throttles.
+type LagGovernor struct {
+	// DB is a pool on the primary. Polling uses it outside of any load
+	// transaction.
+	DB *sql.DB
+
+	// SlowAbove is the lag above which each Wait sleeps SlowDelay.
+	SlowAbove time.Duration
+	// SlowDelay is the pause added per Wait while lag exceeds SlowAbove.
+	SlowDelay time.Duration
+	// PauseAbove is the lag above which Wait blocks until lag recovers.
+	// Zero disables pausing.
+	PauseAbove time.Duration
+
+	// PollInterval bounds how often pg_stat_replication is queried.
+	PollInterval time.Duration
+
+	// Clock times polls and delays. Nil means clock.Real.
+	Clock clock.Clock
+
+	mu       sync.Mutex
+	lag      time.Duration
+	polledAt time.Time
+}
+
+// Lag returns the current maximum replay lag across replicas, querying the
+// server at most once per PollInterval.
+func (g *LagGovernor) Lag(ctx context.Context) (time.Duration, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.polledAt.IsZero() && clock.Since(clock.Or(g.Clock), g.polledAt) < g.pollInterval() {
+		return g.lag, nil
+	}
+
+	var seconds float64
+	err := g.DB.QueryRowContext(ctx,
+		"SELECT COALESCE(EXTRACT(EPOCH FROM max(replay_lag)), 0)::float8 FROM pg_stat_replication",
+	).Scan(&seconds)
+	if err != nil {
+		return 0, fmt.Errorf("querying pg_stat_replication failed: %w", err)
+	}
+
+	g.lag = time.Duration(seconds * float64(time.Second))
+	g.polledAt = clock.Or(g.Clock).Now()
+	return g.lag, nil
+}
+
+// Wait applies the governor's policy once: it returns immediately while lag
+// is low, sleeps SlowDelay while lag exceeds SlowAbove, and blocks while lag
+// exceeds PauseAbove. It returns early with ctx.Err() if ctx is done.
+func (g *LagGovernor) Wait(ctx context.Context) error {
+	lag, err := g.Lag(ctx)
+	if err != nil {
+		return err
+	}
+
+	if g.PauseAbove > 0 && lag > g.PauseAbove {
+		for lag > g.SlowAbove {
+			if err := clock.Sleep(ctx, clock.Or(g.Clock), g.pollInterval()); err != nil {
+				return err
+			}
+			if lag, err = g.Lag(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if g.SlowAbove > 0 && lag > g.SlowAbove {
+		return clock.Sleep(ctx, clock.Or(g.Clock), g.SlowDelay)
+	}
+	return nil
+}
+
+func (g *LagGovernor) pollInterval() time.Duration {
+	if g.PollInterval > 0 {
+		return g.PollInterval
+	}
+	return DefaultPollInterval
+}
+
+// GovernedSource wraps src so that g.Wait is called every `every` rows while
+// pgx.CopyFrom consumes it. A Wait error ends the copy with that error.
+func GovernedSource(ctx context.Context, src pgx.CopyFromSource, g *LagGovernor, every int) pgx.CopyFromSource {
+	if every <= 0 {
+		every = 1
+	}
+	return &governedSource{ctx: ctx, src: src, g: g, every: every}
+}
+
+type governedSource struct {
+	ctx   context.Context
+	src   pgx.CopyFromSource
+	g     *LagGovernor
+	every int
+	n     int
+	err   error
+}
+
+func (s *governedSource) Next() bool {
+	if s.n > 0 && s.n%s.every == 0 {
+		if s.err = s.g.Wait(s.ctx); s.err != nil {
+			return false
+		}
+	}
+	s.n++
+	return s.src.Next()
+}
+
+func (s *governedSource) Values() ([]any, error) {
+	return s.src.Values()
+}
+
+func (s *governedSource) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.src.Err()
+}
@@ -0,0 +1,133 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/eqld/example-tx-raw/clock"
+	"github.com/eqld/example-tx-raw/quota"
+)
+
+// TokenBucket caps the throughput of bulk loads, so a long-running load
+// inside a transaction does not starve OLTP traffic on a shared cluster.
+//
+// The bucket holds up to Burst tokens and refills at Rate tokens per second.
+// What a token stands for is up to the caller: a row, or a byte (see
+// RateLimitedSource). It starts full.
+type TokenBucket struct {
+	// Rate is the sustained number of tokens per second. Zero or less
+	// disables the limit.
+	Rate float64
+	// Burst is the bucket size, the most tokens that can be taken at once
+	// without waiting. Zero means Rate, i.e. one second's worth.
+	Burst float64
+
+	// Clock times the refills and waits. Nil means clock.Real.
+	Clock clock.Clock
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// WaitN takes n tokens, first waiting until the bucket has refilled enough
+// for them. A request larger than Burst waits for the deficit rather than
+// forever. It returns early with ctx.Err() if ctx is done; the tokens are
+// then given back.
+func (b *TokenBucket) WaitN(ctx context.Context, n float64) error {
+	if b.Rate <= 0 || n <= 0 {
+		return nil
+	}
+	c := clock.Or(b.Clock)
+
+	b.mu.Lock()
+	now := c.Now()
+	if b.last.IsZero() {
+		b.tokens = b.burst()
+	} else {
+		b.tokens = min(b.burst(), b.tokens+now.Sub(b.last).Seconds()*b.Rate)
+	}
+	b.last = now
+	// Taking the tokens up front, possibly into debt, queues concurrent
+	// callers behind each other.
+	b.tokens -= n
+	debt := -b.tokens
+	b.mu.Unlock()
+
+	if debt <= 0 {
+		return nil
+	}
+	err := clock.Sleep(ctx, c, time.Duration(debt/b.Rate*float64(time.Second)))
+	if err != nil {
+		b.mu.Lock()
+		b.tokens += n
+		b.mu.Unlock()
+	}
+	return err
+}
+
+func (b *TokenBucket) burst() float64 {
+	if b.Burst > 0 {
+		return b.Burst
+	}
+	return b.Rate
+}
+
+// CostFunc returns the number of tokens a row costs.
+type CostFunc func(values []any) float64
+
+// PerRow charges one token per row, making the bucket's Rate rows per
+// second.
+func PerRow(values []any) float64 {
+	return 1
+}
+
+// PerByte charges a row's estimated size in bytes (see quota.Size), making
+// the bucket's Rate bytes per second. The estimate ignores protocol overhead,
+// so the actual bytes on the wire run somewhat higher.
+func PerByte(values []any) float64 {
+	return float64(quota.Size(values))
+}
+
+// RateLimitedSource wraps src so that pgx.CopyFrom consumes it no faster
+// than b allows, charging each row cost tokens. A wait error ends the copy
+// with that error.
+func RateLimitedSource(ctx context.Context, src pgx.CopyFromSource, b *TokenBucket, cost CostFunc) pgx.CopyFromSource {
+	if cost == nil {
+		cost = PerRow
+	}
+	return &rateLimitedSource{ctx: ctx, src: src, b: b, cost: cost}
+}
+
+type rateLimitedSource struct {
+	ctx  context.Context
+	src  pgx.CopyFromSource
+	b    *TokenBucket
+	cost CostFunc
+	err  error
+}
+
+func (s *rateLimitedSource) Next() bool {
+	return s.err == nil && s.src.Next()
+}
+
+func (s *rateLimitedSource) Values() ([]any, error) {
+	values, err := s.src.Values()
+	if err != nil {
+		return nil, err
+	}
+	if s.err = s.b.WaitN(s.ctx, s.cost(values)); s.err != nil {
+		return nil, s.err
+	}
+	return values, nil
+}
+
+func (s *rateLimitedSource) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.src.Err()
+}
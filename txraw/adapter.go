@@ -0,0 +1,116 @@
+package txraw
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// RawAdapter teaches txraw how to get from a driver's database/sql
+// connection to the native connection that carries its bulk and
+// driver-specific APIs, e.g. from *stdlib.Conn to *pgx.Conn.
+type RawAdapter interface {
+	// Match reports whether the adapter handles conn.
+	Match(conn driver.Conn) bool
+	// Unwrap returns the native connection behind conn. It is only called
+	// for connections Match accepted.
+	Unwrap(conn driver.Conn) any
+}
+
+var adapters struct {
+	sync.RWMutex
+	list []namedAdapter
+}
+
+type namedAdapter struct {
+	name string
+	RawAdapter
+}
+
+// RegisterAdapter adds adapter to the registry under name, typically from
+// the init function of a driver integration package. Registering a name
+// again replaces the earlier adapter. Adapters are consulted in registration
+// order and the first match wins.
+func RegisterAdapter(name string, adapter RawAdapter) {
+	adapters.Lock()
+	defer adapters.Unlock()
+	for i, a := range adapters.list {
+		if a.name == name {
+			adapters.list[i].RawAdapter = adapter
+			return
+		}
+	}
+	adapters.list = append(adapters.list, namedAdapter{name: name, RawAdapter: adapter})
+}
+
+// LookupAdapter returns the name and adapter registered for driverConn, as
+// passed to a Raw callback.
+func LookupAdapter(driverConn any) (name string, adapter RawAdapter, ok bool) {
+	conn, ok := driverConn.(driver.Conn)
+	if !ok {
+		return "", nil, false
+	}
+	adapters.RLock()
+	defer adapters.RUnlock()
+	for _, a := range adapters.list {
+		if a.Match(conn) {
+			return a.name, a.RawAdapter, true
+		}
+	}
+	return "", nil, false
+}
+
+// UnwrapConn resolves the native connection behind driverConn through the
+// adapter registry. It returns an error matching ErrUnsupportedDriver if no
+// adapter matches.
+func UnwrapConn(driverConn any) (any, error) {
+	_, adapter, ok := LookupAdapter(driverConn)
+	if !ok {
+		return nil, fmt.Errorf("%w: no adapter registered for %T", ErrUnsupportedDriver, driverConn)
+	}
+	return adapter.Unwrap(driverConn.(driver.Conn)), nil
+}
+
+// UnwrapAs adapts a callback taking a native connection type T into one
+// suitable for Tx.Raw, resolving T through the adapter registry:
+//
+//	err := tx.Raw(txraw.UnwrapAs(func(conn *pgx.Conn) error { ... }))
+//
+// If no adapter matches, or the adapter's native connection is not a T, fn
+// is not called and an error matching ErrUnsupportedDriver is returned.
+func UnwrapAs[T any](fn func(T) error) func(driverConn any) error {
+	return func(driverConn any) error {
+		native, err := UnwrapConn(driverConn)
+		if err != nil {
+			return err
+		}
+		conn, ok := native.(T)
+		if !ok {
+			return &ConnTypeError{
+				Want: reflect.TypeFor[T](),
+				Got:  reflect.TypeOf(native),
+			}
+		}
+		return fn(conn)
+	}
+}
+
+// pgxAdapter unwraps pgx's database/sql connection to its *pgx.Conn. It is
+// built in because pgx is the driver this package is primarily used with.
+type pgxAdapter struct{}
+
+func (pgxAdapter) Match(conn driver.Conn) bool {
+	_, ok := conn.(*stdlib.Conn)
+	return ok
+}
+
+func (pgxAdapter) Unwrap(conn driver.Conn) any {
+	return conn.(*stdlib.Conn).Conn()
+}
+
+func init() {
+	RegisterAdapter("pgx", pgxAdapter{})
+}
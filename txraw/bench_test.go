@@ -0,0 +1,40 @@
+package txraw
+
+import "testing"
+
+// BenchmarkRaw compares the reflection path with the path Raw falls back to
+// in this build, which is the unsafe offset path with -tags txraw_unsafe:
+//
+//	go test -run '^$' -bench Raw ./txraw
+//	go test -run '^$' -bench Raw -tags txraw_unsafe ./txraw
+func BenchmarkRaw(b *testing.B) {
+	db, _ := openFake(b)
+	tx, _ := beginFake(b, db)
+	defer tx.Rollback()
+
+	enabled, reason := FastPath()
+	b.Logf("unsafe fast path enabled: %v (%s)", enabled, reason)
+
+	noop := func(any) error { return nil }
+	b.Run("reflect", func(b *testing.B) {
+		for b.Loop() {
+			if err := reflectRaw(tx.tx, noop); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("fallback", func(b *testing.B) {
+		for b.Loop() {
+			if err := fallbackRaw(tx.tx, noop); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Tx.Raw", func(b *testing.B) {
+		for b.Loop() {
+			if err := tx.Raw(noop); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
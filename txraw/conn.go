@@ -0,0 +1,267 @@
+package txraw
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// RawTx is the transaction API shared by Tx, ConnTx and NestedTx, so that
+// callers can work with any of them without knowing which one they were
+// given.
+type RawTx interface {
+	Raw(f func(driverConn any) error) error
+	RawContext(ctx context.Context, f func(driverConn any) error) error
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	Commit() error
+	Rollback() error
+	Err() error
+	Options() sql.TxOptions
+}
+
+var (
+	_ RawTx = (*Tx)(nil)
+	_ RawTx = (*ConnTx)(nil)
+	_ RawTx = (*NestedTx)(nil)
+)
+
+// ErrCommitRolledBack is returned by ConnTx.Commit when the server had
+// already aborted the transaction, so COMMIT rolled it back instead.
+var ErrCommitRolledBack = errors.New("txraw: transaction was aborted, commit rolled back")
+
+// ConnTx is a degraded but safe transaction that does not depend on sql.Tx
+// internals at all. It takes a dedicated sql.Conn and issues BEGIN, COMMIT and
+// ROLLBACK manually through the official sql.Conn.Raw, so Raw is just
+// sql.Conn.Raw. Use it where reflection is unavailable or has stopped
+// working (see Verify and BeginRawTx).
+//
+// Statements are sent verbatim, so ConnTx only works with drivers that accept
+// standard SQL transaction control statements, such as pgx.
+type ConnTx struct {
+	conn  *sql.Conn
+	opts  sql.TxOptions
+	guard guard
+
+	mu   sync.Mutex
+	done bool
+}
+
+// BeginConn starts a ConnTx on a connection reserved from db. The connection
+// is returned to the pool when the transaction is committed or rolled back.
+func BeginConn(ctx context.Context, db *sql.DB, opts *sql.TxOptions) (*ConnTx, error) {
+	begin, err := beginStatement(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("txraw: reserving connection failed: %w", err)
+	}
+
+	if err := execRaw(ctx, conn, begin); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	tx := &ConnTx{conn: conn}
+	if opts != nil {
+		tx.opts = *opts
+	}
+	return tx, nil
+}
+
+// BeginRawTx begins a Tx if the reflection path works on this Go release
+// (see Verify), and falls back to a ConnTx otherwise. Callers get the same
+// transactional semantics either way.
+func BeginRawTx(ctx context.Context, db *sql.DB, opts *sql.TxOptions) (RawTx, error) {
+	if HasOfficialRaw() || verified() == nil {
+		return Begin(ctx, db, opts)
+	}
+	return BeginConn(ctx, db, opts)
+}
+
+// verified caches the result of Verify.
+var verified = sync.OnceValue(Verify)
+
+// Conn returns the dedicated connection tx runs on.
+func (tx *ConnTx) Conn() *sql.Conn {
+	return tx.conn
+}
+
+// Options returns the options the transaction was started with.
+func (tx *ConnTx) Options() sql.TxOptions {
+	return tx.opts
+}
+
+// Err returns the *PanicError that poisoned tx, or nil.
+func (tx *ConnTx) Err() error {
+	return tx.guard.err()
+}
+
+// Raw runs f with the driver connection via sql.Conn.Raw. Panics in f are
+// recovered and poison tx, as with Tx.Raw.
+func (tx *ConnTx) Raw(f func(driverConn any) error) error {
+	if err := tx.checkDone(); err != nil {
+		return err
+	}
+	return tx.guard.call(func() error {
+		return tx.conn.Raw(f)
+	})
+}
+
+// RawContext is like Raw but cancels the in-flight operation when ctx is
+// done, as with Tx.RawContext.
+func (tx *ConnTx) RawContext(ctx context.Context, f func(driverConn any) error) error {
+	return rawContext(ctx, tx.Raw, f)
+}
+
+// ExecContext executes a query that doesn't return rows within the
+// transaction.
+func (tx *ConnTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if err := tx.checkUsable(); err != nil {
+		return nil, err
+	}
+	return tx.conn.ExecContext(ctx, query, args...)
+}
+
+// QueryContext executes a query that returns rows within the transaction.
+func (tx *ConnTx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if err := tx.checkUsable(); err != nil {
+		return nil, err
+	}
+	return tx.conn.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext executes a query that is expected to return at most one
+// row within the transaction. As with sql.Tx, on a finished transaction the
+// row's Scan returns sql.ErrTxDone, and on a poisoned one the poison error,
+// without running the query.
+func (tx *ConnTx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	if err := tx.checkUsable(); err != nil {
+		return errRow(err)
+	}
+	return tx.conn.QueryRowContext(ctx, query, args...)
+}
+
+// Commit commits the transaction and releases the connection. A poisoned
+// transaction is rolled back instead and Commit returns the poison error.
+func (tx *ConnTx) Commit() error {
+	if err := tx.Err(); err != nil {
+		if rollbackErr := tx.finish("ROLLBACK"); rollbackErr != nil && !errors.Is(rollbackErr, sql.ErrTxDone) {
+			return fmt.Errorf("%w (rollback also failed: %w)", err, rollbackErr)
+		}
+		return err
+	}
+	return tx.finish("COMMIT")
+}
+
+// Rollback aborts the transaction and releases the connection.
+func (tx *ConnTx) Rollback() error {
+	return tx.finish("ROLLBACK")
+}
+
+// finish sends COMMIT or ROLLBACK and returns the connection to the pool.
+func (tx *ConnTx) finish(stmt string) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return sql.ErrTxDone
+	}
+	tx.done = true
+
+	// Detect an aborted transaction before COMMIT: PostgreSQL answers COMMIT
+	// on it with ROLLBACK and no error.
+	aborted := false
+	if stmt == "COMMIT" {
+		_ = tx.conn.Raw(func(driverConn any) error {
+			if conn, ok := driverConn.(*stdlib.Conn); ok {
+				aborted = conn.Conn().PgConn().TxStatus() == 'E'
+			}
+			return nil
+		})
+		if aborted {
+			stmt = "ROLLBACK"
+		}
+	}
+
+	err := execRaw(context.Background(), tx.conn, stmt)
+	if err != nil {
+		// The connection is in an unknown state; make sure it is not reused.
+		_ = tx.conn.Raw(func(any) error { return driver.ErrBadConn })
+	}
+	if closeErr := tx.conn.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && aborted {
+		err = ErrCommitRolledBack
+	}
+	return err
+}
+
+func (tx *ConnTx) checkDone() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return sql.ErrTxDone
+	}
+	return nil
+}
+
+func (tx *ConnTx) checkUsable() error {
+	if err := tx.Err(); err != nil {
+		return err
+	}
+	return tx.checkDone()
+}
+
+// execRaw sends a transaction control statement through sql.Conn.Raw using
+// the driver's ExecerContext, bypassing database/sql's own bookkeeping.
+func execRaw(ctx context.Context, conn *sql.Conn, stmt string) error {
+	err := conn.Raw(func(driverConn any) error {
+		execer, ok := driverConn.(driver.ExecerContext)
+		if !ok {
+			return fmt.Errorf("%w: %T does not implement driver.ExecerContext", ErrUnsupportedDriver, driverConn)
+		}
+		_, err := execer.ExecContext(ctx, stmt, nil)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("txraw: %s failed: %w", stmt, err)
+	}
+	return nil
+}
+
+// beginStatement renders opts as a BEGIN statement.
+func beginStatement(opts *sql.TxOptions) (string, error) {
+	stmt := "BEGIN"
+	if opts == nil {
+		return stmt, nil
+	}
+
+	switch opts.Isolation {
+	case sql.LevelDefault:
+	case sql.LevelReadUncommitted:
+		stmt += " ISOLATION LEVEL READ UNCOMMITTED"
+	case sql.LevelReadCommitted:
+		stmt += " ISOLATION LEVEL READ COMMITTED"
+	case sql.LevelRepeatableRead:
+		stmt += " ISOLATION LEVEL REPEATABLE READ"
+	case sql.LevelSerializable:
+		stmt += " ISOLATION LEVEL SERIALIZABLE"
+	default:
+		return "", fmt.Errorf("txraw: isolation level %v is not supported by ConnTx", opts.Isolation)
+	}
+	if opts.ReadOnly {
+		stmt += " READ ONLY"
+	}
+	return stmt, nil
+}
@@ -0,0 +1,37 @@
+package txraw
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestConnTxQueryRowUnusable(t *testing.T) {
+	ctx := context.Background()
+	db, _ := openFake(t)
+
+	poisoned, err := BeginConn(ctx, db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = poisoned.Raw(func(any) error { panic("boom") })
+	var n int
+	if err := poisoned.QueryRowContext(ctx, "SELECT 1").Scan(&n); !errors.Is(err, ErrPoisoned) {
+		t.Errorf("QueryRow on a poisoned ConnTx = %v, want ErrPoisoned", err)
+	}
+	// sql.Conn.Raw closed the connection on the panic, so this ROLLBACK
+	// fails, but it releases the connection for the next transaction.
+	_ = poisoned.Rollback()
+
+	finished, err := BeginConn(ctx, db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := finished.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := finished.QueryRowContext(ctx, "SELECT 1").Scan(&n); !errors.Is(err, sql.ErrTxDone) {
+		t.Errorf("QueryRow on a committed ConnTx = %v, want sql.ErrTxDone", err)
+	}
+}
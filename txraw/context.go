@@ -0,0 +1,69 @@
+package txraw
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// cancelTimeout bounds how long RawContext waits for the server to accept a
+// cancel request.
+const cancelTimeout = 5 * time.Second
+
+// RawContext is like Raw but also watches ctx while f runs. When ctx is done
+// before f returns, the in-flight operation on the driver connection is
+// cancelled on the server side (for pgx via pgconn.CancelRequest), so long
+// CopyFrom calls inside a transaction can be aborted cleanly even if they
+// were not started with ctx.
+//
+// If ctx ends before or during f, RawContext returns an error wrapping
+// ctx.Err() and, when f failed as a result, f's error as well.
+//
+// As with Raw, the connection is locked while f runs, so f must not use tx.
+func (tx *Tx) RawContext(ctx context.Context, f func(driverConn any) error) error {
+	return rawContext(ctx, tx.Raw, f)
+}
+
+// rawContext implements RawContext on top of a Raw method.
+func rawContext(ctx context.Context, raw func(func(driverConn any) error) error, f func(driverConn any) error) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("txraw: raw operation not started: %w", err)
+	}
+
+	return raw(func(driverConn any) error {
+		stop := context.AfterFunc(ctx, func() {
+			if cancel := cancelFunc(driverConn); cancel != nil {
+				cancelCtx, cancelDone := context.WithTimeout(context.Background(), cancelTimeout)
+				defer cancelDone()
+				_ = cancel(cancelCtx)
+			}
+		})
+
+		defer stop()
+
+		err := f(driverConn)
+
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			if err != nil {
+				return fmt.Errorf("txraw: raw operation aborted: %w: %w", ctxErr, err)
+			}
+			return fmt.Errorf("txraw: raw operation aborted: %w", ctxErr)
+		}
+		return err
+	})
+}
+
+// cancelFunc returns a function that asks the server to cancel the operation
+// currently running on driverConn, or nil if the driver is not supported.
+func cancelFunc(driverConn any) func(context.Context) error {
+	switch conn := driverConn.(type) {
+	case *stdlib.Conn:
+		return conn.Conn().PgConn().CancelRequest
+	case interface{ CancelRequest(context.Context) error }:
+		return conn.CancelRequest
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,103 @@
+package txraw
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Errors returned by this package. They fall into two groups that call for
+// different fallback strategies:
+//
+//   - ErrLayoutChanged (with ErrTxFieldNotFound or ErrDriverConnInaccessible)
+//     means Go internals changed and the reflection path no longer works on
+//     this toolchain; callers may fall back to a path that does not need it.
+//   - ErrUnsupportedDriver means reflection worked but the transaction runs on
+//     a different driver than expected; callers may pick another adapter.
+var (
+	// ErrNilTx is returned when Raw is called on a nil transaction.
+	ErrNilTx = errors.New("txraw: nil transaction")
+
+	// ErrLayoutChanged is matched by every *LayoutError, i.e. whenever the
+	// unexported fields of sql.Tx or its driverConn no longer match what this
+	// package expects, typically after a Go release changed database/sql.
+	ErrLayoutChanged = errors.New("txraw: unexpected database/sql internal layout")
+
+	// ErrTxFieldNotFound means sql.Tx no longer has the expected dc field.
+	ErrTxFieldNotFound = errors.New("txraw: sql.Tx field not found")
+
+	// ErrDriverConnInaccessible means the driver connection could not be
+	// reached through sql.Tx's driverConn.
+	ErrDriverConnInaccessible = errors.New("txraw: driver connection inaccessible")
+
+	// ErrPoisoned is matched by *PanicError, i.e. by every operation on a Tx
+	// after one of its Raw callbacks panicked.
+	ErrPoisoned = errors.New("txraw: transaction poisoned by a panicking Raw callback")
+
+	// ErrUnsupportedDriver is matched by *ConnTypeError, i.e. whenever the
+	// transaction's driver connection is not of the type a caller requires.
+	ErrUnsupportedDriver = errors.New("txraw: unsupported driver")
+
+	// ErrDeadlock is matched by *DeadlockError, i.e. when an operation on a
+	// Serialized transaction would wait for it forever.
+	ErrDeadlock = errors.New("txraw: operation would deadlock on the shared transaction")
+
+	// ErrReadOnly is returned by CheckWritable and RawWrite, and the write
+	// helpers built on them, for a transaction started with
+	// sql.TxOptions.ReadOnly.
+	ErrReadOnly = errors.New("txraw: write refused in a read-only transaction")
+)
+
+// LayoutError reports an unexpected internal layout of database/sql.
+// It matches ErrLayoutChanged and its Err with errors.Is.
+type LayoutError struct {
+	// Struct is the database/sql type that was inspected, e.g. "sql.Tx".
+	Struct string
+	// Field is the unexported field that was missing or had the wrong kind.
+	Field string
+	// Err is ErrTxFieldNotFound or ErrDriverConnInaccessible.
+	Err error
+}
+
+func (e *LayoutError) Error() string {
+	return fmt.Sprintf("%v: cannot access %s.%s", e.Err, e.Struct, e.Field)
+}
+
+func (e *LayoutError) Unwrap() []error {
+	return []error{e.Err, ErrLayoutChanged}
+}
+
+// ConnTypeError is returned by RawAs when the driver connection of the
+// transaction is not of the requested type. It matches ErrUnsupportedDriver
+// with errors.Is.
+type ConnTypeError struct {
+	// Want is the type requested by the caller.
+	Want reflect.Type
+	// Got is the dynamic type of the driver connection.
+	Got reflect.Type
+}
+
+func (e *ConnTypeError) Error() string {
+	return fmt.Sprintf("txraw: driver connection is %v, not %v", e.Got, e.Want)
+}
+
+func (e *ConnTypeError) Is(target error) bool {
+	return target == ErrUnsupportedDriver
+}
+
+// PanicError is returned by Raw when its callback panics, and by every later
+// operation on the poisoned Tx. It matches ErrPoisoned with errors.Is.
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value any
+	// Stack is the goroutine stack at the time of the panic.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrPoisoned, e.Value)
+}
+
+func (e *PanicError) Unwrap() error {
+	return ErrPoisoned
+}
@@ -0,0 +1,54 @@
+package txraw
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+)
+
+// errRowDB is a database whose every query fails with the error passed as
+// its only argument. sql.Row has no exported constructor, so this is how
+// errRow makes one that carries an error of ours.
+var errRowDB = sync.OnceValue(func() *sql.DB {
+	return sql.OpenDB(errRowConnector{})
+})
+
+// errRow returns an *sql.Row whose Scan and Err return err, for
+// QueryRowContext on a transaction that is not usable.
+func errRow(err error) *sql.Row {
+	return errRowDB().QueryRowContext(context.Background(), "", errRowArg{err})
+}
+
+// errRowArg wraps the error so that errRowConn can tell it from a value.
+type errRowArg struct{ err error }
+
+type errRowConnector struct{}
+
+func (errRowConnector) Connect(context.Context) (driver.Conn, error) { return errRowConn{}, nil }
+func (c errRowConnector) Driver() driver.Driver                      { return errRowDriver{} }
+
+type errRowDriver struct{}
+
+func (errRowDriver) Open(string) (driver.Conn, error) { return errRowConn{}, nil }
+
+type errRowConn struct{}
+
+var errRowUnsupported = errors.New("txraw: error row connection used for a real query")
+
+func (errRowConn) Prepare(string) (driver.Stmt, error) { return nil, errRowUnsupported }
+func (errRowConn) Close() error                        { return nil }
+func (errRowConn) Begin() (driver.Tx, error)           { return nil, errRowUnsupported }
+
+// CheckNamedValue lets errRowArg through database/sql's argument conversion.
+func (errRowConn) CheckNamedValue(*driver.NamedValue) error { return nil }
+
+func (errRowConn) QueryContext(_ context.Context, _ string, args []driver.NamedValue) (driver.Rows, error) {
+	if len(args) == 1 {
+		if arg, ok := args[0].Value.(errRowArg); ok {
+			return nil, arg.err
+		}
+	}
+	return nil, errRowUnsupported
+}
@@ -0,0 +1,131 @@
+package txraw
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeConnector is a minimal in-process driver for tests, in the spirit of
+// verifyConnector. Its connections accept Exec, record the statements and
+// transaction outcomes they see, and detect overlapping use, which would
+// corrupt the wire protocol of a real driver.
+type fakeConnector struct {
+	mu    sync.Mutex
+	conns []*fakeConn
+}
+
+func (c *fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	conn := &fakeConn{}
+	c.conns = append(c.conns, conn)
+	return conn, nil
+}
+
+func (c *fakeConnector) Driver() driver.Driver { return fakeDriver{c} }
+
+type fakeDriver struct{ c *fakeConnector }
+
+func (d fakeDriver) Open(string) (driver.Conn, error) { return d.c.Connect(context.Background()) }
+
+type fakeConn struct {
+	// busy is set while the connection is in use by database/sql or by a
+	// Raw callback that calls enter.
+	busy atomic.Bool
+	// overlaps counts the times the connection was entered while busy.
+	overlaps atomic.Int64
+
+	mu         sync.Mutex
+	execs      []string
+	commits    int
+	rollbacks  int
+	beginCalls int
+}
+
+// enter marks c busy until the returned function is called, counting an
+// overlap if it already was.
+func (c *fakeConn) enter() (exit func()) {
+	if !c.busy.CompareAndSwap(false, true) {
+		c.overlaps.Add(1)
+		return func() {}
+	}
+	return func() { c.busy.Store(false) }
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fake driver: Prepare not supported")
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.beginCalls++
+	return fakeTx{c}, nil
+}
+
+// BeginTx accepts any options, so read-only transactions can be begun.
+func (c *fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return c.Begin()
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	defer c.enter()()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.execs = append(c.execs, query)
+	return driver.RowsAffected(1), nil
+}
+
+type fakeTx struct{ c *fakeConn }
+
+func (tx fakeTx) Commit() error {
+	tx.c.mu.Lock()
+	defer tx.c.mu.Unlock()
+	tx.c.commits++
+	return nil
+}
+
+func (tx fakeTx) Rollback() error {
+	tx.c.mu.Lock()
+	defer tx.c.mu.Unlock()
+	tx.c.rollbacks++
+	return nil
+}
+
+// openFake returns a database on a fresh fakeConnector limited to one
+// connection, closed when the test ends.
+func openFake(t testing.TB) (*sql.DB, *fakeConnector) {
+	t.Helper()
+	connector := &fakeConnector{}
+	db := sql.OpenDB(connector)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db, connector
+}
+
+// beginFake begins a wrapped transaction on db and returns it with the fake
+// connection it runs on.
+func beginFake(t testing.TB, db *sql.DB) (*Tx, *fakeConn) {
+	t.Helper()
+	sqlTx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	tx := Wrap(sqlTx)
+
+	var conn *fakeConn
+	if err := tx.Raw(func(driverConn any) error {
+		conn = driverConn.(*fakeConn)
+		return nil
+	}); err != nil {
+		t.Fatalf("Raw: %v", err)
+	}
+	return tx, conn
+}
@@ -0,0 +1,19 @@
+//go:build !txraw_unsafe
+
+package txraw
+
+import "database/sql"
+
+// fallbackRaw is the path Raw takes when there is no official
+// (*sql.Tx).Raw. Build with -tags txraw_unsafe to use the precomputed field
+// offsets of fallback_unsafe.go instead of per-call reflection.
+func fallbackRaw(tx *sql.Tx, f func(driverConn any) error) error {
+	return reflectRaw(tx, f)
+}
+
+// FastPath reports whether Raw uses precomputed unsafe field offsets instead
+// of per-call reflection, and if not, why. Without the txraw_unsafe build tag
+// the fast path is not compiled in.
+func FastPath() (enabled bool, reason string) {
+	return false, "built without the txraw_unsafe tag"
+}
@@ -0,0 +1,126 @@
+//go:build txraw_unsafe
+
+package txraw
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// txLayout holds the offsets of the unexported fields Raw needs, resolved
+// once at init so that each call is a handful of pointer reads instead of
+// FieldByName lookups.
+type txLayout struct {
+	closemu uintptr // sql.Tx.closemu
+	done    uintptr // sql.Tx.done
+	dc      uintptr // sql.Tx.dc
+	dcMutex uintptr // driverConn.Mutex
+	dcCI    uintptr // driverConn.ci
+
+	// closemuLocker turns a pointer to closemu into an rLocker. closemu is a
+	// sync.RWMutex on older releases and an unexported type on newer ones,
+	// which can only be reached through reflect.NewAt.
+	closemuLocker func(p unsafe.Pointer) rLocker
+}
+
+var (
+	layout       *txLayout
+	layoutReason string
+)
+
+func init() {
+	layout, layoutReason = resolveLayout()
+}
+
+// resolveLayout checks the field names and types of sql.Tx and driverConn
+// against what this file expects, returning nil and a reason on mismatch.
+// Checking types, not just names, is the sanity check that a new Go release
+// has not silently changed the layout underneath the unsafe reads.
+func resolveLayout() (*txLayout, string) {
+	mismatch := func(what string) (*txLayout, string) {
+		return nil, fmt.Sprintf("unexpected %s on %s; using reflection", what, runtime.Version())
+	}
+
+	txType := reflect.TypeFor[sql.Tx]()
+
+	closemu, ok := txType.FieldByName("closemu")
+	if !ok || !reflect.PointerTo(closemu.Type).Implements(reflect.TypeFor[rLocker]()) {
+		return mismatch("sql.Tx.closemu")
+	}
+	done, ok := txType.FieldByName("done")
+	if !ok || done.Type != reflect.TypeFor[atomic.Bool]() {
+		return mismatch("sql.Tx.done")
+	}
+	dc, ok := txType.FieldByName("dc")
+	if !ok || dc.Type.Kind() != reflect.Pointer || dc.Type.Elem().Kind() != reflect.Struct {
+		return mismatch("sql.Tx.dc")
+	}
+	dcMutex, ok := dc.Type.Elem().FieldByName("Mutex")
+	if !ok || !dcMutex.Anonymous || dcMutex.Type != reflect.TypeFor[sync.Mutex]() {
+		return mismatch("driverConn.Mutex")
+	}
+	ci, ok := dc.Type.Elem().FieldByName("ci")
+	if !ok || ci.Type != reflect.TypeFor[driver.Conn]() {
+		return mismatch("driverConn.ci")
+	}
+
+	l := &txLayout{
+		closemu: closemu.Offset,
+		done:    done.Offset,
+		dc:      dc.Offset,
+		dcMutex: dcMutex.Offset,
+		dcCI:    ci.Offset,
+	}
+	if closemu.Type == reflect.TypeFor[sync.RWMutex]() {
+		l.closemuLocker = func(p unsafe.Pointer) rLocker { return (*sync.RWMutex)(p) }
+	} else {
+		closemuType := closemu.Type
+		l.closemuLocker = func(p unsafe.Pointer) rLocker {
+			return reflect.NewAt(closemuType, p).Interface().(rLocker)
+		}
+	}
+	return l, "field offsets verified on " + runtime.Version()
+}
+
+// fallbackRaw is the path Raw takes when there is no official
+// (*sql.Tx).Raw. With the txraw_unsafe build tag it reads the fields at the
+// offsets resolved at init, falling back to reflectRaw if they could not be
+// verified. It takes the same locks as reflectRaw.
+func fallbackRaw(tx *sql.Tx, f func(driverConn any) error) error {
+	if layout == nil {
+		return reflectRaw(tx, f)
+	}
+
+	base := unsafe.Pointer(tx)
+
+	closemu := layout.closemuLocker(unsafe.Add(base, layout.closemu))
+	closemu.RLock()
+	defer closemu.RUnlock()
+
+	if (*atomic.Bool)(unsafe.Add(base, layout.done)).Load() {
+		return sql.ErrTxDone
+	}
+
+	dc := *(*unsafe.Pointer)(unsafe.Add(base, layout.dc))
+	if dc == nil {
+		return sql.ErrTxDone
+	}
+
+	mu := (*sync.Mutex)(unsafe.Add(dc, layout.dcMutex))
+	mu.Lock()
+	defer mu.Unlock()
+
+	return f(*(*driver.Conn)(unsafe.Add(dc, layout.dcCI)))
+}
+
+// FastPath reports whether Raw uses precomputed unsafe field offsets instead
+// of per-call reflection, and if not, why.
+func FastPath() (enabled bool, reason string) {
+	return layout != nil, layoutReason
+}
@@ -0,0 +1,37 @@
+package txraw
+
+import (
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// guard implements panic recovery and poisoning for Tx and ConnTx.
+type guard struct {
+	poison atomic.Pointer[PanicError]
+}
+
+// err returns the *PanicError that poisoned g, or nil.
+func (g *guard) err() error {
+	if perr := g.poison.Load(); perr != nil {
+		return perr
+	}
+	return nil
+}
+
+// call runs f unless g is poisoned. If f panics, the panic is recovered, g is
+// poisoned and the resulting *PanicError is returned.
+func (g *guard) call(f func() error) (err error) {
+	if err := g.err(); err != nil {
+		return err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			perr := &PanicError{Value: r, Stack: debug.Stack()}
+			g.poison.CompareAndSwap(nil, perr)
+			err = g.err()
+		}
+	}()
+
+	return f()
+}
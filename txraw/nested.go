@@ -0,0 +1,190 @@
+package txraw
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// nestedSeq makes savepoint names of nested transactions unique.
+var nestedSeq atomic.Int64
+
+// NestedTx is a transaction nested in another, emulated with a savepoint:
+// Commit releases the savepoint, keeping the work in the enclosing
+// transaction, and Rollback rolls back to it, undoing only the nested work.
+// Nothing is durable until the outermost transaction commits.
+//
+// NestedTx implements RawTx, so library code that takes a RawTx and commits
+// or rolls back "its" transaction composes inside an outer one, e.g. an ORM
+// write mixed with bulk loads:
+//
+//	nested, err := tx.BeginNested(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	if err := library.Save(ctx, nested); err != nil { // commits or rolls back nested
+//		return err
+//	}
+//	return tx.Commit()
+//
+// Once a NestedTx or any transaction it is nested in has finished, its
+// methods return sql.ErrTxDone; QueryRowContext cannot, so check Err first.
+// A NestedTx must not be used concurrently with the transactions it is
+// nested in.
+type NestedTx struct {
+	root      RawTx
+	parent    *NestedTx
+	savepoint string
+
+	mu   sync.Mutex
+	done bool
+}
+
+// BeginNested begins a transaction nested in tx.
+func (tx *Tx) BeginNested(ctx context.Context) (*NestedTx, error) {
+	return beginNested(ctx, tx, nil)
+}
+
+// BeginNested begins a transaction nested in tx.
+func (tx *ConnTx) BeginNested(ctx context.Context) (*NestedTx, error) {
+	return beginNested(ctx, tx, nil)
+}
+
+// BeginNested begins a transaction nested in n, one level deeper.
+func (n *NestedTx) BeginNested(ctx context.Context) (*NestedTx, error) {
+	if err := n.checkDone(); err != nil {
+		return nil, err
+	}
+	return beginNested(ctx, n.root, n)
+}
+
+func beginNested(ctx context.Context, root RawTx, parent *NestedTx) (*NestedTx, error) {
+	n := &NestedTx{
+		root:      root,
+		parent:    parent,
+		savepoint: fmt.Sprintf("txraw_nested_%d", nestedSeq.Add(1)),
+	}
+	if err := savepointCommand(ctx, root.RawContext, "SAVEPOINT", n.savepoint); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// checkDone returns sql.ErrTxDone if n or a transaction it is nested in has
+// finished.
+func (n *NestedTx) checkDone() error {
+	for t := n; t != nil; t = t.parent {
+		t.mu.Lock()
+		done := t.done
+		t.mu.Unlock()
+		if done {
+			return sql.ErrTxDone
+		}
+	}
+	return nil
+}
+
+// Commit releases the savepoint, keeping the nested work in the enclosing
+// transaction. If that fails, e.g. because a statement in the nested
+// transaction failed, the nested work is rolled back instead so the
+// enclosing transaction stays usable, and the release error is returned.
+func (n *NestedTx) Commit() error {
+	return n.finish(func() error {
+		ctx := context.Background()
+		err := savepointCommand(ctx, n.root.RawContext, "RELEASE SAVEPOINT", n.savepoint)
+		if err == nil {
+			return nil
+		}
+		if rollbackErr := n.rollback(ctx); rollbackErr != nil {
+			return fmt.Errorf("%w (rollback also failed: %w)", err, rollbackErr)
+		}
+		return err
+	})
+}
+
+// Rollback undoes the nested work, leaving the enclosing transaction as it
+// was when n began.
+func (n *NestedTx) Rollback() error {
+	return n.finish(func() error {
+		return n.rollback(context.Background())
+	})
+}
+
+func (n *NestedTx) rollback(ctx context.Context) error {
+	if err := savepointCommand(ctx, n.root.RawContext, "ROLLBACK TO SAVEPOINT", n.savepoint); err != nil {
+		return err
+	}
+	return savepointCommand(ctx, n.root.RawContext, "RELEASE SAVEPOINT", n.savepoint)
+}
+
+// finish marks n done and calls end. The check and the mark happen under
+// one lock, so of concurrent Commit and Rollback calls exactly one ends the
+// savepoint and the others return sql.ErrTxDone.
+func (n *NestedTx) finish(end func() error) error {
+	if n.parent != nil {
+		if err := n.parent.checkDone(); err != nil {
+			return err
+		}
+	}
+	n.mu.Lock()
+	if n.done {
+		n.mu.Unlock()
+		return sql.ErrTxDone
+	}
+	n.done = true
+	n.mu.Unlock()
+	return end()
+}
+
+// Raw calls f with the driver connection of the outermost transaction.
+func (n *NestedTx) Raw(f func(driverConn any) error) error {
+	return n.RawContext(context.Background(), f)
+}
+
+// RawContext is Raw with a context (see Tx.RawContext).
+func (n *NestedTx) RawContext(ctx context.Context, f func(driverConn any) error) error {
+	if err := n.checkDone(); err != nil {
+		return err
+	}
+	return n.root.RawContext(ctx, f)
+}
+
+// ExecContext executes a query that doesn't return rows within the nested
+// transaction.
+func (n *NestedTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if err := n.checkDone(); err != nil {
+		return nil, err
+	}
+	return n.root.ExecContext(ctx, query, args...)
+}
+
+// QueryContext executes a query that returns rows within the nested
+// transaction.
+func (n *NestedTx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if err := n.checkDone(); err != nil {
+		return nil, err
+	}
+	return n.root.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext executes a query that is expected to return at most one
+// row within the nested transaction.
+func (n *NestedTx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return n.root.QueryRowContext(ctx, query, args...)
+}
+
+// Err returns sql.ErrTxDone once n or a transaction it is nested in has
+// finished, and otherwise the outermost transaction's poison error, if any.
+func (n *NestedTx) Err() error {
+	if err := n.checkDone(); err != nil {
+		return err
+	}
+	return n.root.Err()
+}
+
+// Options returns the options of the outermost transaction.
+func (n *NestedTx) Options() sql.TxOptions {
+	return n.root.Options()
+}
@@ -0,0 +1,98 @@
+package txraw
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNestedFinishOnce(t *testing.T) {
+	db, _ := openFake(t)
+	tx, conn := beginFake(t, db)
+	defer tx.Rollback()
+
+	const callers = 8
+	for range 100 {
+		nested, err := tx.BeginNested(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.mu.Lock()
+		conn.execs = nil
+		conn.mu.Unlock()
+
+		// Release the callers together so that their checks overlap.
+		start := make(chan struct{})
+		var wg sync.WaitGroup
+		errs := make(chan error, callers)
+		for i := range callers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				if i%2 == 0 {
+					errs <- nested.Commit()
+				} else {
+					errs <- nested.Rollback()
+				}
+			}()
+		}
+		close(start)
+		wg.Wait()
+		close(errs)
+
+		var ended, done int
+		for err := range errs {
+			switch {
+			case err == nil:
+				ended++
+			case errors.Is(err, sql.ErrTxDone):
+				done++
+			default:
+				t.Fatal(err)
+			}
+		}
+		if ended != 1 || done != callers-1 {
+			t.Errorf("%d calls ended the nested transaction and %d returned sql.ErrTxDone; want 1 and %d", ended, done, callers-1)
+		}
+
+		conn.mu.Lock()
+		releases := 0
+		for _, stmt := range conn.execs {
+			if strings.HasPrefix(stmt, "RELEASE SAVEPOINT "+nested.savepoint) {
+				releases++
+			}
+		}
+		conn.mu.Unlock()
+		if releases != 1 {
+			t.Errorf("savepoint %s released %d times, want 1", nested.savepoint, releases)
+		}
+	}
+}
+
+func TestNestedDoneWithParent(t *testing.T) {
+	db, _ := openFake(t)
+	tx, _ := beginFake(t, db)
+	defer tx.Rollback()
+
+	outer, err := tx.BeginNested(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner, err := outer.BeginNested(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := outer.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := inner.Rollback(); !errors.Is(err, sql.ErrTxDone) {
+		t.Errorf("Rollback of a nested transaction whose parent committed = %v, want sql.ErrTxDone", err)
+	}
+	if err := inner.Err(); !errors.Is(err, sql.ErrTxDone) {
+		t.Errorf("Err = %v, want sql.ErrTxDone", err)
+	}
+}
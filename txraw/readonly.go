@@ -0,0 +1,31 @@
+package txraw
+
+import (
+	"context"
+	"fmt"
+)
+
+// CheckWritable returns an error matching ErrReadOnly, naming op, if tx was
+// started read-only. Raw callbacks are opaque to the wrapper, so writes
+// through them, such as CopyFrom, must be checked here before they start;
+// otherwise the server refuses them only after the source has been consumed
+// in part, with a less obvious error. Every helper in this module that
+// writes into a caller's transaction calls it, or RawWrite, first.
+//
+// A Tx created with Wrap does not know its options and always passes.
+func CheckWritable(tx RawTx, op string) error {
+	if tx.Options().ReadOnly {
+		return fmt.Errorf("%w: %s", ErrReadOnly, op)
+	}
+	return nil
+}
+
+// RawWrite is tx.RawContext for a callback that writes, op naming it for the
+// error: it fails with ErrReadOnly without calling f if tx is read-only (see
+// CheckWritable).
+func RawWrite(ctx context.Context, tx RawTx, op string, f func(driverConn any) error) error {
+	if err := CheckWritable(tx, op); err != nil {
+		return err
+	}
+	return tx.RawContext(ctx, f)
+}
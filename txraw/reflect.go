@@ -0,0 +1,94 @@
+package txraw
+
+import (
+	"database/sql"
+	"reflect"
+	"sync"
+)
+
+// rLocker is the read side of sql.Tx's closemu. Depending on the Go release
+// closemu is a sync.RWMutex or an internal closingMutex; both have these
+// methods.
+type rLocker interface {
+	RLock()
+	RUnlock()
+}
+
+// reflectRaw implements Raw for Go releases without an official
+// (*sql.Tx).Raw by reading the unexported tx.dc.ci field chain.
+//
+// It takes the same locks database/sql takes around its own operations on a
+// transaction: closemu for reading, so the transaction cannot be committed or
+// rolled back while f runs, and the driverConn mutex, so f never runs
+// concurrently with a Query or Exec on the same connection.
+func reflectRaw(tx *sql.Tx, f func(driverConn any) error) error {
+	// Use reflection to access `tx.dc` (`driverConn`).
+	txValue := reflect.ValueOf(tx).Elem()
+
+	closemu, ok := fieldAddr(txValue, "closemu").(rLocker)
+	if !ok {
+		return &LayoutError{Struct: "sql.Tx", Field: "closemu", Err: ErrTxFieldNotFound}
+	}
+	done, ok := fieldAddr(txValue, "done").(interface{ Load() bool })
+	if !ok {
+		return &LayoutError{Struct: "sql.Tx", Field: "done", Err: ErrTxFieldNotFound}
+	}
+
+	dcField := txValue.FieldByName("dc")
+	if !dcField.IsValid() || dcField.Kind() != reflect.Pointer {
+		return &LayoutError{Struct: "sql.Tx", Field: "dc", Err: ErrTxFieldNotFound}
+	}
+
+	// closemu must be held before checking done, exactly like
+	// (*sql.Tx).grabConn, to keep the Tx from closing underneath us.
+	closemu.RLock()
+	defer closemu.RUnlock()
+	if done.Load() {
+		return sql.ErrTxDone
+	}
+
+	// Make the field accessible and get the `driverConn` pointer.
+	dcField = accessible(dcField)
+	if dcField.IsNil() {
+		// The connection is released on Commit or Rollback.
+		return sql.ErrTxDone
+	}
+	dcValue := dcField.Elem()
+
+	// driverConn embeds the sync.Mutex that serializes all use of the driver
+	// connection.
+	dcLock, ok := dcField.Interface().(sync.Locker)
+	if !ok {
+		return &LayoutError{Struct: "sql.driverConn", Field: "Mutex", Err: ErrDriverConnInaccessible}
+	}
+
+	// Access `dc.ci` (`driver.Conn` interface).
+	ciField := dcValue.FieldByName("ci")
+	if !ciField.IsValid() || ciField.Kind() != reflect.Interface {
+		return &LayoutError{Struct: "sql.driverConn", Field: "ci", Err: ErrDriverConnInaccessible}
+	}
+
+	dcLock.Lock()
+	defer dcLock.Unlock()
+
+	// Make the field accessible and get the underlying driver connection.
+	ci := accessible(ciField).Interface()
+
+	return f(ci)
+}
+
+// accessible returns a settable, interface-able view of the unexported
+// struct field v.
+func accessible(v reflect.Value) reflect.Value {
+	return reflect.NewAt(v.Type(), v.Addr().UnsafePointer()).Elem()
+}
+
+// fieldAddr returns a pointer to the named field of the struct value v as an
+// interface, or nil if there is no such field.
+func fieldAddr(v reflect.Value, name string) any {
+	field := v.FieldByName(name)
+	if !field.IsValid() {
+		return nil
+	}
+	return accessible(field).Addr().Interface()
+}
@@ -0,0 +1,103 @@
+package txraw
+
+import (
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Run these with -race: besides the overlap counting of the fake driver, the
+// race detector checks that Raw's reads of sql.Tx internals are synchronized
+// with database/sql's own.
+
+func TestRawConcurrentExec(t *testing.T) {
+	db, _ := openFake(t)
+	tx, conn := beginFake(t, db)
+	defer tx.Rollback()
+
+	const workers, iterations = 8, 50
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*workers*iterations)
+	for range workers {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for range iterations {
+				if _, err := tx.Exec("SELECT 1"); err != nil {
+					errs <- err
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range iterations {
+				errs <- tx.Raw(func(driverConn any) error {
+					defer driverConn.(*fakeConn).enter()()
+					time.Sleep(10 * time.Microsecond)
+					return nil
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := conn.overlaps.Load(); n != 0 {
+		t.Errorf("Raw callbacks and Exec overlapped %d times on the connection", n)
+	}
+	if got := len(conn.execs); got != workers*iterations {
+		t.Errorf("driver saw %d statements, want %d", got, workers*iterations)
+	}
+}
+
+func TestCommitDuringRaw(t *testing.T) {
+	db, connector := openFake(t)
+	tx, _ := beginFake(t, db)
+
+	inRaw := make(chan struct{})
+	release := make(chan struct{})
+	rawDone := make(chan error, 1)
+	go func() {
+		rawDone <- tx.Raw(func(any) error {
+			close(inRaw)
+			<-release
+			return nil
+		})
+	}()
+	<-inRaw
+
+	committed := make(chan error, 1)
+	go func() { committed <- tx.Commit() }()
+
+	select {
+	case err := <-committed:
+		t.Fatalf("Commit returned %v while a Raw callback was running", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-rawDone; err != nil {
+		t.Errorf("Raw: %v", err)
+	}
+	if err := <-committed; err != nil {
+		t.Errorf("Commit: %v", err)
+	}
+
+	conn := connector.conns[0]
+	conn.mu.Lock()
+	commits := conn.commits
+	conn.mu.Unlock()
+	if commits != 1 {
+		t.Errorf("driver saw %d commits, want 1", commits)
+	}
+	if err := tx.Raw(func(any) error { return nil }); !errors.Is(err, sql.ErrTxDone) {
+		t.Errorf("Raw after Commit = %v, want sql.ErrTxDone", err)
+	}
+}
@@ -0,0 +1,218 @@
+package txraw
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/eqld/example-tx-raw/clock"
+	"github.com/eqld/example-tx-raw/random"
+)
+
+// ErrRetriesExhausted is returned by WithRetry, wrapping the last attempt's
+// error, when a retryable failure persisted past the attempt limit or the
+// time budget.
+var ErrRetriesExhausted = errors.New("txraw: retries exhausted")
+
+// SQLSTATE codes of the failures WithRetry retries by default.
+const (
+	SQLStateSerializationFailure = "40001"
+	SQLStateDeadlockDetected     = "40P01"
+)
+
+// RetryMode is how WithRetry runs a transaction again.
+type RetryMode int
+
+const (
+	// RetryTransaction rolls the transaction back and begins a new one for
+	// every attempt. This is what PostgreSQL needs.
+	RetryTransaction RetryMode = iota
+	// RetryCockroach runs the client-side retry protocol of CockroachDB in a
+	// single transaction: SAVEPOINT cockroach_restart after BEGIN, RELEASE
+	// SAVEPOINT cockroach_restart after fn, and on a retryable error ROLLBACK
+	// TO SAVEPOINT cockroach_restart before running fn again. CockroachDB
+	// keeps the transaction's priority across such restarts, so a contended
+	// transaction eventually wins. On PostgreSQL a restart keeps the old
+	// snapshot and does not resolve real conflicts.
+	RetryCockroach
+)
+
+// cockroachRestart is the savepoint name CockroachDB reserves for its retry
+// protocol.
+const cockroachRestart = "cockroach_restart"
+
+// RetryOptions configures WithRetry.
+type RetryOptions struct {
+	// Mode is how the transaction is run again. The zero value is
+	// RetryTransaction.
+	Mode RetryMode
+	// TxOptions are passed to Begin for every transaction.
+	TxOptions *sql.TxOptions
+	// MaxAttempts caps the number of attempts, the first one included. Zero
+	// means 5.
+	MaxAttempts int
+	// Budget caps the total time spent, attempts and backoff included: no
+	// retry is started whose backoff would end past it. Zero means no limit
+	// beyond MaxAttempts and ctx.
+	Budget time.Duration
+	// InitialBackoff is the wait before the first retry; it doubles for
+	// every retry after that, up to MaxBackoff. The actual wait is drawn
+	// between half of it and all of it, so that transactions that collided
+	// do not retry in lockstep. Zero means 50ms.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait between attempts. Zero means 5s.
+	MaxBackoff time.Duration
+	// Retryable decides which errors are retried. Nil means IsRetryable.
+	Retryable func(err error) bool
+	// OnRetry, if set, is called before each wait with the number of the
+	// attempt that failed and its error.
+	OnRetry func(attempt int, err error, backoff time.Duration)
+
+	// Clock times the backoff and the budget. Nil means clock.Real.
+	Clock clock.Clock
+	// Rand draws the backoff jitter. Nil means random.Default.
+	Rand random.Rand
+}
+
+// IsRetryable reports whether err is a serialization failure (SQLSTATE
+// 40001, also CockroachDB's retry error) or a deadlock (40P01), after which
+// the whole transaction can be retried. It recognizes errors of any driver
+// whose error type has a SQLState() string method, as pgx's *pgconn.PgError
+// and lib/pq's *pq.Error do, however deeply they are wrapped.
+func IsRetryable(err error) bool {
+	switch SQLState(err) {
+	case SQLStateSerializationFailure, SQLStateDeadlockDetected:
+		return true
+	default:
+		return false
+	}
+}
+
+// SQLState returns the SQLSTATE code of err, or "" if err carries none.
+func SQLState(err error) string {
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) {
+		return stateErr.SQLState()
+	}
+	return ""
+}
+
+// WithRetry is WithTx for transactions that may lose a conflict with
+// another one: if fn or the commit fails with a retryable error (see
+// IsRetryable), including one from a raw CopyFrom, the transaction is rolled
+// back and fn runs again in a new one, after an exponential backoff.
+//
+// fn must be safe to run more than once: everything it does to the database
+// is rolled back between attempts, but side effects elsewhere are not, and a
+// CopyFromSource it consumed must be recreated inside fn.
+//
+// A non-retryable error is returned as is. Once MaxAttempts or Budget is
+// used up the last error is returned wrapped with ErrRetriesExhausted; if
+// ctx ends during a backoff, ctx's error is returned wrapping the last one.
+//
+// With RetryCockroach all attempts share one transaction, restarted through
+// the cockroach_restart savepoint on the raw connection (see RetryMode).
+func WithRetry(ctx context.Context, db *sql.DB, opts RetryOptions, fn func(tx *Tx) error) error {
+	if opts.Mode == RetryCockroach {
+		return withCockroachRetry(ctx, db, opts, fn)
+	}
+	return retry(ctx, opts, func() error {
+		return withTx(ctx, db, opts.TxOptions, fn)
+	})
+}
+
+// withCockroachRetry is WithRetry with RetryCockroach.
+func withCockroachRetry(ctx context.Context, db *sql.DB, opts RetryOptions, fn func(tx *Tx) error) (err error) {
+	tx, err := Begin(ctx, db, opts.TxOptions)
+	if err != nil {
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		if r := recover(); r != nil {
+			_ = tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	retryable := opts.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+	err = tx.SavepointContext(ctx, cockroachRestart)
+	if err == nil {
+		err = retry(ctx, opts, func() error {
+			err := fn(tx)
+			if err == nil {
+				// A conflict may only be detected here.
+				err = tx.ReleaseContext(ctx, cockroachRestart)
+			}
+			if err != nil && retryable(err) {
+				if restartErr := tx.RollbackToContext(ctx, cockroachRestart); restartErr != nil {
+					// Not retryable any more: the restart itself failed.
+					return fmt.Errorf("%w (after: %v)", restartErr, err)
+				}
+			}
+			return err
+		})
+	}
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return fmt.Errorf("%w (rollback also failed: %w)", err, rollbackErr)
+		}
+		return err
+	}
+
+	committed = true
+	return tx.Commit()
+}
+
+// retry calls attempt until it succeeds, fails with an error that is not
+// retryable, or opts' limits are reached, backing off in between.
+func retry(ctx context.Context, opts RetryOptions, attempt func() error) error {
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 5
+	}
+	backoff := opts.InitialBackoff
+	if backoff <= 0 {
+		backoff = 50 * time.Millisecond
+	}
+	maxBackoff := opts.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = 5 * time.Second
+	}
+	retryable := opts.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+	c := clock.Or(opts.Clock)
+	rnd := random.Or(opts.Rand)
+	start := c.Now()
+
+	for n := 1; ; n++ {
+		err := attempt()
+		if err == nil || !retryable(err) {
+			return err
+		}
+
+		wait := min(backoff, maxBackoff)
+		wait = wait/2 + time.Duration(rnd.Int64N(int64(wait/2)+1))
+		if n >= maxAttempts || (opts.Budget > 0 && clock.Since(c, start)+wait > opts.Budget) {
+			return fmt.Errorf("%w after %d attempts: %w", ErrRetriesExhausted, n, err)
+		}
+		if opts.OnRetry != nil {
+			opts.OnRetry(n, err, wait)
+		}
+		if sleepErr := clock.Sleep(ctx, c, wait); sleepErr != nil {
+			return fmt.Errorf("%w (last attempt: %w)", sleepErr, err)
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,115 @@
+package txraw
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+)
+
+// Savepoint sets a savepoint named name in the transaction. Rolling back to
+// it undoes what was done since, such as a failed CopyFrom chunk, and leaves
+// the transaction usable, which it is not after a failed statement in
+// PostgreSQL otherwise.
+//
+// The SAVEPOINT command is sent on the raw connection, since database/sql
+// has no savepoint API; name must be a plain identifier (letters, digits and
+// underscores, not starting with a digit). Setting a savepoint with the name
+// of an existing one hides the older one until the newer is released.
+func (tx *Tx) Savepoint(name string) error {
+	return tx.SavepointContext(context.Background(), name)
+}
+
+// SavepointContext is Savepoint with a context.
+func (tx *Tx) SavepointContext(ctx context.Context, name string) error {
+	return savepointCommand(ctx, tx.RawContext, "SAVEPOINT", name)
+}
+
+// RollbackTo rolls the transaction back to the savepoint name, which stays
+// set and can be rolled back to again.
+func (tx *Tx) RollbackTo(name string) error {
+	return tx.RollbackToContext(context.Background(), name)
+}
+
+// RollbackToContext is RollbackTo with a context.
+func (tx *Tx) RollbackToContext(ctx context.Context, name string) error {
+	return savepointCommand(ctx, tx.RawContext, "ROLLBACK TO SAVEPOINT", name)
+}
+
+// Release forgets the savepoint name and those set after it, keeping what
+// was done since.
+func (tx *Tx) Release(name string) error {
+	return tx.ReleaseContext(context.Background(), name)
+}
+
+// ReleaseContext is Release with a context.
+func (tx *Tx) ReleaseContext(ctx context.Context, name string) error {
+	return savepointCommand(ctx, tx.RawContext, "RELEASE SAVEPOINT", name)
+}
+
+// Savepoint is Tx.Savepoint for a ConnTx.
+func (tx *ConnTx) Savepoint(name string) error {
+	return tx.SavepointContext(context.Background(), name)
+}
+
+// SavepointContext is Tx.SavepointContext for a ConnTx.
+func (tx *ConnTx) SavepointContext(ctx context.Context, name string) error {
+	return savepointCommand(ctx, tx.RawContext, "SAVEPOINT", name)
+}
+
+// RollbackTo is Tx.RollbackTo for a ConnTx.
+func (tx *ConnTx) RollbackTo(name string) error {
+	return tx.RollbackToContext(context.Background(), name)
+}
+
+// RollbackToContext is Tx.RollbackToContext for a ConnTx.
+func (tx *ConnTx) RollbackToContext(ctx context.Context, name string) error {
+	return savepointCommand(ctx, tx.RawContext, "ROLLBACK TO SAVEPOINT", name)
+}
+
+// Release is Tx.Release for a ConnTx.
+func (tx *ConnTx) Release(name string) error {
+	return tx.ReleaseContext(context.Background(), name)
+}
+
+// ReleaseContext is Tx.ReleaseContext for a ConnTx.
+func (tx *ConnTx) ReleaseContext(ctx context.Context, name string) error {
+	return savepointCommand(ctx, tx.RawContext, "RELEASE SAVEPOINT", name)
+}
+
+// savepointCommand sends "cmd name" through raw with the driver's
+// ExecerContext.
+func savepointCommand(ctx context.Context, raw func(context.Context, func(driverConn any) error) error, cmd, name string) error {
+	if !validSavepointName(name) {
+		return fmt.Errorf("txraw: invalid savepoint name %q", name)
+	}
+	stmt := cmd + " " + name
+	err := raw(ctx, func(driverConn any) error {
+		execer, ok := driverConn.(driver.ExecerContext)
+		if !ok {
+			return fmt.Errorf("%w: %T does not implement driver.ExecerContext", ErrUnsupportedDriver, driverConn)
+		}
+		_, err := execer.ExecContext(ctx, stmt, nil)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("txraw: %s failed: %w", stmt, err)
+	}
+	return nil
+}
+
+// validSavepointName reports whether name can be used unquoted, which keeps
+// the commands portable across SQL dialects.
+func validSavepointName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_', 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z':
+		case '0' <= r && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,229 @@
+package txraw
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/eqld/example-tx-raw/clock"
+)
+
+// DeadlockError is returned by Serialized when an operation would wait for
+// the transaction forever: either it was started from inside an operation
+// holding the transaction, or it waited longer than the DeadlockTimeout. It
+// matches ErrDeadlock with errors.Is.
+type DeadlockError struct {
+	// Op is the operation that gave up waiting.
+	Op string
+	// HeldBy is the operation holding the transaction.
+	HeldBy string
+	// HeldFor is how long HeldBy had held the transaction.
+	HeldFor time.Duration
+	// Reentrant reports that Op was started from inside HeldBy.
+	Reentrant bool
+}
+
+func (e *DeadlockError) Error() string {
+	if e.Reentrant {
+		return fmt.Sprintf("%v: %s started inside %s", ErrDeadlock, e.Op, e.HeldBy)
+	}
+	return fmt.Sprintf("%v: %s waited for %s, held for %v", ErrDeadlock, e.Op, e.HeldBy, e.HeldFor)
+}
+
+func (e *DeadlockError) Unwrap() error {
+	return ErrDeadlock
+}
+
+// SerializedOptions configures NewSerialized.
+type SerializedOptions struct {
+	// DeadlockTimeout is how long an operation waits for the transaction
+	// before it fails with a *DeadlockError. Zero means it waits until its
+	// context is done.
+	DeadlockTimeout time.Duration
+	// Clock times the waits; nil means clock.Real.
+	Clock clock.Clock
+}
+
+// Serialized lets goroutines share one transaction. A transaction runs on a
+// single connection that can only do one thing at a time, and concurrent use
+// of it - above all through Raw, which bypasses database/sql's own locking -
+// corrupts the connection's protocol state. Serialized queues every operation
+// and runs them one at a time, in the order they arrived.
+//
+// Operations that use the connection across several calls get it for their
+// whole duration: Query hands its rows to a callback and closes them before
+// the next operation runs, and Do runs a whole function with exclusive use of
+// the transaction. Calling Serialized from inside such a callback would wait
+// for itself; Serialized detects this when the callback passes on the
+// context it was given, and reports any other wait that exceeds the
+// DeadlockTimeout, as a *DeadlockError instead of hanging.
+//
+// Serialized is opt-in; the transaction itself must not be used directly
+// while it is shared.
+type Serialized struct {
+	tx    RawTx
+	opts  SerializedOptions
+	clock clock.Clock
+
+	// queue is a one-slot semaphore; goroutines blocked sending to a channel
+	// are woken in FIFO order, which makes the queue fair.
+	queue chan struct{}
+
+	mu     sync.Mutex
+	holder string
+	since  time.Time
+	done   bool
+}
+
+// NewSerialized returns a Serialized sharing tx.
+func NewSerialized(tx RawTx, opts SerializedOptions) *Serialized {
+	return &Serialized{
+		tx:    tx,
+		opts:  opts,
+		clock: clock.Or(opts.Clock),
+		queue: make(chan struct{}, 1),
+	}
+}
+
+// heldKey marks contexts passed to callbacks holding a Serialized.
+type heldKey struct{ s *Serialized }
+
+// acquire waits for the transaction on behalf of op.
+func (s *Serialized) acquire(ctx context.Context, op string) error {
+	if holder, ok := ctx.Value(heldKey{s}).(string); ok {
+		return &DeadlockError{Op: op, HeldBy: holder, Reentrant: true}
+	}
+
+	var timeout <-chan time.Time
+	if s.opts.DeadlockTimeout > 0 {
+		timer := s.clock.NewTimer(s.opts.DeadlockTimeout)
+		defer timer.Stop()
+		timeout = timer.C()
+	}
+
+	select {
+	case s.queue <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timeout:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return &DeadlockError{Op: op, HeldBy: s.holder, HeldFor: clock.Since(s.clock, s.since)}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done {
+		<-s.queue
+		return sql.ErrTxDone
+	}
+	s.holder, s.since = op, s.clock.Now()
+	return nil
+}
+
+func (s *Serialized) release() {
+	s.mu.Lock()
+	s.holder = ""
+	s.mu.Unlock()
+	<-s.queue
+}
+
+// run runs f as op with exclusive use of the transaction. f's context is
+// marked as holding it.
+func (s *Serialized) run(ctx context.Context, op string, f func(ctx context.Context) error) error {
+	if err := s.acquire(ctx, op); err != nil {
+		return err
+	}
+	defer s.release()
+	return f(context.WithValue(ctx, heldKey{s}, op))
+}
+
+// Do runs fn with exclusive use of the transaction, for a sequence of
+// operations that must not be interleaved with other goroutines' ones. fn
+// must use tx, not the Serialized, and should pass ctx on.
+func (s *Serialized) Do(ctx context.Context, fn func(ctx context.Context, tx RawTx) error) error {
+	return s.run(ctx, "Do", func(ctx context.Context) error {
+		return fn(ctx, s.tx)
+	})
+}
+
+// ExecContext queues tx.ExecContext.
+func (s *Serialized) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	var result sql.Result
+	err := s.run(ctx, "Exec "+query, func(ctx context.Context) error {
+		var err error
+		result, err = s.tx.ExecContext(ctx, query, args...)
+		return err
+	})
+	return result, err
+}
+
+// Query queues tx.QueryContext and passes the rows to fn. The rows are closed
+// when fn returns, before the next operation runs.
+func (s *Serialized) Query(ctx context.Context, fn func(rows *sql.Rows) error, query string, args ...any) error {
+	return s.run(ctx, "Query "+query, func(ctx context.Context) error {
+		rows, err := s.tx.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		if err := fn(rows); err != nil {
+			return err
+		}
+		return rows.Err()
+	})
+}
+
+// QueryRow queues tx.QueryRowContext and scans the row into dest.
+func (s *Serialized) QueryRow(ctx context.Context, query string, args []any, dest ...any) error {
+	return s.run(ctx, "QueryRow "+query, func(ctx context.Context) error {
+		return s.tx.QueryRowContext(ctx, query, args...).Scan(dest...)
+	})
+}
+
+// RawContext queues tx.RawContext.
+func (s *Serialized) RawContext(ctx context.Context, f func(driverConn any) error) error {
+	return s.run(ctx, "Raw", func(ctx context.Context) error {
+		return s.tx.RawContext(ctx, f)
+	})
+}
+
+// Raw queues tx.Raw.
+func (s *Serialized) Raw(f func(driverConn any) error) error {
+	return s.RawContext(context.Background(), f)
+}
+
+// Commit waits for the queued operations and commits the transaction.
+// Operations queued after it fail with sql.ErrTxDone.
+func (s *Serialized) Commit() error {
+	return s.finish("Commit", s.tx.Commit)
+}
+
+// Rollback waits for the queued operations and rolls the transaction back.
+// Operations queued after it fail with sql.ErrTxDone.
+func (s *Serialized) Rollback() error {
+	return s.finish("Rollback", s.tx.Rollback)
+}
+
+func (s *Serialized) finish(op string, end func() error) error {
+	if err := s.acquire(context.Background(), op); err != nil {
+		return err
+	}
+	defer s.release()
+	s.mu.Lock()
+	s.done = true
+	s.mu.Unlock()
+	return end()
+}
+
+// Err returns tx.Err.
+func (s *Serialized) Err() error {
+	return s.tx.Err()
+}
+
+// Options returns tx.Options.
+func (s *Serialized) Options() sql.TxOptions {
+	return s.tx.Options()
+}
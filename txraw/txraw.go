@@ -0,0 +1,297 @@
+// Package txraw provides access to the underlying driver connection of a
+// database/sql transaction.
+//
+// sql.Conn offers a Raw() method for driver-specific functionality, but sql.Tx
+// has no equivalent. This package fills the gap with a reflection-based
+// workaround so that driver features such as pgx's CopyFrom can be used inside
+// a transaction:
+//
+//	tx := txraw.Wrap(sqlTx)
+//	err := tx.Raw(func(driverConn any) error {
+//		conn := driverConn.(*stdlib.Conn).Conn()
+//		_, err := conn.CopyFrom(ctx, pgx.Identifier{"items"}, columns, source)
+//		return err
+//	})
+//
+// IMPORTANT: The reflection-based approach is fragile and depends on the
+// internal structure of sql.Tx, which could change between Go versions.
+// An official Tx.Raw() method in the standard library would eliminate
+// the need for this unsafe workaround.
+package txraw
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/stdlib"
+
+	"github.com/eqld/example-tx-raw/runctx"
+)
+
+// Tx wraps a sql.Tx and provides a Raw() method using reflection.
+// This demonstrates the workaround currently needed to access the underlying
+// driver connection from within a transaction context.
+//
+// Tx has every method of sql.Tx (see SQLTx), so it can be passed through
+// existing code that accepts a *sql.Tx-shaped interface without converting
+// back and forth.
+//
+// A Tx also guards against callbacks that panic: the driver connection may be
+// left mid-protocol, so the Tx is marked as poisoned and every later
+// operation on it fails fast (see Err).
+type Tx struct {
+	tx    *sql.Tx
+	opts  sql.TxOptions
+	guard guard
+}
+
+// SQLTx is the method set of *sql.Tx. Both *sql.Tx and *Tx implement it.
+type SQLTx interface {
+	Commit() error
+	Rollback() error
+	Exec(query string, args ...any) (sql.Result, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	Prepare(query string) (*sql.Stmt, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	Stmt(stmt *sql.Stmt) *sql.Stmt
+	StmtContext(ctx context.Context, stmt *sql.Stmt) *sql.Stmt
+}
+
+var (
+	_ SQLTx = (*sql.Tx)(nil)
+	_ SQLTx = (*Tx)(nil)
+)
+
+// Wrap returns a *Tx for tx so that Raw can be called on it. The wrapper
+// shares the transaction with tx; committing or rolling back either one
+// finishes the same transaction. Poisoning is tracked per wrapper, so keep
+// using the same *Tx after wrapping.
+func Wrap(tx *sql.Tx) *Tx {
+	return &Tx{tx: tx}
+}
+
+// Begin starts a transaction on db with opts and wraps it, in one call.
+// Unlike Wrap, the wrapper records opts, so Options reports the isolation
+// level and read-only state the transaction was started with.
+func Begin(ctx context.Context, db *sql.DB, opts *sql.TxOptions) (*Tx, error) {
+	sqlTx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("txraw: begin failed: %w", err)
+	}
+
+	tx := Wrap(sqlTx)
+	if opts != nil {
+		tx.opts = *opts
+	}
+
+	if err := labelSession(ctx, tx); err != nil {
+		_ = sqlTx.Rollback()
+		return nil, err
+	}
+	return tx, nil
+}
+
+// applicationName is the base application_name set by labelSession.
+const applicationName = "txraw"
+
+// labelSession sets application_name for the duration of the transaction to
+// include the runctx labels of ctx, if any, so the transaction can be
+// attributed in pg_stat_activity. It only applies to pgx connections; the
+// setting is transaction-local and reverts on commit or rollback.
+func labelSession(ctx context.Context, tx *Tx) error {
+	labels := runctx.FromContext(ctx)
+	if labels.IsZero() {
+		return nil
+	}
+
+	isPgx := false
+	if err := tx.Raw(func(driverConn any) error {
+		_, isPgx = driverConn.(*stdlib.Conn)
+		return nil
+	}); err != nil {
+		return err
+	}
+	if !isPgx {
+		return nil
+	}
+
+	_, err := tx.ExecContext(ctx, "SELECT set_config('application_name', $1, true)",
+		labels.ApplicationName(applicationName))
+	if err != nil {
+		return fmt.Errorf("txraw: setting application_name failed: %w", err)
+	}
+	return nil
+}
+
+// Options returns the options the transaction was started with. For a Tx
+// created with Wrap, which cannot know them, it returns the zero value
+// (default isolation level, read-write).
+func (tx *Tx) Options() sql.TxOptions {
+	return tx.opts
+}
+
+// Unwrap returns the *sql.Tx that tx is based on.
+func (tx *Tx) Unwrap() *sql.Tx {
+	return tx.tx
+}
+
+// Err returns the *PanicError that poisoned tx, or nil if no Raw callback
+// has panicked. The error matches ErrPoisoned with errors.Is.
+func (tx *Tx) Err() error {
+	return tx.guard.err()
+}
+
+// Commit commits the transaction. A poisoned transaction is rolled back
+// instead and Commit returns the poison error.
+func (tx *Tx) Commit() error {
+	if err := tx.Err(); err != nil {
+		if rollbackErr := tx.tx.Rollback(); rollbackErr != nil && !errors.Is(rollbackErr, sql.ErrTxDone) {
+			return fmt.Errorf("%w (rollback also failed: %w)", err, rollbackErr)
+		}
+		return err
+	}
+	return tx.tx.Commit()
+}
+
+// Rollback aborts the transaction. It is always allowed, including on a
+// poisoned transaction.
+func (tx *Tx) Rollback() error {
+	return tx.tx.Rollback()
+}
+
+// ExecContext executes a query that doesn't return rows within the
+// transaction. It fails fast on a poisoned transaction.
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if err := tx.Err(); err != nil {
+		return nil, err
+	}
+	return tx.tx.ExecContext(ctx, query, args...)
+}
+
+// QueryContext executes a query that returns rows within the transaction.
+// It fails fast on a poisoned transaction.
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if err := tx.Err(); err != nil {
+		return nil, err
+	}
+	return tx.tx.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext executes a query that is expected to return at most one
+// row within the transaction. sql.Row cannot carry an error of ours, so check
+// Err first if the transaction may be poisoned.
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return tx.tx.QueryRowContext(ctx, query, args...)
+}
+
+// Exec is like ExecContext with context.Background.
+func (tx *Tx) Exec(query string, args ...any) (sql.Result, error) {
+	return tx.ExecContext(context.Background(), query, args...)
+}
+
+// Query is like QueryContext with context.Background.
+func (tx *Tx) Query(query string, args ...any) (*sql.Rows, error) {
+	return tx.QueryContext(context.Background(), query, args...)
+}
+
+// QueryRow is like QueryRowContext with context.Background.
+func (tx *Tx) QueryRow(query string, args ...any) *sql.Row {
+	return tx.QueryRowContext(context.Background(), query, args...)
+}
+
+// PrepareContext creates a prepared statement for use within the
+// transaction. It fails fast on a poisoned transaction.
+func (tx *Tx) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	if err := tx.Err(); err != nil {
+		return nil, err
+	}
+	return tx.tx.PrepareContext(ctx, query)
+}
+
+// Prepare is like PrepareContext with context.Background.
+func (tx *Tx) Prepare(query string) (*sql.Stmt, error) {
+	return tx.PrepareContext(context.Background(), query)
+}
+
+// StmtContext returns a transaction-specific prepared statement from an
+// existing statement, as (*sql.Tx).StmtContext does.
+func (tx *Tx) StmtContext(ctx context.Context, stmt *sql.Stmt) *sql.Stmt {
+	return tx.tx.StmtContext(ctx, stmt)
+}
+
+// Stmt is like StmtContext with context.Background.
+func (tx *Tx) Stmt(stmt *sql.Stmt) *sql.Stmt {
+	return tx.StmtContext(context.Background(), stmt)
+}
+
+// Raw executes the provided function with access to the underlying driver connection.
+//
+// If the running Go release provides an official (*sql.Tx).Raw method, Raw
+// delegates to it. Otherwise it falls back to reflection on the unexported
+// fields of sql.Tx, which is necessary because sql.Tx doesn't provide a Raw()
+// method like sql.Conn does.
+//
+// The reflection process:
+// 1. Access sql.Tx.dc (driverConn) field
+// 2. Extract dc.ci (driver.Conn interface)
+// 3. Execute the callback with the driver connection
+//
+// Raw returns sql.ErrTxDone if the transaction has already been committed or
+// rolled back. If the fields above cannot be found it returns a *LayoutError,
+// which matches ErrLayoutChanged and either ErrTxFieldNotFound or
+// ErrDriverConnInaccessible with errors.Is.
+//
+// This approach is fragile because:
+// - It depends on internal Go standard library structure
+// - Field names and types could change between Go versions
+// - It bypasses Go's type safety and encapsulation
+//
+// If f panics, the panic is recovered and returned as a *PanicError, and tx
+// is poisoned: later calls to Raw and Commit fail with that error.
+//
+// f runs with the transaction's connection locked, as database/sql locks it
+// around its own operations: calls on tx from other goroutines, Commit and
+// Rollback included, wait until f returns. f itself must therefore not use
+// tx, or the *sql.Tx behind it, in any way; a tx.ExecContext inside f
+// deadlocks. Do all work inside f on driverConn, and use tx before or after.
+func (tx *Tx) Raw(f func(driverConn any) error) error {
+	if tx == nil || tx.tx == nil {
+		return ErrNilTx
+	}
+	return tx.guard.call(func() error {
+		if official := officialRaw(tx.tx); official != nil {
+			return official.Raw(f)
+		}
+		return fallbackRaw(tx.tx, f)
+	})
+}
+
+// officialRawer is the method set of an official (*sql.Tx).Raw, mirroring
+// (*sql.Conn).Raw. It is checked with a dynamic type assertion so that this
+// package keeps compiling on releases without the method and picks it up
+// automatically on releases that add it.
+type officialRawer interface {
+	Raw(f func(driverConn any) error) error
+}
+
+// officialRaw returns tx as an officialRawer, or nil on releases without
+// (*sql.Tx).Raw. It is a variable so that tests can exercise the official
+// path on releases that lack it.
+var officialRaw = func(tx *sql.Tx) officialRawer {
+	if official, ok := any(tx).(officialRawer); ok {
+		return official
+	}
+	return nil
+}
+
+// HasOfficialRaw reports whether the running Go release provides
+// (*sql.Tx).Raw, in which case Raw uses it instead of reflection.
+func HasOfficialRaw() bool {
+	return officialRaw((*sql.Tx)(nil)) != nil
+}
@@ -0,0 +1,204 @@
+package txraw
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRawFallback(t *testing.T) {
+	if HasOfficialRaw() {
+		t.Skip("this Go release has (*sql.Tx).Raw; Raw does not take the fallback path")
+	}
+	db, connector := openFake(t)
+	tx, conn := beginFake(t, db)
+	defer tx.Rollback()
+
+	if len(connector.conns) != 1 || conn != connector.conns[0] {
+		t.Fatalf("Raw passed %p, want the connection the driver opened", conn)
+	}
+	if err := tx.Raw(func(driverConn any) error { return errors.New("callback failed") }); err == nil || err.Error() != "callback failed" {
+		t.Errorf("Raw = %v, want the callback's error", err)
+	}
+}
+
+// fakeOfficial stands in for an official (*sql.Tx).Raw.
+type fakeOfficial struct {
+	calls *int
+}
+
+func (o fakeOfficial) Raw(f func(driverConn any) error) error {
+	*o.calls++
+	return f("official")
+}
+
+func TestRawOfficial(t *testing.T) {
+	var calls int
+	saved := officialRaw
+	officialRaw = func(*sql.Tx) officialRawer { return fakeOfficial{&calls} }
+	t.Cleanup(func() { officialRaw = saved })
+
+	if !HasOfficialRaw() {
+		t.Error("HasOfficialRaw = false with an official Raw")
+	}
+
+	db, _ := openFake(t)
+	sqlTx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlTx.Rollback()
+
+	var got any
+	if err := Wrap(sqlTx).Raw(func(driverConn any) error {
+		got = driverConn
+		return nil
+	}); err != nil {
+		t.Fatalf("Raw: %v", err)
+	}
+	if calls != 1 || got != "official" {
+		t.Errorf("official Raw called %d times with %v, want once with official", calls, got)
+	}
+}
+
+func TestRawAfterFinish(t *testing.T) {
+	for _, finish := range []struct {
+		name string
+		f    func(*Tx) error
+	}{
+		{"Commit", (*Tx).Commit},
+		{"Rollback", (*Tx).Rollback},
+	} {
+		t.Run(finish.name, func(t *testing.T) {
+			db, _ := openFake(t)
+			tx, _ := beginFake(t, db)
+			if err := finish.f(tx); err != nil {
+				t.Fatalf("%s: %v", finish.name, err)
+			}
+
+			called := false
+			err := tx.Raw(func(any) error {
+				called = true
+				return nil
+			})
+			if !errors.Is(err, sql.ErrTxDone) || called {
+				t.Errorf("Raw after %s = %v (callback called: %v), want sql.ErrTxDone", finish.name, err, called)
+			}
+		})
+	}
+}
+
+func TestRawNilTx(t *testing.T) {
+	var tx *Tx
+	if err := tx.Raw(func(any) error { return nil }); !errors.Is(err, ErrNilTx) {
+		t.Errorf("Raw on nil Tx = %v, want ErrNilTx", err)
+	}
+}
+
+func TestLayoutError(t *testing.T) {
+	var err error = &LayoutError{Struct: "sql.Tx", Field: "dc", Err: ErrTxFieldNotFound}
+
+	if !errors.Is(err, ErrLayoutChanged) || !errors.Is(err, ErrTxFieldNotFound) {
+		t.Errorf("%v does not match ErrLayoutChanged and ErrTxFieldNotFound", err)
+	}
+	if errors.Is(err, ErrDriverConnInaccessible) {
+		t.Errorf("%v matches ErrDriverConnInaccessible", err)
+	}
+	if !strings.Contains(err.Error(), "sql.Tx.dc") {
+		t.Errorf("Error() = %q, want the field name", err.Error())
+	}
+
+	var layoutErr *LayoutError
+	if !errors.As(err, &layoutErr) || layoutErr.Field != "dc" {
+		t.Errorf("errors.As(%v) = %v", err, layoutErr)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	if err := Verify(); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+}
+
+func TestPanicPoisons(t *testing.T) {
+	db, connector := openFake(t)
+	tx, _ := beginFake(t, db)
+
+	err := tx.Raw(func(any) error { panic("boom") })
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) || panicErr.Value != "boom" || len(panicErr.Stack) == 0 {
+		t.Fatalf("Raw with panicking callback = %v, want a *PanicError for boom", err)
+	}
+	if !errors.Is(tx.Err(), ErrPoisoned) {
+		t.Errorf("Err = %v, want ErrPoisoned", tx.Err())
+	}
+
+	if _, err := tx.Exec("SELECT 1"); !errors.Is(err, ErrPoisoned) {
+		t.Errorf("Exec = %v, want ErrPoisoned", err)
+	}
+	called := false
+	if err := tx.Raw(func(any) error {
+		called = true
+		return nil
+	}); !errors.Is(err, ErrPoisoned) || called {
+		t.Errorf("Raw = %v (callback called: %v), want ErrPoisoned", err, called)
+	}
+	if err := tx.Commit(); !errors.Is(err, ErrPoisoned) {
+		t.Errorf("Commit = %v, want ErrPoisoned", err)
+	}
+
+	conn := connector.conns[0]
+	if conn.commits != 0 || conn.rollbacks != 1 {
+		t.Errorf("poisoned Commit: %d commits, %d rollbacks; want it rolled back", conn.commits, conn.rollbacks)
+	}
+	if len(conn.execs) != 0 {
+		t.Errorf("statements reached the driver after poisoning: %q", conn.execs)
+	}
+}
+
+func TestCommit(t *testing.T) {
+	db, connector := openFake(t)
+	tx, _ := beginFake(t, db)
+
+	if _, err := tx.Exec("INSERT 1"); err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if err := tx.Commit(); !errors.Is(err, sql.ErrTxDone) {
+		t.Errorf("second Commit = %v, want sql.ErrTxDone", err)
+	}
+
+	conn := connector.conns[0]
+	if conn.commits != 1 || conn.rollbacks != 0 || len(conn.execs) != 1 {
+		t.Errorf("got %d commits, %d rollbacks, execs %q; want one commit after INSERT 1",
+			conn.commits, conn.rollbacks, conn.execs)
+	}
+}
+
+func TestRawWriteReadOnly(t *testing.T) {
+	db, _ := openFake(t)
+	tx, err := Begin(context.Background(), db, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	called := false
+	err = RawWrite(context.Background(), tx, "CopyFrom into items", func(any) error {
+		called = true
+		return nil
+	})
+	if !errors.Is(err, ErrReadOnly) || !strings.Contains(err.Error(), "CopyFrom into items") {
+		t.Errorf("RawWrite in a read-only transaction = %v, want ErrReadOnly naming the operation", err)
+	}
+	if called {
+		t.Error("RawWrite called its callback in a read-only transaction")
+	}
+	if err := tx.Raw(func(any) error { return nil }); err != nil {
+		t.Errorf("Raw in a read-only transaction = %v, want nil", err)
+	}
+}
@@ -0,0 +1,42 @@
+package txraw
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+)
+
+// RawAs is like Tx.Raw but also asserts the driver connection to the concrete
+// type T before calling fn, removing the type assertion boilerplate from
+// every caller:
+//
+//	err := txraw.RawAs(sqlTx, func(conn *stdlib.Conn) error {
+//		_, err := conn.Conn().CopyFrom(ctx, table, columns, source)
+//		return err
+//	})
+//
+// If the driver connection is not a T, fn is not called and a *ConnTypeError
+// matching ErrUnsupportedDriver is returned.
+func RawAs[T driver.Conn](tx *sql.Tx, fn func(T) error) error {
+	return Wrap(tx).Raw(As(fn))
+}
+
+// As adapts a callback taking a concrete driver connection type T into one
+// suitable for Tx.Raw, Tx.RawContext or sql.Conn.Raw:
+//
+//	err := tx.Raw(txraw.As(func(conn *stdlib.Conn) error { ... }))
+//
+// If the driver connection is not a T, fn is not called and a *ConnTypeError
+// matching ErrUnsupportedDriver is returned.
+func As[T driver.Conn](fn func(T) error) func(driverConn any) error {
+	return func(driverConn any) error {
+		conn, ok := driverConn.(T)
+		if !ok {
+			return &ConnTypeError{
+				Want: reflect.TypeFor[T](),
+				Got:  reflect.TypeOf(driverConn),
+			}
+		}
+		return fn(conn)
+	}
+}
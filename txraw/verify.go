@@ -0,0 +1,75 @@
+package txraw
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+// Verify checks that the fallback path of Raw works with the running Go
+// release. It opens a throwaway transaction against an in-process fake
+// driver, which needs no database, extracts the driver connection and checks
+// that it is the one the fake driver handed out. It also checks that Raw
+// refuses a finished transaction.
+//
+// Call it at startup to turn silent breakage after a Go upgrade into an
+// actionable failure. The returned error matches ErrLayoutChanged when the
+// internal layout of sql.Tx has changed.
+func Verify() error {
+	db := sql.OpenDB(verifyConnector{})
+	defer db.Close()
+
+	sqlTx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("txraw: verify: beginning fake transaction failed: %w", err)
+	}
+	defer sqlTx.Rollback()
+
+	var got any
+	if err := fallbackRaw(sqlTx, func(driverConn any) error {
+		got = driverConn
+		return nil
+	}); err != nil {
+		return fmt.Errorf("txraw: verify on %s: %w", runtime.Version(), err)
+	}
+	if _, ok := got.(*verifyConn); !ok {
+		return fmt.Errorf("txraw: verify on %s: %w: extracted %T instead of the fake driver connection",
+			runtime.Version(), ErrLayoutChanged, got)
+	}
+
+	if err := sqlTx.Commit(); err != nil {
+		return fmt.Errorf("txraw: verify: committing fake transaction failed: %w", err)
+	}
+	if err := fallbackRaw(sqlTx, func(any) error { return nil }); !errors.Is(err, sql.ErrTxDone) {
+		return fmt.Errorf("txraw: verify on %s: %w: finished transaction not detected (got %v)",
+			runtime.Version(), ErrLayoutChanged, err)
+	}
+	return nil
+}
+
+// verifyConnector and friends implement the minimal driver Verify needs: it
+// can open connections and begin transactions, and nothing else.
+type verifyConnector struct{}
+
+func (verifyConnector) Connect(context.Context) (driver.Conn, error) { return &verifyConn{}, nil }
+func (c verifyConnector) Driver() driver.Driver                      { return verifyDriver{c} }
+
+type verifyDriver struct{ c verifyConnector }
+
+func (d verifyDriver) Open(string) (driver.Conn, error) { return d.c.Connect(context.Background()) }
+
+type verifyConn struct{}
+
+func (*verifyConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("txraw: verify driver")
+}
+func (*verifyConn) Close() error              { return nil }
+func (*verifyConn) Begin() (driver.Tx, error) { return verifyTx{}, nil }
+
+type verifyTx struct{}
+
+func (verifyTx) Commit() error   { return nil }
+func (verifyTx) Rollback() error { return nil }
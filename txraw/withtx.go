@@ -0,0 +1,46 @@
+package txraw
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// WithTx runs fn in a new transaction on db. The transaction is committed if
+// fn returns nil and rolled back if fn returns an error or panics; a panic is
+// re-raised after the rollback.
+//
+// If fn fails and the rollback fails too, the returned error wraps both.
+// A failing commit is returned as is.
+func WithTx(ctx context.Context, db *sql.DB, fn func(tx *Tx) error) error {
+	return withTx(ctx, db, nil, fn)
+}
+
+// withTx is WithTx with opts for Begin.
+func withTx(ctx context.Context, db *sql.DB, opts *sql.TxOptions, fn func(tx *Tx) error) (err error) {
+	tx, err := Begin(ctx, db, opts)
+	if err != nil {
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		if r := recover(); r != nil {
+			_ = tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return fmt.Errorf("%w (rollback also failed: %w)", err, rollbackErr)
+		}
+		return err
+	}
+
+	committed = true
+	return tx.Commit()
+}
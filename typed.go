@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/eqld/example-tx-raw/pgxraw"
+	"github.com/eqld/example-tx-raw/txraw"
+)
+
+// typedTable has columns beyond plain text for the type-mapping scenario.
+const typedTable = "items_typed"
+
+// itemAttrs is stored as JSONB.
+type itemAttrs struct {
+	Color string `json:"color"`
+	Size  int    `json:"size"`
+}
+
+// itemRef is a custom type converted by a registered mapping.
+type itemRef struct {
+	Shelf, Slot int
+}
+
+// generateTypedData builds rows of Go values, not strings: a slice for the
+// text[] column, a struct for JSONB, times in several zones and nil pointers
+// for NULL.
+func generateTypedData(numRows int) [][]any {
+	zones := []*time.Location{time.UTC, time.FixedZone("UTC+2", 2*3600), time.FixedZone("UTC-5", -5*3600)}
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	data := make([][]any, numRows)
+	for i := 0; i < numRows; i++ {
+		var note *string
+		if i%2 == 0 {
+			s := fmt.Sprintf("Typed Note %d", i+1)
+			note = &s
+		}
+		data[i] = []any{
+			fmt.Sprintf("Typed Name %d", i+1),
+			[]string{"demo", fmt.Sprintf("row-%d", i+1)},
+			itemAttrs{Color: []string{"red", "green", "blue"}[i%3], Size: i + 1},
+			base.Add(time.Duration(i) * time.Hour).In(zones[i%len(zones)]),
+			note,
+			itemRef{Shelf: i / 4, Slot: i % 4},
+		}
+	}
+	return data
+}
+
+// demonstrateTypeMapping copies rows built from slices, structs, times and
+// nil pointers through a pgxraw.TypeMap into a table with array, JSONB and
+// timestamptz columns, and reads one back.
+func demonstrateTypeMapping(ctx context.Context, db *sql.DB) scenarioResult {
+	log.Println("--- Extra scenario: CopyFrom with arrays, JSONB, time zones and NULLs via a type map ---")
+
+	_, err := db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		id SERIAL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		tags TEXT[],
+		attrs JSONB,
+		seen_at TIMESTAMPTZ,
+		note TEXT,
+		ref TEXT
+	)`, typedTable))
+	if err != nil {
+		log.Fatalf("Failed to create %s: %v", typedTable, err)
+	}
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s", typedTable)); err != nil {
+		log.Fatalf("Failed to clear %s: %v", typedTable, err)
+	}
+
+	types := pgxraw.NewTypeMap()
+	types.Location = time.UTC
+	pgxraw.RegisterType(types, func(ref itemRef) (any, error) {
+		return fmt.Sprintf("shelf %d / slot %d", ref.Shelf, ref.Slot), nil
+	})
+
+	data := generateTypedData(6)
+	columns := []string{"name", "tags", "attrs", "seen_at", "note", "ref"}
+	var copied int64
+	err = txraw.WithTx(ctx, db, func(tx *txraw.Tx) error {
+		return tx.RawContext(ctx, txraw.UnwrapAs(func(conn *pgx.Conn) error {
+			copied, err = conn.CopyFrom(ctx, pgx.Identifier{typedTable}, columns, types.Source(pgx.CopyFromRows(data)))
+			return err
+		}))
+	})
+	if err != nil {
+		log.Printf("✗ Typed CopyFrom failed and was rolled back: %v", err)
+		log.Println()
+		return scenarioResult{loaded: len(data), err: err}
+	}
+	log.Printf("✓ Copied %d typed rows", copied)
+
+	var tags, attrs, seenAt, ref string
+	var persisted, notes int
+	err = db.QueryRowContext(ctx, fmt.Sprintf(`SELECT tags::text, attrs::text, seen_at::text, ref,
+		(SELECT COUNT(*) FROM %[1]s), (SELECT COUNT(note) FROM %[1]s)
+		FROM %[1]s ORDER BY id LIMIT 1 OFFSET 1`, typedTable)).
+		Scan(&tags, &attrs, &seenAt, &ref, &persisted, &notes)
+	if err != nil {
+		log.Fatalf("Failed to read back %s: %v", typedTable, err)
+	}
+	log.Printf("  row 2: tags=%s attrs=%s seen_at=%s ref=%q", tags, attrs, seenAt, ref)
+	log.Printf("✓ Result: %d rows persisted (Expected: %d), %d with a note, the rest NULL from nil pointers",
+		persisted, len(data), notes)
+	log.Println()
+	return scenarioResult{loaded: len(data), persisted: persisted}
+}